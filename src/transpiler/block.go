@@ -0,0 +1,558 @@
+package transpiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// if expression and block implementation in Go
+/**
+let grade: string = if mark > 90 { "gold" } else { "silver" }
+let area: float = {
+	let r: float = 2.0
+	r * r * 3.14
+}
+
+var grade string
+_ = grade
+if mark > 90 {
+	grade = "gold"
+} else {
+	grade = "silver"
+}
+var area float64
+_ = area
+{
+	var r float64 = 2.0
+	area = r * r * 3.14
+}
+
+// the value of each branch is assigned to a temporary, or returned when the
+// if expression is the value of a function
+
+let n: int = 1 + if mark > 90 { 2 } else { 3 }
+
+var n int = 1 + func() int {
+	if mark > 90 {
+		return 2
+	} else {
+		return 3
+	}
+}()
+
+// an if expression written on one line can also be part of another expression
+// or an argument, where its branches return from a function literal instead
+*/
+
+// how the value of each branch of an if expression or block is used
+type blockTarget struct {
+	assign   string // Go written before the value e.g. "grade =" or "return"
+	dataType primitiveType
+}
+
+type ValueBlock struct {
+	declaration *Variable    // variable declared by let, nil otherwise
+	conditions  []Expression // one for each branch except else
+	branches    []Scope
+	multiLine   bool // the closing } of multi-line if expressions is part of the parent scope
+}
+
+// final line of a branch, whose value is assigned or returned
+type BlockResult struct {
+	target blockTarget
+	value  Transpileable
+}
+
+// if c { a } else { b } used inside another expression
+type IfExpression struct {
+	block    ValueBlock
+	dataType primitiveType
+}
+
+func isValueBlock(value string) bool {
+	trimmed := strings.Trim(value, " ")
+	return strings.HasPrefix(trimmed, "if ") || strings.HasPrefix(trimmed, "{")
+}
+
+func blockValue(line string) (string, bool) {
+	// value of a let, assignment or return if it is an if expression or block
+	trimmed := strings.Trim(line, " ")
+	if strings.HasPrefix(trimmed, "return ") {
+		value := trimmed[len("return"):]
+		return value, isValueBlock(value)
+	}
+	equals := strings.Index(trimmed, "=")
+	if equals == -1 || strings.HasPrefix(trimmed, "if ") {
+		return "", false
+	}
+	value := trimmed[equals+1:]
+	return value, isValueBlock(value)
+}
+
+func isValueIf(lines []string, lineNum int) bool {
+	// whether an if statement which isn't assigned or returned gives a value
+	if findScopeEnd(lines, lineNum) == lineNum {
+		// if statements have to be written on multiple lines
+		return true
+	}
+	for branch := lineNum; ; {
+		if blockYields(lines, branch) {
+			return true
+		}
+		closer := findScopeEnd(lines, branch)
+		if words := strings.Fields(lines[closer]); len(words) < 2 || words[1] != "else" {
+			return false
+		}
+		branch = closer
+	}
+}
+
+func blockYields(lines []string, open int) bool {
+	// whether the last item of the block opened on line open is a value
+	end := findScopeEnd(lines, open)
+	var yields bool
+	for n := open + 1; n < end; n++ {
+		words := strings.Fields(lines[n])
+		if len(words) == 0 {
+			continue
+		}
+		switch {
+		case words[0] == "if":
+			yields = isValueIf(lines, n)
+			n = chainEnd(lines, n)
+		case words[0] == "{":
+			yields = true
+			n = findScopeEnd(lines, n)
+		case strings.Contains(lines[n], "{"):
+			yields = !isStatement(lines[n])
+			n = chainEnd(lines, n)
+		case words[0] == "break" || words[0] == "continue":
+			yields = false
+		default:
+			yields = !isStatement(lines[n])
+		}
+	}
+	return yields
+}
+
+func chainEnd(lines []string, lineNum int) int {
+	// line of the } which closes the last branch of an if chain
+	end := findScopeEnd(lines, lineNum)
+	for {
+		words := strings.Fields(lines[end])
+		if len(words) < 2 || words[0] != "}" || words[1] != "else" {
+			return end
+		}
+		end = findScopeEnd(lines, end)
+	}
+}
+
+func parseValueBlockItem(lines []string, lineNum int, end int, currentScope *Scope) (ValueBlock, int) {
+	// parses a line which uses the value of an if expression or block, returning the
+	// last line of the if expression
+	line := lines[lineNum]
+	trimmed := strings.Trim(line, " ")
+	words := strings.Fields(line)
+	start := strings.Index(line, trimmed)
+
+	var target blockTarget
+	var declaration *Variable
+	switch {
+	case words[0] == "let":
+		v := parseValueDeclaration(line, lineNum, currentScope)
+		declaration = &v
+		target = blockTarget{assign: v.identifier + " =", dataType: v.dataType}
+		start = strings.Index(line, "=") + 1
+	case words[0] == "return":
+		if returnedType == IO {
+			panic(fmt.Sprintf("Line %d: cannot return a value from a function with return type IO", lineNum+1))
+		}
+		target = blockTarget{assign: "return", dataType: returnedType}
+		start += len("return")
+	case words[0] == "if" || words[0] == "{":
+		// the value of the enclosing block or function
+		if currentScope.target != nil {
+			target = *currentScope.target
+		} else if currentScope.scopeType == FunctionScope && returnedType != IO {
+			target = blockTarget{assign: "return", dataType: returnedType}
+		} else {
+			panic(fmt.Sprintf("Line %d: value of %s expression is never used, assign it with let or return it", lineNum+1, words[0]))
+		}
+		last := lineNum
+		if words[0] == "if" {
+			last = chainEnd(lines, lineNum)
+		} else {
+			last = findScopeEnd(lines, lineNum)
+		}
+		for n := last + 1; n < end; n++ {
+			if len(strings.Trim(lines[n], " ")) != 0 {
+				panic(fmt.Sprintf("Line %d: found dead code after value of %s expression", n+1, words[0]))
+			}
+		}
+	default:
+		target = parseAssignmentTarget(line, lineNum, currentScope)
+		start = strings.Index(line, "=") + 1
+	}
+
+	v, last := parseValueBlock(lines, lineNum, start, target, currentScope)
+	if declaration != nil {
+		// the variable can't be used inside its own value
+		v.declaration = declaration
+		declareValue(*declaration, currentScope)
+	}
+	return v, last
+}
+
+func parseValueDeclaration(line string, lineNum int, currentScope *Scope) Variable {
+	// let x: T = if ..., which is declared after its value is parsed
+	words := strings.Fields(line)
+	mut := len(words) > 1 && words[1] == "mut"
+	identifierIndex := 1
+	if mut {
+		identifierIndex = 2
+	}
+	if len(words) <= identifierIndex {
+		panic(fmt.Sprintf("Line %d: expected identifier after let", lineNum+1))
+	}
+	id := parseIdentifier(words[identifierIndex], lineNum)
+
+	if _, v := (*currentScope).vars[id]; v {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
+	} else if _, f := (*currentScope).functions[id]; f {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
+	} else if _, a := (*currentScope).arrays[id]; a {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
+	} else if _, t := (*currentScope).tuples[id]; t {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
+	}
+
+	annotation := typeAnnotation(line)
+	if len(annotation) == 0 {
+		panic(fmt.Sprintf("Line %d: if expressions and blocks need a type annotation e.g. let %s: int = if ...", lineNum+1, id))
+	}
+	T := readType(annotation, lineNum)
+	if T == IO {
+		panic(fmt.Sprintf("Line %d: variables cannot have data type IO", lineNum+1))
+	}
+	return Variable{
+		identifier: id,
+		dataType:   T,
+		mut:        mut,
+	}
+}
+
+func declareValue(v Variable, currentScope *Scope) {
+	// adds a variable declared with an if expression or block to the scope as a
+	// variable, array or tuple depending on its type
+	switch {
+	case isArray(v.dataType):
+		a, _ := v.dataType.defined()
+		currentScope.arrays[v.identifier] = Array{identifier: v.identifier, dataType: a.array, mut: v.mut}
+	case isTuple(v.dataType):
+		t, _ := v.dataType.defined()
+		currentScope.tuples[v.identifier] = Tuple{identifier: v.identifier, pattern: TuplePattern{dataTypes: t.elements}, mut: v.mut}
+	default:
+		currentScope.vars[v.identifier] = v
+	}
+}
+
+func parseAssignmentTarget(line string, lineNum int, currentScope *Scope) blockTarget {
+	// x = if ..., where x can also be an array, tuple or member
+	left, operator, _ := splitAssignment(line, lineNum)
+	var mut bool
+	var T primitiveType
+	if v, ok := currentScope.vars[left]; ok {
+		mut, T = v.mut, v.dataType
+	} else if arr, ok := currentScope.arrays[left]; ok {
+		mut, T = arr.mut, arrayTypeID(arr.dataType)
+	} else if t, ok := currentScope.tuples[left]; ok {
+		mut, T = t.mut, tupleType(t.pattern)
+	} else if isMemberAccess(left, currentScope) {
+		member := parseMemberAccess(left, lineNum, currentScope)
+		T = member.dataType
+		left = member.transpile()
+		if v, ok := currentScope.vars[member.root]; ok {
+			mut = v.mut
+		} else if arr, ok := currentScope.arrays[member.root]; ok {
+			mut = arr.mut
+		} else if t, ok := currentScope.tuples[member.root]; ok {
+			mut = t.mut
+		}
+	} else {
+		panic(fmt.Sprintf("Line %d: assignment to variable %s not in scope", lineNum+1, left))
+	}
+
+	if !mut {
+		panic(fmt.Sprintf("Line %d: attempt to assign new value to immutable value %s", lineNum+1, left))
+	}
+	if operator != "=" && (isArray(T) || isTuple(T)) {
+		panic(fmt.Sprintf("Line %d: %s cannot be used with %s of type %v", lineNum+1, operator, left, T))
+	}
+	checkAssignmentOperator(operator, T, T, lineNum)
+	return blockTarget{assign: left + " " + operator, dataType: T}
+}
+
+func parseValueBlock(lines []string, lineNum int, start int, target blockTarget, currentScope *Scope) (ValueBlock, int) {
+	// parses the if expression or block starting at character start of the line
+	value := strings.Trim(lines[lineNum][start:], " ")
+	if findScopeEnd(lines, lineNum) == lineNum {
+		return parseInlineValueBlock(value, lineNum, target, currentScope), lineNum
+	}
+
+	v := ValueBlock{multiLine: true}
+	header := *currentScope // carries the target to the branches
+	header.parent = currentScope
+	header.target = &target
+
+	if value == "{" {
+		v.branches = append(v.branches, parseBranch(lines, lineNum, target, &header))
+		return v, findScopeEnd(lines, lineNum)
+	}
+	if !strings.HasSuffix(value, "{") {
+		panic(fmt.Sprintf("Line %d: expected { at the end of the line of a multi-line if expression", lineNum+1))
+	}
+
+	hasElse := false
+	branch := lineNum
+	condition := value
+	for {
+		if hasElse {
+			panic(fmt.Sprintf("Line %d: found another branch after else", branch+1))
+		}
+		if strings.HasPrefix(condition, "if") {
+			condition = strings.Trim(condition[len("if"):len(condition)-1], " ")
+			if len(condition) == 0 {
+				panic(fmt.Sprintf("Line %d: if expression has no condition", branch+1))
+			}
+			v.conditions = append(v.conditions, parseCondition(condition, len(v.conditions) == 0, branch, currentScope))
+		} else if condition == "{" {
+			hasElse = true
+		} else {
+			panic(fmt.Sprintf("Line %d: expected if or { after else", branch+1))
+		}
+		v.branches = append(v.branches, parseBranch(lines, branch, target, &header))
+
+		closer := findScopeEnd(lines, branch)
+		words := strings.Fields(lines[closer])
+		if len(words) == 1 {
+			if !hasElse {
+				panic(fmt.Sprintf("Line %d: if expression must have an else branch so that it always has a value", closer+1))
+			}
+			return v, closer
+		}
+		if words[1] != "else" {
+			panic(fmt.Sprintf("Line %d: only an else/else if statement can be opened on the same line where another scope is closed", closer+1))
+		}
+		closing := strings.Trim(lines[closer], " ")
+		condition = strings.Trim(strings.TrimPrefix(strings.Trim(closing[1:], " "), "else"), " ")
+		branch = closer
+	}
+}
+
+func isIfExpression(token string) bool {
+	return strings.HasPrefix(token, "if ")
+}
+
+func ifExpressionEnd(expression string, start int, lineNum int) int {
+	// index of the } closing the last branch of the if expression which starts at start
+	i := start
+	for {
+		open := strings.Index(expression[i:], "{")
+		if open == -1 {
+			return len(expression) - 1 // reported by splitInlineBranches()
+		}
+		close, depth := -1, 0
+		for j := i + open; j < len(expression) && close == -1; j++ {
+			switch expression[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					close = j
+				}
+			}
+		}
+		if close == -1 {
+			panic(fmt.Sprintf("Line %d: an if expression which is part of another expression must be written on one line", lineNum+1))
+		}
+		rest := strings.TrimLeft(expression[close+1:], " ")
+		if !strings.HasPrefix(rest, "else ") && !strings.HasPrefix(rest, "else{") {
+			return close
+		}
+		i = len(expression) - len(rest) + len("else")
+	}
+}
+
+func parseIfExpression(token string, lineNum int, currentScope *Scope) IfExpression {
+	// the branches are inside a function literal, so they can't leave the function around it
+	conditionalDepth++
+	defer func() { conditionalDepth-- }()
+	_, branches := splitInlineBranches(token, lineNum)
+	var T primitiveType
+	typed := false
+	for _, branch := range branches {
+		words := strings.Fields(branch)
+		if len(words) == 0 {
+			panic(fmt.Sprintf("Line %d: branch of if expression has no value", lineNum+1))
+		}
+		switch {
+		case words[0] == "return" || words[0] == "break" || words[0] == "continue":
+			panic(fmt.Sprintf("Line %d: %s cannot be used in an if expression which is part of another expression", lineNum+1, words[0]))
+		case isPanic(words[0]):
+			continue
+		}
+		if !typed {
+			// the other branches must have the same type as the first one
+			T = parseExpression(branch, lineNum, currentScope).dataType
+			typed = true
+		}
+	}
+	if !typed {
+		panic(fmt.Sprintf("Line %d: if expression has no branch which gives a value", lineNum+1))
+	}
+	return IfExpression{
+		block:    parseInlineValueBlock(token, lineNum, blockTarget{assign: "return", dataType: T}, currentScope),
+		dataType: T,
+	}
+}
+
+func parseCondition(condition string, first bool, lineNum int, currentScope *Scope) Expression {
+	parse := func() Expression { return parseExpression(condition, lineNum, currentScope) }
+	var e Expression
+	if first {
+		e = parse()
+	} else {
+		// only checked when the branches before aren't taken
+		e = conditionally(parse)
+	}
+	if e.dataType != Bool {
+		panic(fmt.Sprintf("Line %d: condition of if expression must have type bool but found type %v", lineNum+1, e.dataType))
+	}
+	return e
+}
+
+func parseBranch(lines []string, open int, target blockTarget, header *Scope) Scope {
+	branch := parseScope(lines, open, BlockScope, header)
+	checkBranch(branch, target, open)
+	return branch
+}
+
+func checkBranch(branch Scope, target blockTarget, lineNum int) {
+	// the last item of each branch has to give its value, unless it leaves the
+	// branch with return, break, continue or panic!()
+	var last Transpileable
+	for _, item := range branch.items {
+		if _, ok := last.(BlockResult); ok {
+			panic(fmt.Sprintf("Line %d: found dead code after value of branch", lineNum+1))
+		}
+		if _, ok := item.(ScopeCloser); !ok {
+			last = item
+		}
+	}
+	switch l := last.(type) {
+	case BlockResult, ValueBlock, EarlyReturn, BreakStatement:
+		return
+	case Macro:
+		if l.T == Panic {
+			return
+		}
+	}
+	panic(fmt.Sprintf("Line %d: branch must end with a value of type %v", lineNum+1, target.dataType))
+}
+
+func parseInlineValueBlock(value string, lineNum int, target blockTarget, currentScope *Scope) ValueBlock {
+	// if c { a } else if d { b } else { c } or { a } written on one line
+	var v ValueBlock
+	conditions, branches := splitInlineBranches(value, lineNum)
+	for i, condition := range conditions {
+		v.conditions = append(v.conditions, parseCondition(condition, i == 0, lineNum, currentScope))
+	}
+	for _, branch := range branches {
+		v.branches = append(v.branches, parseInlineBranch(branch, lineNum, target, currentScope))
+	}
+	return v
+}
+
+func splitInlineBranches(value string, lineNum int) ([]string, []string) {
+	// splits an if expression written on one line into its conditions and the
+	// contents of its branches
+	var conditions, branches []string
+	rest := strings.Trim(value, " ")
+	hasElse := false
+	for {
+		if hasElse {
+			panic(fmt.Sprintf("Line %d: found another branch after else", lineNum+1))
+		}
+		if strings.HasPrefix(rest, "if ") {
+			open := strings.Index(rest, "{")
+			if open == -1 {
+				panic(fmt.Sprintf("Line %d: expected { after condition of if expression", lineNum+1))
+			}
+			condition := strings.Trim(rest[len("if"):open], " ")
+			if len(condition) == 0 {
+				panic(fmt.Sprintf("Line %d: if expression has no condition", lineNum+1))
+			}
+			conditions = append(conditions, condition)
+			rest = rest[open:]
+		} else if strings.HasPrefix(rest, "{") {
+			hasElse = true
+		} else {
+			panic(fmt.Sprintf("Line %d: expected if or { after else", lineNum+1))
+		}
+
+		close := findBracketEnd('{', []string{rest}, 0, 0).charIndex
+		if close == -1 {
+			panic(fmt.Sprintf("Line %d: branch of if expression opened with { but never closed", lineNum+1))
+		}
+		branches = append(branches, strings.Trim(rest[1:close], " "))
+
+		rest = strings.Trim(rest[close+1:], " ")
+		if len(rest) == 0 {
+			if !hasElse && len(conditions) != 0 {
+				panic(fmt.Sprintf("Line %d: if expression must have an else branch so that it always has a value", lineNum+1))
+			}
+			return conditions, branches
+		}
+		if len(conditions) == 0 {
+			panic(fmt.Sprintf("Line %d: found unexpected tokens after block", lineNum+1))
+		}
+		if !strings.HasPrefix(rest, "else ") && rest != "else" {
+			panic(fmt.Sprintf("Line %d: expected else after branch of if expression", lineNum+1))
+		}
+		rest = strings.Trim(rest[len("else"):], " ")
+	}
+}
+
+func parseInlineBranch(value string, lineNum int, target blockTarget, currentScope *Scope) Scope {
+	// a branch written on one line contains only its value
+	value = strings.Trim(value, " ")
+	branch := childScope(currentScope, BlockScope)
+	branch.target = &target
+
+	words := strings.Fields(value)
+	if len(words) == 0 {
+		panic(fmt.Sprintf("Line %d: branch must end with a value of type %v", lineNum+1, target.dataType))
+	}
+	switch {
+	case words[0] == "return":
+		branch.items = []Transpileable{parseEarlyReturn(value, lineNum, &branch)}
+	case words[0] == "break" || words[0] == "continue":
+		branch.items = []Transpileable{parseBreak(value, lineNum, &branch)}
+	case isPanic(words[0]):
+		branch.items = []Transpileable{parseMacro(value, lineNum, &branch)}
+	case isValueBlock(value):
+		branch.items = []Transpileable{parseInlineValueBlock(value, lineNum, target, &branch)}
+	default:
+		branch.items = []Transpileable{parseBlockResult(value, lineNum, &branch)}
+	}
+	return branch
+}
+
+func parseBlockResult(line string, lineNum int, currentScope *Scope) BlockResult {
+	target := *currentScope.target
+	return BlockResult{
+		target: target,
+		value:  parseTypedValue(strings.Trim(line, " "), target.dataType, lineNum, currentScope),
+	}
+}
@@ -0,0 +1,309 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// control flow analysis of function bodies
+/**
+function sign(x: int) -> int = {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+}
+
+Line 1: function sign can reach its end without giving a value of type int
+
+// each statement is a node with edges to the statements which can run after it.
+// return statements and the final value of the function lead to the result node,
+// and the end of the function is the exit node, which must be unreachable in
+// functions which don't have return type IO. panic!() has no edges, because
+// nothing runs after it
+*/
+
+const (
+	flowExit   = iota // end of the function without a value
+	flowResult        // return statement or final value
+)
+
+type flowNode struct {
+	lineNum int
+	edges   []int // nodes which can run next
+}
+
+type controlFlowGraph struct {
+	nodes []flowNode
+	entry int
+}
+
+type flowLoop struct {
+	label string // e.g. 'outer, empty if the loop has no label
+	head  int    // reached by continue
+	after int    // reached by break
+}
+
+type flowContext struct {
+	value int        // node reached by the value at the end of the block, -1 if it isn't used
+	loops []flowLoop // enclosing loops, innermost last
+}
+
+func buildControlFlowGraph(lines []string, open int) controlFlowGraph {
+	// graph of the body of the function declared on line open
+	g := controlFlowGraph{
+		nodes: []flowNode{{lineNum: -1}, {lineNum: -1}},
+	}
+	g.entry = g.block(lines, open+1, findScopeEnd(lines, open), flowExit, flowContext{value: flowResult})
+	return g
+}
+
+func (g *controlFlowGraph) add(lineNum int, edges ...int) int {
+	g.nodes = append(g.nodes, flowNode{lineNum: lineNum, edges: edges})
+	return len(g.nodes) - 1
+}
+
+func (g *controlFlowGraph) block(lines []string, start, end, next int, ctx flowContext) int {
+	// adds the items between lines start and end, returning the node where the block
+	// begins. items are added from last to first so that each one knows what runs after it
+	var items [][2]int // first and last line of each item
+	for n := start; n < end; n++ {
+		if len(strings.Fields(lines[n])) == 0 {
+			continue
+		}
+		last := n
+		if strings.Contains(lines[n], "{") {
+			last = chainEnd(lines, n)
+		}
+		items = append(items, [2]int{n, last})
+		n = last
+	}
+
+	entry := next
+	for i := len(items) - 1; i >= 0; i-- {
+		itemCtx := ctx
+		if i != len(items)-1 {
+			// only the last item gives the value of the block
+			itemCtx.value = -1
+		}
+		entry = g.item(lines, items[i][0], items[i][1], entry, itemCtx)
+	}
+	return entry
+}
+
+func (g *controlFlowGraph) item(lines []string, first, last, next int, ctx flowContext) int {
+	line := strings.Trim(lines[first], " ")
+	words := strings.Fields(line)
+	value, isBlockValue := blockValue(line)
+
+	switch {
+	case words[0] == "return":
+		if isBlockValue {
+			return g.valueBlock(lines, first, last, value, flowResult, next, ctx)
+		}
+		return g.add(first, flowResult)
+
+	case words[0] == "break" || words[0] == "continue":
+		return g.add(first, ctx.jump(words, next))
+
+	case isPanic(words[0]):
+		return g.add(first)
+
+	case words[0] == "function":
+		// local function, whose body is checked when it is parsed
+		return g.add(first, next)
+
+	case words[0] == "loop" || isLoopLabel(words[0]):
+		head := g.add(first)
+		inner := flowContext{value: -1, loops: slices.Clone(ctx.loops)}
+		var label string
+		if isLoopLabel(words[0]) {
+			label = strings.TrimSuffix(words[0], ":")
+		}
+		inner.loops = append(inner.loops, flowLoop{label: label, head: head, after: next})
+		body := g.block(lines, first+1, last, head, inner)
+
+		g.nodes[head].edges = append(g.nodes[head].edges, body)
+		if !isInfiniteLoop(line) {
+			// the condition can be false, or there can be nothing to iterate over
+			g.nodes[head].edges = append(g.nodes[head].edges, next)
+		}
+		return head
+
+	case words[0] == "if":
+		if first == last {
+			return g.valueBlock(lines, first, last, line, ctx.value, next, ctx)
+		}
+		target := -1
+		if isValueIf(lines, first) {
+			target = ctx.value
+		}
+		return g.chain(lines, first, target, next, ctx)
+
+	case words[0] == "{":
+		return g.valueBlock(lines, first, last, line, ctx.value, next, ctx)
+
+	case isBlockValue:
+		// let or assignment, whose value is given to the rest of the block
+		return g.valueBlock(lines, first, last, value, next, next, ctx)
+
+	case !isStatement(line) && ctx.value != -1:
+		return g.add(first, ctx.value)
+	}
+	return g.add(first, next)
+}
+
+func (g *controlFlowGraph) valueBlock(lines []string, first, last int, value string, target, next int, ctx flowContext) int {
+	// if expression or block whose value goes to target
+	value = strings.Trim(value, " ")
+	inner := ctx
+	inner.value = target
+
+	if first == last {
+		_, branches := splitInlineBranches(value, first)
+		head := g.add(first)
+		for _, branch := range branches {
+			entry := g.inlineBranch(first, branch, next, inner)
+			g.nodes[head].edges = append(g.nodes[head].edges, entry)
+		}
+		return head
+	}
+	if value == "{" {
+		return g.block(lines, first+1, last, next, inner)
+	}
+	return g.chain(lines, first, target, next, ctx)
+}
+
+func (g *controlFlowGraph) inlineBranch(lineNum int, branch string, next int, ctx flowContext) int {
+	words := strings.Fields(branch)
+	switch {
+	case len(words) == 0:
+		return g.add(lineNum, next)
+	case words[0] == "return":
+		return g.add(lineNum, flowResult)
+	case words[0] == "break" || words[0] == "continue":
+		return g.add(lineNum, ctx.jump(words, next))
+	case isPanic(words[0]):
+		return g.add(lineNum)
+	case isValueBlock(branch):
+		return g.valueBlock(nil, lineNum, lineNum, branch, ctx.value, next, ctx)
+	}
+	if ctx.value == -1 {
+		return g.add(lineNum, next)
+	}
+	return g.add(lineNum, ctx.value)
+}
+
+func (g *controlFlowGraph) chain(lines []string, first int, target, next int, ctx flowContext) int {
+	// if statement with any else if and else branches, where the value at the end of
+	// each branch goes to target
+	head := g.add(first)
+	inner := ctx
+	inner.value = target
+
+	hasElse := false
+	for branch := first; ; {
+		end := findScopeEnd(lines, branch)
+		entry := g.block(lines, branch+1, end, next, inner)
+		g.nodes[head].edges = append(g.nodes[head].edges, entry)
+
+		words := strings.Fields(lines[end])
+		if len(words) < 2 || words[1] != "else" {
+			break
+		}
+		hasElse = len(words) < 3 || words[2] != "if"
+		branch = end
+	}
+	if !hasElse {
+		// none of the conditions might be true
+		g.nodes[head].edges = append(g.nodes[head].edges, next)
+	}
+	return head
+}
+
+func (ctx flowContext) jump(words []string, next int) int {
+	// node reached by break or continue
+	for i := len(ctx.loops) - 1; i >= 0; i-- {
+		loop := ctx.loops[i]
+		if len(words) > 1 && words[1] != loop.label {
+			continue
+		}
+		if words[0] == "break" {
+			return loop.after
+		}
+		return loop.head
+	}
+	// not inside a loop, which is reported when the line is parsed
+	return next
+}
+
+func isPanic(word string) bool {
+	// panic!() stops the program, so the rest of the function is never reached
+	return strings.HasPrefix(word, "panic!")
+}
+
+func isInfiniteLoop(line string) bool {
+	// loop true, which can only be left with break or return
+	words := strings.Fields(line)
+	if len(words) > 0 && isLoopLabel(words[0]) {
+		words = words[1:]
+	}
+	return len(words) == 3 && words[0] == "loop" && words[1] == "true" && words[2] == "{"
+}
+
+func (g controlFlowGraph) reachable() []bool {
+	reached := make([]bool, len(g.nodes))
+	stack := []int{g.entry}
+	for len(stack) != 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reached[node] {
+			continue
+		}
+		reached[node] = true
+		stack = append(stack, g.nodes[node].edges...)
+	}
+	return reached
+}
+
+func checkControlFlow(lines []string, lineNum int, identifier string) {
+	// checks that there is no code which can't be reached, and that every path through
+	// a function which doesn't have return type IO gives a value
+	if findScopeEnd(lines, lineNum) == lineNum {
+		return
+	}
+	g := buildControlFlowGraph(lines, lineNum)
+	reached := g.reachable()
+	dead := -1 // first line which can't be reached
+	for i := flowResult + 1; i < len(g.nodes); i++ {
+		if !reached[i] && (dead == -1 || g.nodes[i].lineNum < dead) {
+			dead = g.nodes[i].lineNum
+		}
+	}
+	if dead != -1 {
+		panic(fmt.Sprintf("Line %d: found dead code which can never be reached", dead+1))
+	}
+	if returnedType != IO && reached[flowExit] {
+		panic(fmt.Sprintf("Line %d: function %s can reach its end without giving a value of type %v", lineNum+1, identifier, returnedType))
+	}
+}
+
+func endsWithExpression(lines []string, open int) bool {
+	// whether the last item of the block opened on line open is an expression, which
+	// isn't an if expression or block
+	end := findScopeEnd(lines, open)
+	var last bool
+	for n := open + 1; n < end; n++ {
+		words := strings.Fields(lines[n])
+		if len(words) == 0 {
+			continue
+		}
+		last = !isStatement(lines[n])
+		if strings.Contains(lines[n], "{") {
+			n = chainEnd(lines, n)
+		}
+	}
+	return last
+}
@@ -2,14 +2,16 @@ package transpiler
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 type Array struct {
-	identifier string
-	dataType   ArrayType
-	mut        bool
+	identifier  string
+	dataType    ArrayType
+	mut         bool
+	lengthParam string // optional - name of the length of an array parameter e.g. n in xs: T[n]
 }
 
 // let nums: int[5] = [1, 2, 3, 4, 5]
@@ -19,14 +21,14 @@ type ArrayType struct {
 }
 
 type BaseArray struct {
-	values   []Expression
+	values   []Transpileable // elements can be expressions, tuples or arrays
 	dataType primitiveType
 	length   int
 }
 
 type ArrayValue[T primitiveType] struct {
 	children []*ArrayValue[T] // will be empty if it is a base-array
-	elements []Expression     // will be empty if not a base-array
+	elements []Transpileable  // will be empty if not a base-array
 	baseType primitiveType
 	length   int
 }
@@ -49,13 +51,67 @@ type ArrayAssignment struct {
 
 type ArrayIndexAssignment struct {
 	arrIndex ArrayIndexing
-	value    Expression
+	value    Transpileable
+	operator string
 }
 
 type ArrayExpression struct {
 	stringValue string
 	dataType    ArrayType
-	literal     BaseArray // optional - needed for transpile()
+	literal     BaseArray     // optional - needed for transpile()
+	node        Transpileable // optional - function call, element of another value or slice of an array
+	conversion  ArrayType     // optional - array type that a slice is copied into
+}
+
+// xs[1..3]
+type ArraySlice struct {
+	arrayID  string
+	dataType ArrayType  // first dimension is -1 if the length isn't known until the program runs
+	start    Expression // optional - defaults to the start of the array
+	end      Expression // optional - defaults to the end of the array
+}
+
+// tuple or array literal or slice compared with another value e.g. t == (1, 2)
+type ComparedCollection struct {
+	value Transpileable
+}
+
+func (A ArrayType) goType() string {
+	var transpiled string
+	for _, d := range A.dimensions {
+		if d == -1 {
+			// length given by a parameter so it becomes a slice
+			transpiled += "[]"
+			continue
+		}
+		transpiled += fmt.Sprintf("[%d]", d)
+	}
+	return transpiled + A.baseType.goType()
+}
+
+func arrayTypeID(A ArrayType) primitiveType {
+	// returns the id of the array type so that it can be used as an element of other types
+	// the same array type always gives the same id
+	identifier := A.baseType.String()
+	for _, d := range A.dimensions {
+		if d == -1 {
+			identifier += "[n]"
+		} else {
+			identifier += fmt.Sprintf("[%d]", d)
+		}
+	}
+
+	for i, d := range definedTypes {
+		// compared by structure rather than identifier as type parameters in different functions can share names
+		if d.kind == ArrayKind && d.array.baseType == A.baseType && slices.Equal(d.array.dimensions, A.dimensions) {
+			return firstDefinedType + primitiveType(i)
+		}
+	}
+	return defineType(definedType{
+		identifier: identifier,
+		kind:       ArrayKind,
+		array:      A,
+	}, 0)
 }
 
 func squareBracketEnd(s string, start, lineNum int) int {
@@ -78,8 +134,16 @@ func squareBracketEnd(s string, start, lineNum int) int {
 func parseArrayType(typeWord string, lineNum int) ArrayType {
 	// parse array type based on type annotation
 	squareBracketIndex := -1
+	var bracketCount int
 	for i := 0; i < len(typeWord); i++ {
-		if typeWord[i] == '[' {
+		// base type can be a tuple containing arrays e.g. (int, float[2])[10]
+		switch typeWord[i] {
+		case '(':
+			bracketCount++
+		case ')':
+			bracketCount--
+		}
+		if typeWord[i] == '[' && bracketCount == 0 {
 			squareBracketIndex = i
 			break
 		}
@@ -96,7 +160,7 @@ func parseArrayType(typeWord string, lineNum int) ArrayType {
 	}
 
 	dims := typeWord[squareBracketIndex:]
-	bracketCount := 0
+	bracketCount = 0
 
 	// check for valid dimensions declaration
 	var dimensions []int
@@ -149,51 +213,25 @@ func parseBaseArray(arrayValue string, expectedType primitiveType, currentScope
 	}
 	if len(arrayValue) == 2 {
 		return BaseArray{
-			values:   []Expression{},
+			values:   []Transpileable{},
 			dataType: expectedType,
 			length:   0,
 		}
 	}
 
-	var currentElement string
-	var elements []Expression
-
-	var stringLiteralCount int
-
-	for i := 1; i < len(arrayValue); i++ {
-		if arrayValue[i] == '"' {
-			if stringLiteralCount == 1 {
-				stringLiteralCount = 0
-			} else {
-				stringLiteralCount = 1
-			}
-		}
+	var elements []Transpileable
 
-		if stringLiteralCount != 0 {
-			// inside string literal
-			currentElement += string(arrayValue[i])
+	// split at the top level so that elements can be tuples, records or function calls
+	for _, element := range splitTopLevel(arrayValue[1:len(arrayValue)-1], ',') {
+		if isTuple(expectedType) || isArray(expectedType) {
+			elements = append(elements, parseTypedValue(element, expectedType, lineNum, currentScope))
 			continue
 		}
-		switch arrayValue[i] {
-		case ',':
-			expr := parseExpression(currentElement, lineNum, currentScope)
-			if expr.dataType != expectedType {
-				panic(fmt.Sprintf("Line %d: found element of type %v in array of type %v", lineNum+1, expr.dataType, expectedType))
-			}
-			elements = append(elements, expr)
-			currentElement = ""
-		case ' ':
-			currentElement = ""
-		case ']':
-			expr := parseExpression(currentElement, lineNum, currentScope)
-			if expr.dataType != expectedType {
-				panic(fmt.Sprintf("Line %d: found element of type %v in array of type %v", lineNum+1, expr.dataType, expectedType))
-			}
-			elements = append(elements, expr)
-			currentElement = ""
-		default:
-			currentElement += string(arrayValue[i])
+		expr := parseContextualExpression(element, expectedType, lineNum, currentScope)
+		if expr.dataType != expectedType {
+			panic(fmt.Sprintf("Line %d: found element of type %v in array of type %v", lineNum+1, expr.dataType, expectedType))
 		}
+		elements = append(elements, expr)
 	}
 	return BaseArray{
 		values:   elements,
@@ -213,14 +251,14 @@ func parseArrayValue[T primitiveType](arrayValue string, expectedType primitiveT
 	if len(arrayValue) == 2 {
 		return ArrayValue[T]{
 			children: []*ArrayValue[T]{},
-			elements: []Expression{},
+			elements: []Transpileable{},
 			baseType: expectedType,
 			length:   0,
 		}
 	}
 
 	var children []*ArrayValue[T]
-	var elements []Expression
+	var elements []Transpileable
 	var L int
 
 	var bracketCount, maxBracketCount int
@@ -298,9 +336,12 @@ func parseArrayDeclaration(line string, lineNum int, currentScope *Scope) ArrayD
 		mut = true
 	}
 
-	typeIndex, equalsIndex := identifierIndex+1, identifierIndex+2
+	annotation := typeAnnotation(line)
+	if len(annotation) == 0 {
+		panic(fmt.Sprintf("Line %d: expected type annotation and = sign after identifier in array declaration", lineNum+1))
+	}
 
-	expectedType := parseArrayType(words[typeIndex], lineNum)
+	expectedType := parseArrayType(annotation, lineNum)
 
 	id := parseIdentifier(words[identifierIndex], lineNum)
 
@@ -314,10 +355,6 @@ func parseArrayDeclaration(line string, lineNum int, currentScope *Scope) ArrayD
 		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
 	}
 
-	if words[equalsIndex] != "=" {
-		panic(fmt.Sprintf("Line %d: expected = sign but found %s", lineNum+1, words[equalsIndex]))
-	}
-
 	var equalsCharIndex int // expression is everything after equals
 	for i := 0; i < len(line); i++ {
 		if line[i] == '=' {
@@ -331,15 +368,7 @@ func parseArrayDeclaration(line string, lineNum int, currentScope *Scope) ArrayD
 		panic(fmt.Sprintf("Line %d: found no value assigned to array %s in declaration statement", lineNum+1, id))
 	}
 	expression := strings.Trim(line[equalsCharIndex+1:], " ")
-	arrFound := parseArrayExpression(expression, expectedType.baseType, lineNum, currentScope)
-
-	if arrFound.dataType.baseType != expectedType.baseType {
-		panic(fmt.Sprintf("Line %d: expected array of type %v found array of type %v", lineNum+1, expectedType.baseType, arrFound.dataType.baseType))
-	}
-
-	if arrFound.dataType.dimensions[0] != expectedType.dimensions[0] {
-		panic(fmt.Sprintf("Line %d: expected array of length %d, found array of length %d", lineNum+1, arrFound.dataType.dimensions[0], expectedType.dimensions[0]))
-	}
+	arrFound := copyArray(parseArrayExpression(expression, expectedType.baseType, lineNum, currentScope), expectedType, lineNum)
 
 	arr := Array{
 		mut:        mut,
@@ -422,7 +451,7 @@ Loop:
 	}
 
 	num, err := strconv.Atoi(dimensions[0].transpile())
-	if err != nil { // integer literal -> we can check whether it is inside array bounds
+	if err == nil && arr.dataType.dimensions[0] != -1 { // integer literal -> we can check whether it is inside array bounds
 		if num > arr.dataType.dimensions[0]-1 { // zero-indexed
 			panic(fmt.Sprintf("Line %d: attempt to index element %d but array has size %d", lineNum+1, num, arr.dataType.dimensions[0]))
 		}
@@ -450,6 +479,9 @@ func parseArrayAssignment(line string, lineNum int, currentScope *Scope) ArrayAs
 	if !arr.mut {
 		panic(fmt.Sprintf("Line %d: attempt to assign new value to immutable array %s", lineNum+1, arr.identifier))
 	}
+	if _, operator, _ := splitAssignment(line, lineNum); operator != "=" {
+		panic(fmt.Sprintf("Line %d: %s cannot be used with array %s", lineNum+1, operator, arr.identifier))
+	}
 
 	if words[1] != "=" {
 		panic(fmt.Sprintf("Line %d: invalid assignment: equals sign must come directly after variable", lineNum+1))
@@ -476,15 +508,7 @@ func parseArrayAssignment(line string, lineNum int, currentScope *Scope) ArrayAs
 
 	expr := line[exprStart:]
 
-	arrayExpr := parseArrayExpression(expr, expectedType, lineNum, currentScope)
-
-	if arrayExpr.dataType.baseType != expectedType {
-		panic(fmt.Sprintf("Line %d: attempt tp assign value of base type %v to array of base type %v", lineNum+1, arrayExpr.dataType.baseType, expectedType))
-	}
-
-	if len(arrayExpr.dataType.dimensions) != len(arr.dataType.dimensions) {
-		panic(fmt.Sprintf("Line %d: attempt to assign array value with %d dimensions to array with %d dimensions", lineNum+1, len(arrayExpr.dataType.dimensions), len(arr.dataType.dimensions)))
-	}
+	arrayExpr := copyArray(parseArrayExpression(expr, expectedType, lineNum, currentScope), arr.dataType, lineNum)
 
 	return ArrayAssignment{
 		arr:  arr,
@@ -494,26 +518,26 @@ func parseArrayAssignment(line string, lineNum int, currentScope *Scope) ArrayAs
 
 func parseArrayIndexAssignment(line string, lineNum int, currentScope *Scope) ArrayIndexAssignment {
 	// parse assignment to index of array
-	words := strings.Fields(line)
+	left, operator, expr := splitAssignment(line, lineNum)
 
 	var identifier string
 
 Loop:
-	for i := 0; i < len(words[0]); i++ {
-		switch words[0][i] {
+	for i := 0; i < len(left); i++ {
+		switch left[i] {
 		case '[':
 			break Loop
 		default:
-			identifier += string(words[0][i])
+			identifier += string(left[i])
 		}
 	}
 
 	var leftSideType primitiveType
 	arr, ok := (*currentScope).arrays[identifier]
 
-	indexing := parseArrayIndexing(words[0], lineNum, currentScope)
+	indexing := parseArrayIndexing(left, lineNum, currentScope)
 
-	leftSideType = indexing.dataType.baseType
+	leftSideType = elementType(indexing.dataType)
 	if ok {
 		if !arr.mut {
 			panic(fmt.Sprintf("Line %d: attempt to assign new value to element of immutable array %s", lineNum+1, identifier))
@@ -522,29 +546,34 @@ Loop:
 		panic(fmt.Sprintf("Line %d: attempted assignment to array %s not in scope", lineNum+1, identifier))
 	}
 
-	var exprStart int
-
-	for i := 0; i < len(line); i++ {
-		if line[i] == '=' {
-			exprStart = i + 1
-			break
+	if isIncrement(line) {
+		checkAssignmentOperator(operator, leftSideType, leftSideType, lineNum)
+		return ArrayIndexAssignment{
+			arrIndex: indexing,
+			operator: operator,
 		}
 	}
 
-	if exprStart == 0 || exprStart == len(line)-1 {
-		panic(fmt.Sprintf("Line %d: found no expression in assignment to variable %s", lineNum+1, identifier))
+	if isTuple(leftSideType) || isArray(leftSideType) {
+		// element is a tuple or a row of the array so the value could be a literal
+		checkAssignmentOperator(operator, leftSideType, leftSideType, lineNum)
+		return ArrayIndexAssignment{
+			arrIndex: indexing,
+			value:    parseTypedValue(expr, leftSideType, lineNum, currentScope),
+			operator: operator,
+		}
 	}
-
-	expr := line[exprStart:]
-	rightSide := parseExpression(expr, lineNum, currentScope)
+	rightSide := parseContextualExpression(expr, leftSideType, lineNum, currentScope)
 	if rightSide.dataType != leftSideType {
 		// should panic in parsing anyway, but maybe I'll change something later and forget
 		panic(fmt.Sprintf("Line %d: data type of right hand side of expression does not match data type of left hand side", lineNum+1))
 	}
+	checkAssignmentOperator(operator, leftSideType, rightSide.dataType, lineNum)
 
 	return ArrayIndexAssignment{
 		arrIndex: indexing,
 		value:    rightSide,
+		operator: operator,
 	}
 }
 
@@ -552,9 +581,16 @@ func parseArrayExpression(expr string, expectedType primitiveType, lineNum int,
 	// parses any array expression
 	trimmed := strings.Trim(expr, " ")
 
+	if trimmed[0] == '[' && !isArray(expectedType) {
+		rows := splitTopLevel(trimmed[1:len(trimmed)-1], ',')
+		if len(rows) != 0 && (rows[0][0] == '[' || isArrayValue(rows[0], lineNum, currentScope)) {
+			return parseArrayRows(rows, expectedType, lineNum, currentScope)
+		}
+	}
+
 	if trimmed[0] == '[' {
 		// array literals
-		T := parseBaseArray(expr, expectedType, currentScope, lineNum)
+		T := parseBaseArray(trimmed, expectedType, currentScope, lineNum)
 		return ArrayExpression{
 			stringValue: expr,
 			dataType: ArrayType{
@@ -571,37 +607,275 @@ func parseArrayExpression(expr string, expectedType primitiveType, lineNum int,
 			dataType:    arr.dataType,
 		}
 	}
-	currentString := ""
-Loop:
-	for i := 0; i < len(expr); i++ {
-		switch expr[i] {
-		case '(':
-			break Loop
-		default:
-			currentString += string(expr[i])
+
+	if isArraySlice(trimmed, currentScope) {
+		slice := parseArraySlice(trimmed, lineNum, currentScope)
+		return ArrayExpression{
+			stringValue: expr,
+			dataType:    slice.dataType,
+			node:        slice,
+		}
+	}
+
+	if isPropagation(trimmed) {
+		// Option or Result carrying an array
+		propagation := parsePropagation(trimmed, lineNum, currentScope)
+		T, ok := propagation.dataType.defined()
+		if !ok || T.kind != ArrayKind {
+			panic(fmt.Sprintf("Line %d: expected array but found value of type %v", lineNum+1, propagation.dataType))
+		}
+		return ArrayExpression{
+			stringValue: expr,
+			dataType:    T.array,
+			node:        propagation,
 		}
 	}
-	currentString = strings.Trim(currentString, " ")
-	if fn, ok := (*currentScope).functions[currentString]; ok {
-		// functions returning derived type
-		if fn.returnDomain == derived {
-			_ = parseFunctionCall(strings.Trim(expr, " "), lineNum, currentScope)
-			//check that function call is actually valid
+
+	if open := strings.Index(trimmed, "("); open > 0 {
+		// functions returning arrays
+		if _, ok := lookupFunction(trimmed[:open], currentScope); ok {
+			fnCall := parseFunctionCall(trimmed, lineNum, currentScope)
+			T, ok := fnCall.dataType.defined()
+			if !ok || T.kind != ArrayKind {
+				panic(fmt.Sprintf("Line %d: expected array but function %s returns type %v", lineNum+1, fnCall.functionName, fnCall.dataType))
+			}
 			return ArrayExpression{
 				stringValue: expr,
-				dataType:    fn.derivedReturnType,
+				dataType:    T.array,
+				node:        fnCall,
 			}
 		}
 	}
+
+	if rootEnd := strings.IndexAny(trimmed, ".["); rootEnd > 0 && isInScope(trimmed[:rootEnd], currentScope) {
+		// row of an array, or an array inside a tuple or record e.g. grid[0] or p.position
+		access := parseMemberAccess(trimmed, lineNum, currentScope)
+		T, ok := access.dataType.defined()
+		if !ok || T.kind != ArrayKind {
+			panic(fmt.Sprintf("Line %d: expected array but found value of type %v", lineNum+1, access.dataType))
+		}
+		return ArrayExpression{
+			stringValue: expr,
+			dataType:    T.array,
+			node:        access,
+		}
+	}
 	panic(fmt.Sprintf("Line %d: invalid array expression", lineNum+1))
 }
 
+func parseArrayRows(rows []string, expectedType primitiveType, lineNum int, currentScope *Scope) ArrayExpression {
+	// literal of a multi-dimensional array e.g. [[1, 2], [3, 4]] where each element is a row
+	var values []Transpileable
+	var rowType ArrayType
+	for i, row := range rows {
+		r := parseArrayExpression(row, expectedType, lineNum, currentScope)
+		if i == 0 {
+			rowType = r.dataType
+		} else if r.dataType.baseType != rowType.baseType || !slices.Equal(r.dataType.dimensions, rowType.dimensions) {
+			panic(fmt.Sprintf("Line %d: rows of multi-dimensional array must all have the same type, found %v and %v", lineNum+1, arrayTypeID(rowType), arrayTypeID(r.dataType)))
+		}
+		values = append(values, copyArray(r, rowType, lineNum))
+	}
+	return ArrayExpression{
+		stringValue: strings.Join(rows, ", "),
+		dataType: ArrayType{
+			baseType:   rowType.baseType,
+			dimensions: append([]int{len(rows)}, rowType.dimensions...),
+		},
+		literal: BaseArray{
+			values:   values,
+			dataType: arrayTypeID(rowType),
+			length:   len(rows),
+		},
+	}
+}
+
+func isArraySlice(token string, currentScope *Scope) bool {
+	open := strings.Index(token, "[")
+	if open <= 0 || !strings.HasSuffix(token, "]") {
+		return false
+	}
+	if _, ok := (*currentScope).arrays[token[:open]]; !ok {
+		return false
+	}
+	end := strings.Index(token[open:], "]") + open
+	return end == len(token)-1 && strings.Contains(token[open:end], "..")
+}
+
+func parseArraySlice(token string, lineNum int, currentScope *Scope) ArraySlice {
+	// xs[start..end] includes the element at start but not the one at end
+	open := strings.Index(token, "[")
+	arr := (*currentScope).arrays[token[:open]]
+	bounds := strings.Split(token[open+1:len(token)-1], "..")
+	if len(bounds) != 2 {
+		panic(fmt.Sprintf("Line %d: slice of array must have the form %s[start..end]", lineNum+1, arr.identifier))
+	}
+
+	length := arr.dataType.dimensions[0] // -1 for parameters with a length parameter
+	start, end := 0, length
+	slice := ArraySlice{
+		arrayID: arr.identifier,
+	}
+	if len(bounds[0]) != 0 {
+		slice.start = parseExpression(bounds[0], lineNum, currentScope)
+		start = sliceBound(slice.start, lineNum)
+	}
+	if len(bounds[1]) != 0 {
+		slice.end = parseExpression(bounds[1], lineNum, currentScope)
+		end = sliceBound(slice.end, lineNum)
+	}
+
+	dimensions := slices.Clone(arr.dataType.dimensions)
+	dimensions[0] = -1
+	if start != -1 && end != -1 {
+		// both ends are integer literals so the length can be checked now
+		if start > end {
+			panic(fmt.Sprintf("Line %d: slice of array %s starts at %d which is after its end at %d", lineNum+1, arr.identifier, start, end))
+		}
+		if length != -1 && end > length {
+			panic(fmt.Sprintf("Line %d: attempt to slice up to element %d but array has size %d", lineNum+1, end, length))
+		}
+		dimensions[0] = end - start
+	}
+
+	slice.dataType = ArrayType{
+		baseType:   arr.dataType.baseType,
+		dimensions: dimensions,
+	}
+	return slice
+}
+
+func sliceBound(bound Expression, lineNum int) int {
+	// returns the value of an integer literal, or -1 if it isn't known until the program runs
+	if bound.dataType != Int {
+		panic(fmt.Sprintf("Line %d: attempt to slice array with expression evaluating to non-integer type %v", lineNum+1, bound.dataType))
+	}
+	n, err := strconv.Atoi(bound.transpile())
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func isInScope(identifier string, currentScope *Scope) bool {
+	_, isVar := (*currentScope).vars[identifier]
+	_, isArray := (*currentScope).arrays[identifier]
+	_, isTuple := (*currentScope).tuples[identifier]
+	return isVar || isArray || isTuple
+}
+
+func (A ArrayExpression) isSlice() bool {
+	// whether the value is a slice in Go rather than an array
+	if _, ok := A.node.(ArraySlice); ok {
+		return true
+	}
+	return A.dataType.dimensions[0] == -1
+}
+
+// array passed to a parameter which takes arrays of any length
+type SliceArgument struct {
+	array  ArrayExpression
+	spread bool // passed to a variadic parameter e.g. xs...
+}
+
+func (A ArrayExpression) asSlice() string {
+	// used to pass arrays to parameters which take arrays of any length
+	if A.isSlice() {
+		return A.transpile()
+	}
+	if len(A.literal.values) > 0 {
+		// written as a slice literal instead e.g. []float64{1.5, 2.5}
+		return "[]" + strings.TrimPrefix(A.literal.transpile(), fmt.Sprintf("[%d]", A.literal.length))
+	}
+	if _, ok := A.node.(FunctionCall); !ok {
+		return A.transpile() + "[:]"
+	}
+	// Go can't slice an array returned by a function, so it is passed through another function first
+	sliceType := ArrayType{
+		baseType:   A.dataType.baseType,
+		dimensions: append([]int{-1}, A.dataType.dimensions[1:]...),
+	}
+	return "func(a " + A.dataType.goType() + ") " + sliceType.goType() + " { return a[:] }(" + A.transpile() + ")"
+}
+
+func copyArray(A ArrayExpression, T ArrayType, lineNum int) ArrayExpression {
+	// checks that an array expression can be copied into an array of type T
+	// arrays are copied when they are assigned in Go, but slices have to be converted
+	if A.dataType.baseType != T.baseType {
+		panic(fmt.Sprintf("Line %d: expected array of type %v but found array of type %v", lineNum+1, T.baseType, A.dataType.baseType))
+	}
+	if len(A.dataType.dimensions) != len(T.dimensions) {
+		panic(fmt.Sprintf("Line %d: expected array with %d dimensions but found array with %d dimensions", lineNum+1, len(T.dimensions), len(A.dataType.dimensions)))
+	}
+	for i, d := range T.dimensions {
+		if i == 0 && (d == -1 || A.dataType.dimensions[0] == -1) {
+			// length isn't known until the program runs
+			continue
+		}
+		if A.dataType.dimensions[i] != d {
+			panic(fmt.Sprintf("Line %d: expected array of length %d but found array of length %d", lineNum+1, d, A.dataType.dimensions[i]))
+		}
+	}
+	if A.isSlice() && T.dimensions[0] != -1 {
+		A.conversion = T
+	}
+	return A
+}
+
+func elementType(A ArrayType) primitiveType {
+	// type of the values found by indexing an array, which are rows for arrays with more than one dimension
+	if len(A.dimensions) > 1 {
+		return arrayTypeID(ArrayType{
+			baseType:   A.baseType,
+			dimensions: A.dimensions[1:],
+		})
+	}
+	return A.baseType
+}
+
+func isArrayValue(token string, lineNum int, currentScope *Scope) bool {
+	// whether a single token is an array e.g. grid, grid[0] or xs[1..3]
+	rootEnd := strings.IndexAny(token, ".[")
+	if rootEnd == -1 {
+		_, ok := (*currentScope).arrays[token]
+		return ok
+	}
+	if rootEnd == 0 || !isInScope(token[:rootEnd], currentScope) {
+		return false
+	}
+	if isArraySlice(token, currentScope) {
+		return true
+	}
+	return isArray(parseMemberAccess(token, lineNum, currentScope).dataType)
+}
+
+func checkComparable(T primitiveType, lineNum int) {
+	// arrays and tuples can be compared with == and != if all of their elements can
+	d, _ := T.defined()
+	switch {
+	case isFunction(T):
+		panic(fmt.Sprintf("Line %d: functions cannot be compared", lineNum+1))
+	case containsFunction(T):
+		panic(fmt.Sprintf("Line %d: values of type %v cannot be compared because they contain a function", lineNum+1, T))
+	case isArray(T):
+		if slices.Contains(d.array.dimensions, -1) {
+			panic(fmt.Sprintf("Line %d: arrays with a length parameter cannot be compared", lineNum+1))
+		}
+		checkComparable(d.array.baseType, lineNum)
+	case isTuple(T):
+		for _, element := range d.elements {
+			checkComparable(element, lineNum)
+		}
+	}
+}
+
 func parseMultiLineArrayExpression(lines []string, lineNum int, expectedType primitiveType, currentScope *Scope) ArrayExpression {
 	// parses blocks evaluating to array expression
 	varsCopy := make(map[string]Variable) // used to later restore currentScope.vars to original
 	// so that when variable declarations are actually parsed they don't throw an already declared error
 	arraysCopy := make(map[string]Array)
 	tuplesCopy := make(map[string]Tuple)
+	functionsCopy := make(map[string]Function) // local functions
 
 	for k, v := range (*currentScope).vars {
 		varsCopy[k] = v
@@ -614,6 +888,9 @@ func parseMultiLineArrayExpression(lines []string, lineNum int, expectedType pri
 	for k, v := range (*currentScope).tuples {
 		tuplesCopy[k] = v
 	}
+	for k, v := range (*currentScope).functions {
+		functionsCopy[k] = v
+	}
 	// copy as reference types
 
 	bracketCount := 0
@@ -624,6 +901,7 @@ func parseMultiLineArrayExpression(lines []string, lineNum int, expectedType pri
 
 	for n := lineNum; n < len(lines); n++ {
 		line := lines[n]
+		depth := bracketCount // before the line
 		for i := 0; i < len(line); i++ {
 			if line[i] == '{' {
 				bracketCount++
@@ -634,13 +912,19 @@ func parseMultiLineArrayExpression(lines []string, lineNum int, expectedType pri
 		if bracketCount == 0 {
 			break
 		}
-		if bracketCount != 1 {
+		if depth != 1 {
 			continue
 		}
 		if getItemType(lines[n], n, currentScope) == VariableDeclaration {
 			_ = parseVariableDeclaration(lines[n], n, currentScope)
 		} else if getItemType(lines[n], n, currentScope) == ArrDeclaration {
 			_ = parseArrayDeclaration(lines[n], n, currentScope)
+		} else if getItemType(lines[n], n, currentScope) == DestructuringDeclaration {
+			_ = parseDestructuringDeclaration(lines[n], n, currentScope)
+		} else if getItemType(lines[n], n, currentScope) == ValueBlockItem && strings.Fields(lines[n])[0] == "let" {
+			declareValue(parseValueDeclaration(lines[n], n, currentScope), currentScope)
+		} else if getItemType(lines[n], n, currentScope) == FunctionDeclaration {
+			declareLocalFunction(lines, n, currentScope)
 		}
 		if exprCount >= 1 {
 			panic(fmt.Sprintf("Line %d: found dead code after expression in multi-line expression", n+1))
@@ -661,11 +945,12 @@ func parseMultiLineArrayExpression(lines []string, lineNum int, expectedType pri
 	(*currentScope).vars = varsCopy
 	(*currentScope).arrays = arraysCopy
 	(*currentScope).tuples = tuplesCopy
+	(*currentScope).functions = functionsCopy
 
 	return to_return
 }
 
-func findExpectedType(lines []string, lineNum int) primitiveType {
+func findExpectedType(lines []string, lineNum int) ArrayType {
 	// needs to loop backwards through lines to find function declaration with return type typeAnnotation
 	// doesn't really need error checking as function declaration will already have been parsed
 	for i := lineNum; i >= 0; i-- {
@@ -673,10 +958,9 @@ func findExpectedType(lines []string, lineNum int) primitiveType {
 		if len(words) == 0 {
 			continue
 		}
-		if words[0] == "function" {
-			typeAnnotation := words[len(words)-3] // not = or {
-			expectedType := parseArrayType(typeAnnotation, i)
-			return expectedType.baseType
+		if words[0] == "function" && findScopeEnd(lines, i) >= lineNum {
+			// skips local functions which end before the line
+			return parseArrayType(returnTypeAnnotation(lines[i]), i)
 		}
 	}
 	panic("in theory should never panic here lol")
@@ -0,0 +1,611 @@
+package transpiler
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// constant implementation in Go
+/**
+const G: float = 6.674e-11
+const HALF_G: float = G / 2.0
+
+const G float64 = 6.674e-11
+const HALF_G float64 = 3.337e-11
+
+// the value is evaluated by the transpiler, so it can only use
+// literals, operators and other constants
+// Go also works out expressions such as 255u8 + 1u8 or u8(300) while compiling,
+// so they are evaluated in the same way to check that they fit in their type
+*/
+
+type ConstantDeclaration struct {
+	identifier string
+	dataType   primitiveType
+	value      string // evaluated value as a Go literal
+}
+
+// value of a constant with one of the sized numeric types e.g. 200u8
+type sizedConstant struct {
+	integer  *big.Int // i32, i64, u8 and u64
+	float    float64  // f32
+	dataType primitiveType
+}
+
+var constants map[string]any // values of the constants declared so far
+
+var globalConstants map[string]bool // every constant in the program, including ones declared later
+
+// evaluates the tokens of a constant expression in order of precedence
+type constantEvaluator struct {
+	tokens      []string
+	nodes       map[int]Transpileable // ** and div
+	pos         int
+	lineNum     int
+	description string // e.g. value of constant G, used in errors
+}
+
+func parseConstantDeclaration(line string, lineNum int, currentScope *Scope) ConstantDeclaration {
+	words := strings.Fields(line)
+	if words[0] != "const" {
+		panic("parseConstantDeclaration() called without const keyword")
+	}
+	if len(words) < 4 {
+		panic(fmt.Sprintf("Line %d: expected constant declaration of the form const NAME: type = value", lineNum+1))
+	}
+
+	identifier := parseIdentifier(words[1], lineNum)
+	if _, ok := currentScope.vars[identifier]; ok {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, identifier))
+	} else if _, ok := currentScope.functions[identifier]; ok {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, identifier))
+	}
+
+	annotation := typeAnnotation(line)
+	if len(annotation) == 0 {
+		panic(fmt.Sprintf("Line %d: expected token '=' after type annotation", lineNum+1))
+	}
+	T := readType(annotation, lineNum)
+	if !constantType(T) {
+		panic(fmt.Sprintf("Line %d: constants must have a numeric type, bool, byte or string but found type %v", lineNum+1, T))
+	}
+
+	if later, ok := laterConstant(line[strings.Index(line, "=")+1:]); ok {
+		// constants are evaluated in the order they are declared
+		if later == identifier {
+			panic(fmt.Sprintf("Line %d: constant %s cannot be used in its own value", lineNum+1, identifier))
+		}
+		panic(fmt.Sprintf("Line %d: %s is not a constant declared before %s", lineNum+1, later, identifier))
+	}
+	value := evaluateConstant(line[strings.Index(line, "=")+1:], T, "value of constant "+identifier, lineNum, currentScope)
+
+	if constants == nil {
+		constants = make(map[string]any)
+	}
+	constants[identifier] = value
+	currentScope.vars[identifier] = Variable{
+		identifier: identifier,
+		dataType:   T,
+	}
+
+	return ConstantDeclaration{
+		identifier: identifier,
+		dataType:   T,
+		value:      constantLiteral(value),
+	}
+}
+
+func laterConstant(value string) (string, bool) {
+	// finds a constant used in value which has not been evaluated yet
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '"' || value[i] == 39:
+			// skip string and byte literals
+			quote := value[i]
+			for i++; i < len(value) && value[i] != quote; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+			}
+		case parseCharType(value[i]) != other:
+			start := i
+			for i < len(value) && parseCharType(value[i]) != other {
+				i++
+			}
+			word := value[start:i]
+			if _, evaluated := constants[word]; globalConstants[word] && !evaluated {
+				return word, true
+			}
+			i--
+		}
+	}
+	return "", false
+}
+
+func evaluateConstant(value string, T primitiveType, description string, lineNum int, currentScope *Scope) any {
+	// evaluates an expression of type T which can only use literals, operators and other constants
+	expression := parseExpression(value, lineNum, currentScope)
+	if expression.dataType != T {
+		panic(fmt.Sprintf("Line %d: expected type %v because of type annotation, found type %v", lineNum+1, T, expression.dataType))
+	}
+
+	for i, item := range expression.items {
+		if node, ok := expression.nodes[i]; ok && !constantNode(node) {
+			panic(fmt.Sprintf("Line %d: %s must be a constant expression, but %s is not a constant", lineNum+1, description, item))
+		}
+	}
+	return evaluateExpression(expression, description, lineNum)
+}
+
+func evaluateExpression(expression Expression, description string, lineNum int) any {
+	evaluator := constantEvaluator{
+		tokens:      expression.items,
+		nodes:       expression.nodes,
+		lineNum:     lineNum,
+		description: description,
+	}
+	evaluated := evaluator.or()
+	if evaluator.pos != len(evaluator.tokens) {
+		panic(fmt.Sprintf("Line %d: unexpected token %s in %s", lineNum+1, evaluator.tokens[evaluator.pos], description))
+	}
+	return evaluated
+}
+
+func constantType(T primitiveType) bool {
+	switch T {
+	case Int, Float, Bool, Byte, String, I32, I64, U8, U64, F32:
+		return true
+	}
+	return false
+}
+
+func constantNode(node Transpileable) bool {
+	// nodes which the transpiler can work out the value of
+	switch n := node.(type) {
+	case BinaryOperation:
+		return true
+	case NumericLiteral:
+		return n.dataType != Complex
+	case Conversion:
+		return constantType(n.dataType) && n.dataType != Bool && n.dataType != String
+	}
+	return false
+}
+
+func isConstantExpression(expression Expression) bool {
+	// whether an expression only uses literals, operators and constants, so that Go works it out while compiling
+	for i, item := range expression.items {
+		if node, ok := expression.nodes[i]; ok {
+			if !constantNode(node) {
+				return false
+			}
+			switch n := node.(type) {
+			case BinaryOperation:
+				if !isConstantExpression(n.left) || !isConstantExpression(n.right) {
+					return false
+				}
+			case Conversion:
+				if !isConstantExpression(n.value) {
+					return false
+				}
+			}
+			continue
+		}
+		_, operator := binaryOperators()[item]
+		_, constant := constants[item]
+		switch {
+		case operator, constant, item == "(", item == ")", item == "!", item == "true", item == "false":
+		case item[0] == '"' || item[0] == 39 || parseCharType(item[0]) == number:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func constantLiteral(value any) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return floatLiteral(v, 64)
+	case sizedConstant:
+		if v.integer != nil {
+			return v.integer.String()
+		}
+		return floatLiteral(v.float, 32)
+	case bool:
+		return strconv.FormatBool(v)
+	case byte:
+		return string([]byte{39, v, 39})
+	default:
+		return string([]byte{34}) + v.(string) + string([]byte{34})
+	}
+}
+
+func suffixedLiteral(value any) string {
+	// value as a literal in Stella, which needs a suffix if it has a sized numeric type
+	literal := constantLiteral(value)
+	if v, ok := value.(sizedConstant); ok {
+		for suffix, T := range numericSuffixes() {
+			if T == v.dataType {
+				literal += suffix
+			}
+		}
+	}
+	return literal
+}
+
+func floatLiteral(value float64, bits int) string {
+	literal := strconv.FormatFloat(value, 'g', -1, bits)
+	if !strings.ContainsAny(literal, ".e") {
+		literal += ".0" // so that it still looks like a float
+	}
+	return literal
+}
+
+func (c *constantEvaluator) peek() string {
+	if c.pos == len(c.tokens) {
+		return ""
+	}
+	return c.tokens[c.pos]
+}
+
+func (c *constantEvaluator) or() any {
+	value := c.and()
+	for c.peek() == "||" {
+		c.pos++
+		right := c.and()
+		value = value.(bool) || right.(bool)
+	}
+	return value
+}
+
+func (c *constantEvaluator) and() any {
+	value := c.comparison()
+	for c.peek() == "&&" {
+		c.pos++
+		right := c.comparison()
+		value = value.(bool) && right.(bool)
+	}
+	return value
+}
+
+func (c *constantEvaluator) comparison() any {
+	value := c.sum()
+	for {
+		operator := c.peek()
+		if _, ok := comparativeOperators()[operator]; !ok {
+			return value
+		}
+		c.pos++
+		value = compareConstants(operator, value, c.sum())
+	}
+}
+
+func (c *constantEvaluator) sum() any {
+	value := c.product()
+	for c.peek() == "+" || c.peek() == "-" || c.peek() == "|" || c.peek() == "^" {
+		operator := c.peek()
+		c.pos++
+		value = c.arithmetic(operator, value, c.product())
+	}
+	return value
+}
+
+func (c *constantEvaluator) product() any {
+	value := c.unary()
+	for slices.Contains([]string{"*", "/", "%", "<<", ">>", "&"}, c.peek()) {
+		operator := c.peek()
+		c.pos++
+		value = c.arithmetic(operator, value, c.unary())
+	}
+	return value
+}
+
+func (c *constantEvaluator) unary() any {
+	switch c.peek() {
+	case "-":
+		c.pos++
+		switch v := c.unary().(type) {
+		case int:
+			if v == math.MinInt {
+				panic(fmt.Sprintf("Line %d: integer overflow in %s", c.lineNum+1, c.description))
+			}
+			return -v
+		case sizedConstant:
+			if v.integer != nil {
+				return c.sizedInteger(new(big.Int).Neg(v.integer), v.dataType)
+			}
+			return sizedConstant{float: -v.float, dataType: v.dataType}
+		default:
+			return -v.(float64)
+		}
+	case "!":
+		c.pos++
+		return !c.unary().(bool)
+	}
+	return c.primary()
+}
+
+func (c *constantEvaluator) primary() any {
+	token := c.peek()
+	c.pos++
+	switch node := c.nodes[c.pos-1].(type) {
+	case BinaryOperation:
+		left := constantEvaluator{tokens: node.left.items, nodes: node.left.nodes, lineNum: c.lineNum, description: c.description}
+		right := constantEvaluator{tokens: node.right.items, nodes: node.right.nodes, lineNum: c.lineNum, description: c.description}
+		return c.arithmetic(node.operator, left.or(), right.or())
+	case NumericLiteral:
+		if strings.ContainsAny(node.value, ".eE") {
+			v, _ := strconv.ParseFloat(node.value, 64)
+			return c.convert(v, node.dataType)
+		}
+		v, _ := new(big.Int).SetString(node.value, 10)
+		return c.convert(v, node.dataType)
+	case Conversion:
+		value := constantEvaluator{tokens: node.value.items, nodes: node.value.nodes, lineNum: c.lineNum, description: c.description}
+		return c.convert(value.or(), node.dataType)
+	}
+	switch {
+	case token == "(":
+		value := c.or()
+		c.pos++ // closing bracket, already checked by parseExpression()
+		return value
+	case token == "true" || token == "false":
+		return token == "true"
+	case token[0] == '"':
+		return token[1 : len(token)-1]
+	case token[0] == 39:
+		return token[1]
+	case parseCharType(token[0]) == number:
+		if getValType(token, c.lineNum) == Int {
+			v, err := strconv.Atoi(token)
+			if err != nil {
+				panic(fmt.Sprintf("Line %d: integer %s is too large", c.lineNum+1, token))
+			}
+			return v
+		}
+		v, _ := strconv.ParseFloat(token, 64)
+		return c.finite(v)
+	}
+	value, ok := constants[token]
+	if !ok {
+		panic(fmt.Sprintf("Line %d: %s must be a constant expression, but %s is not a constant", c.lineNum+1, c.description, token))
+	}
+	return value
+}
+
+func (c *constantEvaluator) arithmetic(operator string, left, right any) any {
+	// types have already been checked by parseExpression()
+	switch l := left.(type) {
+	case int:
+		// worked out exactly so that overflow can be found instead of wrapping around
+		x := c.integerArithmetic(operator, big.NewInt(int64(l)), integerValue(right))
+		if !x.IsInt64() {
+			panic(fmt.Sprintf("Line %d: integer overflow in %s", c.lineNum+1, c.description))
+		}
+		return int(x.Int64())
+	case sizedConstant:
+		if l.integer != nil {
+			return c.sizedInteger(c.integerArithmetic(operator, l.integer, integerValue(right)), l.dataType)
+		}
+		return c.sizedFloat(c.floatArithmetic(operator, l.float, right.(sizedConstant).float), l.dataType)
+	case float64:
+		return c.floatArithmetic(operator, l, right.(float64))
+	default:
+		// only + can be used with strings
+		return l.(string) + right.(string)
+	}
+}
+
+func integerValue(value any) *big.Int {
+	// the number of bits shifted by can have a different integer type
+	if v, ok := value.(sizedConstant); ok {
+		return v.integer
+	}
+	return big.NewInt(int64(value.(int)))
+}
+
+func (c *constantEvaluator) integerArithmetic(operator string, l, r *big.Int) *big.Int {
+	x := new(big.Int)
+	switch operator {
+	case "+":
+		x.Add(l, r)
+	case "-":
+		x.Sub(l, r)
+	case "*":
+		x.Mul(l, r)
+	case "**":
+		if r.Sign() < 0 {
+			panic(fmt.Sprintf("Line %d: negative exponent in %s", c.lineNum+1, c.description))
+		}
+		exponent := r
+		if l.CmpAbs(big.NewInt(1)) > 0 && r.Cmp(big.NewInt(64)) > 0 {
+			exponent = big.NewInt(64) // already too large, so it doesn't need to be worked out exactly
+		}
+		x.Exp(l, exponent, nil)
+	case "&":
+		x.And(l, r)
+	case "|":
+		x.Or(l, r)
+	case "^":
+		x.Xor(l, r)
+	case "<<", ">>":
+		if r.Sign() < 0 {
+			panic(fmt.Sprintf("Line %d: negative shift in %s", c.lineNum+1, c.description))
+		}
+		// shifting further than 64 bits gives the same result as shifting 64 bits
+		shift := uint(64)
+		if r.Cmp(big.NewInt(64)) < 0 {
+			shift = uint(r.Int64())
+		}
+		if operator == ">>" {
+			x.Rsh(l, shift)
+		} else {
+			x.Lsh(l, shift)
+		}
+	default:
+		if r.Sign() == 0 {
+			panic(fmt.Sprintf("Line %d: division by zero in %s", c.lineNum+1, c.description))
+		}
+		// rounds towards zero in the same way as Go
+		if operator == "%" {
+			x.Rem(l, r)
+		} else {
+			x.Quo(l, r)
+		}
+	}
+	return x
+}
+
+func (c *constantEvaluator) floatArithmetic(operator string, l, r float64) float64 {
+	switch operator {
+	case "+":
+		return c.finite(l + r)
+	case "-":
+		return c.finite(l - r)
+	case "*":
+		return c.finite(l * r)
+	case "**":
+		return c.finite(math.Pow(l, r))
+	default:
+		if r == 0 {
+			panic(fmt.Sprintf("Line %d: division by zero in %s", c.lineNum+1, c.description))
+		}
+		if operator == "div" {
+			return c.finite(math.Trunc(l / r))
+		}
+		return c.finite(l / r)
+	}
+}
+
+func (c *constantEvaluator) convert(value any, T primitiveType) any {
+	// Go only allows a constant to be converted to a type which it fits in
+	var integer *big.Int
+	var float float64
+	switch v := value.(type) {
+	case int:
+		integer = big.NewInt(int64(v))
+	case byte:
+		integer = big.NewInt(int64(v))
+	case *big.Int:
+		integer = v
+	case float64:
+		float = v
+	case sizedConstant:
+		integer, float = v.integer, v.float
+	}
+
+	if T == Float || T == F32 {
+		if integer != nil {
+			float, _ = new(big.Float).SetInt(integer).Float64()
+		}
+		return c.sizedFloat(float, T)
+	}
+	if integer == nil {
+		if float != math.Trunc(float) {
+			panic(fmt.Sprintf("Line %d: %s would be truncated by converting it to type %v", c.lineNum+1, floatLiteral(float, 64), T))
+		}
+		integer, _ = new(big.Float).SetFloat64(float).Int(nil)
+	}
+	return c.sizedInteger(integer, T)
+}
+
+func integerRange(T primitiveType) (*big.Int, *big.Int) {
+	switch T {
+	case I32:
+		return big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)
+	case U8, Byte:
+		return big.NewInt(0), big.NewInt(math.MaxUint8)
+	case U64:
+		return big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)
+	default:
+		return big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)
+	}
+}
+
+func (c *constantEvaluator) sizedInteger(x *big.Int, T primitiveType) any {
+	if low, high := integerRange(T); x.Cmp(low) < 0 || x.Cmp(high) > 0 {
+		panic(fmt.Sprintf("Line %d: %s is out of range for type %v", c.lineNum+1, x.String(), T))
+	}
+	switch T {
+	case Int:
+		return int(x.Int64())
+	case Byte:
+		return byte(x.Int64())
+	}
+	return sizedConstant{integer: x, dataType: T}
+}
+
+func (c *constantEvaluator) sizedFloat(value float64, T primitiveType) any {
+	value = c.finite(value)
+	if T == Float {
+		return value
+	}
+	if math.Abs(value) > math.MaxFloat32 {
+		panic(fmt.Sprintf("Line %d: %s is out of range for type %v", c.lineNum+1, floatLiteral(value, 64), T))
+	}
+	return sizedConstant{float: float64(float32(value)), dataType: T}
+}
+
+func (c *constantEvaluator) finite(value float64) float64 {
+	// Go has no literal for infinity or NaN
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		panic(fmt.Sprintf("Line %d: %s is not a finite float", c.lineNum+1, c.description))
+	}
+	return value
+}
+
+func compareConstants(operator string, left, right any) bool {
+	_, sized := left.(sizedConstant)
+	switch {
+	case operator == "==" && !sized:
+		return left == right
+	case operator == "!=" && !sized:
+		return left != right
+	}
+
+	// ordered types, and sized numbers which are compared by value as the integer is a pointer
+	var comparison int
+	switch l := left.(type) {
+	case sizedConstant:
+		if r := right.(sizedConstant); l.integer != nil {
+			comparison = l.integer.Cmp(r.integer)
+		} else {
+			comparison = cmp.Compare(l.float, r.float)
+		}
+	case int:
+		comparison = cmp.Compare(l, right.(int))
+	case float64:
+		if r := right.(float64); l < r {
+			comparison = -1
+		} else if l > r {
+			comparison = 1
+		}
+	case byte:
+		comparison = int(l) - int(right.(byte))
+	default:
+		comparison = strings.Compare(l.(string), right.(string))
+	}
+
+	switch operator {
+	case "==":
+		return comparison == 0
+	case "!=":
+		return comparison != 0
+	case "<":
+		return comparison < 0
+	case ">":
+		return comparison > 0
+	case "<=":
+		return comparison <= 0
+	default:
+		return comparison >= 0
+	}
+}
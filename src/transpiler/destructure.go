@@ -0,0 +1,170 @@
+package transpiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tuple destructuring implementation in Go
+/**
+let (x, mut y, _): (int, int, float) = triple
+
+var _tuple0 tuple3[int, int, float64] = triple
+var x int = _tuple0.v0
+_ = x
+var y int = _tuple0.v1
+_ = y
+
+function length((x, y): (float, float)) -> float = {
+	...
+}
+
+func length(_param0 tuple2[float64, float64]) float64 {
+	var x float64 = _param0.v0
+	_ = x
+	var y float64 = _param0.v1
+	_ = y
+	...
+}
+
+// each name becomes a separate Go variable taken from the fields of the tuple
+*/
+
+type Destructuring struct {
+	tuple    string // identifier of the tuple the names are taken from
+	pattern  TuplePattern
+	names    []string // _ for positions which are ignored
+	mut      []bool
+	e        Transpileable // value being destructured, nil for parameters
+	dataType primitiveType
+}
+
+var destructuringCount int // used to give each destructured value a different name
+
+func isDestructuring(line string) bool {
+	words := strings.Fields(line)
+	if len(words) < 2 || words[0] != "let" {
+		return false
+	}
+	if words[1] == "mut" {
+		return len(words) > 2 && strings.HasPrefix(words[2], "(")
+	}
+	return strings.HasPrefix(words[1], "(")
+}
+
+func parseDestructuringDeclaration(line string, lineNum int, currentScope *Scope) Destructuring {
+	rest := strings.Trim(strings.TrimPrefix(strings.Trim(line, " "), "let"), " ")
+	if strings.HasPrefix(rest, "mut") {
+		panic(fmt.Sprintf("Line %d: mut must be written before each name which is mutable e.g. let (mut x, y)", lineNum+1))
+	}
+
+	d := parseDestructuring(rest, fmt.Sprintf("_tuple%d", destructuringCount), lineNum)
+	destructuringCount++
+
+	afterNames := rest[closingBracket(rest)+1:]
+	if !strings.Contains(afterNames, "=") {
+		panic(fmt.Sprintf("Line %d: expected token '=' after type annotation", lineNum+1))
+	}
+	d.e = parseTupleExpression(afterNames[strings.Index(afterNames, "=")+1:], d.pattern, lineNum, currentScope)
+	d.declare(lineNum, currentScope)
+	return d
+}
+
+func parseDestructuredParameter(param string, index int, lineNum int) Tuple {
+	// (x, y): (float, float)
+	d := parseDestructuring(param, fmt.Sprintf("_param%d", index), lineNum)
+	return Tuple{
+		identifier:    d.tuple,
+		pattern:       d.pattern,
+		destructuring: &d,
+	}
+}
+
+func parseDestructuring(s string, tuple string, lineNum int) Destructuring {
+	// reads the names and type annotation from the start of s e.g. (x, mut y): (int, int)
+	end := closingBracket(s)
+	if end == -1 {
+		panic(fmt.Sprintf("Line %d: names of destructured tuple opened with ( but never closed", lineNum+1))
+	}
+
+	afterNames := strings.Trim(s[end+1:], " ")
+	if len(afterNames) == 0 || afterNames[0] != ':' {
+		panic(fmt.Sprintf("Line %d: expected type annotation after names of destructured tuple", lineNum+1))
+	}
+	annotation := afterNames[1:]
+	if equals := strings.Index(annotation, "="); equals != -1 {
+		annotation = annotation[:equals]
+	}
+	annotation = expandAlias(annotation)
+	if !strings.HasPrefix(annotation, "(") || isFunctionType(annotation) {
+		panic(fmt.Sprintf("Line %d: cannot destructure value of type %s because it is not a tuple", lineNum+1, annotation))
+	}
+	pattern := parseTuplePattern(annotation, lineNum)
+
+	names := splitTopLevel(s[1:end], ',')
+	if len(names) != len(pattern.dataTypes) {
+		panic(fmt.Sprintf("Line %d: tuple of type %v has %d elements but %d names were given", lineNum+1, tupleType(pattern), len(pattern.dataTypes), len(names)))
+	}
+
+	d := Destructuring{
+		tuple:    tuple,
+		pattern:  pattern,
+		dataType: tupleType(pattern),
+	}
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		if strings.HasPrefix(name, "(") {
+			panic(fmt.Sprintf("Line %d: nested tuples cannot be destructured, give the inner tuple a name instead", lineNum+1))
+		}
+		words := strings.Fields(name)
+		mut := len(words) == 2 && words[0] == "mut"
+		if mut {
+			name = words[1]
+		} else if len(words) != 1 {
+			panic(fmt.Sprintf("Line %d: invalid name %s in destructured tuple", lineNum+1, name))
+		}
+
+		if name != "_" {
+			name = parseIdentifier(name+":", lineNum)
+			if _, ok := seen[name]; ok {
+				panic(fmt.Sprintf("Line %d: %s bound more than once in destructured tuple", lineNum+1, name))
+			}
+			seen[name] = struct{}{}
+		} else if mut {
+			panic(fmt.Sprintf("Line %d: ignored position _ cannot be mutable", lineNum+1))
+		}
+		d.names = append(d.names, name)
+		d.mut = append(d.mut, mut)
+	}
+	return d
+}
+
+func (d Destructuring) declare(lineNum int, currentScope *Scope) {
+	// adds each name to the scope as a variable, array or tuple depending on its type
+	for i, name := range d.names {
+		if name == "_" {
+			continue
+		}
+		if _, v := currentScope.vars[name]; v {
+			panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, name))
+		} else if _, f := currentScope.functions[name]; f {
+			panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, name))
+		} else if _, a := currentScope.arrays[name]; a {
+			panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, name))
+		} else if _, t := currentScope.tuples[name]; t {
+			panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, name))
+		}
+
+		T := d.pattern.dataTypes[i]
+		switch {
+		case isArray(T):
+			a, _ := T.defined()
+			currentScope.arrays[name] = Array{identifier: name, dataType: a.array, mut: d.mut[i]}
+		case isTuple(T):
+			t, _ := T.defined()
+			currentScope.tuples[name] = Tuple{identifier: name, pattern: TuplePattern{dataTypes: t.elements}, mut: d.mut[i]}
+		default:
+			currentScope.vars[name] = Variable{identifier: name, dataType: T, mut: d.mut[i]}
+		}
+	}
+}
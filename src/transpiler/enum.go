@@ -0,0 +1,220 @@
+package transpiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// enum implementation in Go
+/**
+enum Cell { Empty, X, O }
+
+type Cell int
+const (
+	Cell_Empty Cell = iota
+	Cell_X
+	Cell_O
+)
+
+// enums where at least one variant carries a payload become a struct
+// with a tag and one field for each value carried by each variant
+
+enum Shape = Circle(float) | Rect(float, float)
+
+type Shape struct {
+	tag    int
+	Circle_0 float64
+	Rect_0   float64
+	Rect_1   float64
+}
+*/
+
+type Variant struct {
+	identifier string
+	payload    []primitiveType
+}
+
+type EnumDeclaration struct {
+	dataType primitiveType
+}
+
+// Cell::Empty, Shape::Circle(2.0)
+type EnumValue struct {
+	dataType primitiveType
+	variant  int
+	values   []Transpileable
+}
+
+func parseEnumDeclaration(line string, lineNum int) EnumDeclaration {
+	trimmed := strings.Trim(line, " ")
+	words := strings.Fields(trimmed)
+	if words[0] != "enum" {
+		panic("parseEnumDeclaration() called without enum keyword")
+	}
+	if len(words) < 3 {
+		panic(fmt.Sprintf("Line %d: expected enum declaration of the form enum Name { A, B } or enum Name = A | B", lineNum+1))
+	}
+
+	nameEnd := strings.IndexAny(trimmed[len("enum "):], " {=") + len("enum ")
+	identifier := parseIdentifier(strings.Trim(trimmed[len("enum "):nameEnd], " ")+":", lineNum)
+	// colon added so it doesn't throw an expected type annotation error
+
+	body := strings.Trim(trimmed[nameEnd:], " ")
+	var variantStrings []string
+	switch body[0] {
+	case '{':
+		if body[len(body)-1] != '}' {
+			panic(fmt.Sprintf("Line %d: enum declaration must be closed on the same line with '}'", lineNum+1))
+		}
+		variantStrings = splitTopLevel(body[1:len(body)-1], ',')
+	case '=':
+		variantStrings = splitTopLevel(body[1:], '|')
+	default:
+		panic(fmt.Sprintf("Line %d: expected '{' or '=' after name of enum %s", lineNum+1, identifier))
+	}
+
+	if len(variantStrings) == 0 {
+		panic(fmt.Sprintf("Line %d: enum %s has no variants", lineNum+1, identifier))
+	}
+
+	var variants []Variant
+	seen := make(map[string]struct{})
+	for _, v := range variantStrings {
+		name := v
+		var payload []primitiveType
+		if open := strings.Index(v, "("); open != -1 {
+			if v[len(v)-1] != ')' {
+				panic(fmt.Sprintf("Line %d: payload of variant %s is not closed with ')'", lineNum+1, v))
+			}
+			name = strings.Trim(v[:open], " ")
+			for _, s := range splitTopLevel(v[open+1:len(v)-1], ',') {
+				T := readType(s, lineNum)
+				if T == IO {
+					panic(fmt.Sprintf("Line %d: enum variants cannot carry values of type IO", lineNum+1))
+				}
+				payload = append(payload, T)
+			}
+			if len(payload) == 0 {
+				panic(fmt.Sprintf("Line %d: variant %s has empty brackets, leave them out if it carries no values", lineNum+1, name))
+			}
+		}
+		name = parseIdentifier(name+":", lineNum)
+		if _, ok := seen[name]; ok {
+			panic(fmt.Sprintf("Line %d: variant %s declared more than once in enum %s", lineNum+1, name, identifier))
+		}
+		seen[name] = struct{}{}
+
+		variants = append(variants, Variant{
+			identifier: name,
+			payload:    payload,
+		})
+	}
+
+	T := defineType(definedType{
+		identifier: identifier,
+		kind:       EnumKind,
+		variants:   variants,
+	}, lineNum)
+
+	if hasPayload(T) {
+		imports = append(imports, "fmt") // needed for String() method
+	}
+
+	return EnumDeclaration{
+		dataType: T,
+	}
+}
+
+func hasPayload(T primitiveType) bool {
+	enum, _ := T.defined()
+	for _, v := range enum.variants {
+		if len(v.payload) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isEnumValue(token string) bool {
+	if isConstructor(token) {
+		// Some(x), None, Ok(x) and Err(e) don't need the name of the enum
+		return true
+	}
+	separator := strings.Index(token, "::")
+	if separator <= 0 {
+		return false
+	}
+	T, ok := lookupType(token[:separator])
+	return ok && isEnum(T)
+}
+
+func findVariant(T primitiveType, name string) (int, bool) {
+	enum, _ := T.defined()
+	for i, v := range enum.variants {
+		if v.identifier == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func parseEnumValue(value string, lineNum int, currentScope *Scope) EnumValue {
+	if isConstructor(value) {
+		return parseConstructor(value, lineNum, currentScope)
+	}
+	trimmed := strings.Trim(value, " ")
+	separator := strings.Index(trimmed, "::")
+	T, _ := lookupType(trimmed[:separator])
+	enum, _ := T.defined()
+
+	name := trimmed[separator+2:]
+	var args []string
+	if open := strings.Index(name, "("); open != -1 {
+		if name[len(name)-1] != ')' {
+			panic(fmt.Sprintf("Line %d: brackets opened but never closed", lineNum+1))
+		}
+		args = splitTopLevel(name[open+1:len(name)-1], ',')
+		name = name[:open]
+	}
+
+	variant, ok := findVariant(T, name)
+	if !ok {
+		panic(fmt.Sprintf("Line %d: enum %s has no variant %s", lineNum+1, enum.identifier, name))
+	}
+	payload := enum.variants[variant].payload
+
+	if len(args) != len(payload) {
+		panic(fmt.Sprintf("Line %d: variant %s::%s carries %d values but %d were given", lineNum+1, enum.identifier, name, len(payload), len(args)))
+	}
+
+	var values []Transpileable
+	for i, arg := range args {
+		value, T := parseExpectedValue(arg, payload[i], lineNum, currentScope)
+		if T != payload[i] {
+			panic(fmt.Sprintf("Line %d: value %d of variant %s::%s has type %v but found type %v", lineNum+1, i+1, enum.identifier, name, payload[i], T))
+		}
+		values = append(values, value)
+	}
+
+	return EnumValue{
+		dataType: T,
+		variant:  variant,
+		values:   values,
+	}
+}
+
+func variantConstant(T primitiveType, variant int) string {
+	// name of the Go constant for the variant
+	enum, _ := T.defined()
+	if len(enum.generic) != 0 {
+		// shared by all uses of Option or Result
+		return enum.generic + "_" + enum.variants[variant].identifier
+	}
+	return enum.identifier + "_" + enum.variants[variant].identifier
+}
+
+func payloadField(T primitiveType, variant int, i int) string {
+	// name of the struct field holding value i of the variant
+	enum, _ := T.defined()
+	return fmt.Sprintf("%s_%d", enum.variants[variant].identifier, i)
+}
@@ -0,0 +1,315 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// generic function implementation in Go
+/**
+function sum<T: numeric>(xs: T[n]) -> T = {
+	...
+}
+
+type numeric interface {
+	~int | ~float64
+}
+
+func sum[T numeric](xs []T) T {
+	n := len(xs)
+	_ = n
+	...
+}
+
+// arrays with a length parameter become slices, so arrays are sliced with arr[:] at the call site
+// type arguments are inferred from the arguments of the call, which is also what Go does
+*/
+
+// T(0), converts a value to a type parameter, or to and from a newtype
+type Conversion struct {
+	dataType primitiveType
+	value    Expression
+}
+
+var typeParameters map[string]primitiveType // type parameters of the generic function currently being parsed
+
+func constraints() map[string]struct{} {
+	return map[string]struct{}{
+		"any":        {},
+		"comparable": {},
+		"ordered":    {},
+		"numeric":    {},
+	}
+}
+
+func parseTypeParameters(list string, lineNum int) []primitiveType {
+	// parses the list between < and > in a function declaration
+	var params []primitiveType
+	seen := make(map[string]struct{})
+	for _, p := range splitTopLevel(list, ',') {
+		name := p
+		constraint := "any"
+		if colon := strings.Index(p, ":"); colon != -1 {
+			name = strings.Trim(p[:colon], " ")
+			constraint = strings.Trim(p[colon+1:], " ")
+		}
+		name = parseIdentifier(name+":", lineNum)
+		if _, ok := constraints()[constraint]; !ok {
+			panic(fmt.Sprintf("Line %d: invalid constraint %s on type parameter %s, expected any, comparable, ordered or numeric", lineNum+1, constraint, name))
+		}
+		if _, ok := seen[name]; ok {
+			panic(fmt.Sprintf("Line %d: type parameter %s declared more than once", lineNum+1, name))
+		}
+		if _, ok := lookupType(name); ok {
+			panic(fmt.Sprintf("Line %d: type parameter %s has the same name as a type", lineNum+1, name))
+		}
+		seen[name] = struct{}{}
+
+		if constraint == "numeric" || constraint == "ordered" {
+			constraintImports = append(constraintImports, constraint)
+		}
+
+		// not added using defineType() as different functions can use the same names
+		definedTypes = append(definedTypes, definedType{
+			identifier: name,
+			kind:       TypeParameterKind,
+			constraint: constraint,
+		})
+		params = append(params, firstDefinedType+primitiveType(len(definedTypes)-1))
+	}
+	if len(params) == 0 {
+		panic(fmt.Sprintf("Line %d: generic function has empty list of type parameters", lineNum+1))
+	}
+	return params
+}
+
+func isTypeParameter(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == TypeParameterKind
+}
+
+func containsTypeParameter(T primitiveType) bool {
+	// whether T is a type parameter, or a tuple or array with one inside it
+	if isTypeParameter(T) {
+		return true
+	}
+	d, ok := T.defined()
+	switch {
+	case ok && d.kind == TupleKind:
+		return slices.ContainsFunc(d.elements, containsTypeParameter)
+	case ok && d.kind == ArrayKind:
+		return containsTypeParameter(d.array.baseType)
+	}
+	return false
+}
+
+func literalBaseType(literal string, lineNum int, currentScope *Scope) primitiveType {
+	// type of the elements of an array literal passed to a generic function, which is
+	// the type of its first element
+	elements := splitTopLevel(literal[1:len(literal)-1], ',')
+	if len(elements) == 0 {
+		panic(fmt.Sprintf("Line %d: cannot infer the type of an empty array literal", lineNum+1))
+	}
+	if elements[0][0] == '[' {
+		return literalBaseType(elements[0], lineNum, currentScope)
+	}
+	return parseExpression(elements[0], lineNum, currentScope).dataType
+}
+
+func literalTupleType(literal string, lineNum int, currentScope *Scope) primitiveType {
+	// type of a tuple literal passed to a generic function, from the types of its elements
+	var elements []primitiveType
+	for _, e := range splitTopLevel(literal[1:len(literal)-1], ',') {
+		switch {
+		case isTupleLiteral(e):
+			elements = append(elements, literalTupleType(e, lineNum, currentScope))
+		case e[0] == '[':
+			array := parseArrayExpression(e, literalBaseType(e, lineNum, currentScope), lineNum, currentScope)
+			elements = append(elements, arrayTypeID(array.dataType))
+		default:
+			elements = append(elements, parseExpression(e, lineNum, currentScope).dataType)
+		}
+	}
+	return tupleType(TuplePattern{dataTypes: elements})
+}
+
+func satisfies(T primitiveType, constraint string) bool {
+	// whether T can be used as a type argument for a type parameter with the constraint
+	if d, ok := T.defined(); ok && d.kind == TypeParameterKind {
+		// type parameters satisfy constraints which are weaker than their own
+		strength := map[string]int{"any": 0, "comparable": 1, "ordered": 2, "numeric": 3}
+		return strength[d.constraint] >= strength[constraint]
+	}
+	switch constraint {
+	case "numeric":
+		return numericType(T)
+	case "ordered":
+		U := underlyingType(T)
+		return numericType(U) || U == Byte || U == String
+	case "comparable":
+		return T != IO && !containsFunction(T)
+	default:
+		return true
+	}
+}
+
+func unify(param, arg primitiveType, bindings map[primitiveType]primitiveType) bool {
+	// matches the type of an argument to the type of a parameter, binding type parameters
+	// returns false if the types can't match
+	if isTypeParameter(param) {
+		if bound, ok := bindings[param]; ok {
+			return bound == arg
+		}
+		bindings[param] = arg
+		return true
+	}
+	if param == arg {
+		return true
+	}
+	p, ok1 := param.defined()
+	a, ok2 := arg.defined()
+	if !ok1 || !ok2 || p.kind != a.kind {
+		return false
+	}
+	switch p.kind {
+	case TupleKind:
+		if len(p.elements) != len(a.elements) {
+			return false
+		}
+		for i := range p.elements {
+			if !unify(p.elements[i], a.elements[i], bindings) {
+				return false
+			}
+		}
+		return true
+	case ArrayKind:
+		if len(p.array.dimensions) != len(a.array.dimensions) {
+			return false
+		}
+		for i, d := range p.array.dimensions {
+			if d != -1 && d != a.array.dimensions[i] {
+				return false
+			}
+		}
+		return unify(p.array.baseType, a.array.baseType, bindings)
+	case FunctionKind:
+		if len(p.parameters) != len(a.parameters) {
+			return false
+		}
+		for i := range p.parameters {
+			if !unify(p.parameters[i], a.parameters[i], bindings) {
+				return false
+			}
+		}
+		return unify(p.returns, a.returns, bindings)
+	case EnumKind:
+		// Option<T> or Result<T, E>
+		if len(p.generic) == 0 || p.generic != a.generic {
+			return false
+		}
+		args := typeArguments(a)
+		for i, T := range typeArguments(p) {
+			if !unify(T, args[i], bindings) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func checkBindings(fn Function, bindings map[primitiveType]primitiveType, lineNum int) {
+	for _, T := range fn.typeParams {
+		bound, ok := bindings[T]
+		if !ok {
+			panic(fmt.Sprintf("Line %d: cannot infer type parameter %v of function %s from its arguments", lineNum+1, T, fn.identifier))
+		}
+		d, _ := T.defined()
+		if !satisfies(bound, d.constraint) {
+			panic(fmt.Sprintf("Line %d: type %v does not satisfy constraint %s of type parameter %v of function %s", lineNum+1, bound, d.constraint, T, fn.identifier))
+		}
+	}
+}
+
+func substitute(T primitiveType, bindings map[primitiveType]primitiveType) primitiveType {
+	// replaces type parameters with their type arguments, including inside compound types
+	if bound, ok := bindings[T]; ok {
+		return bound
+	}
+	d, ok := T.defined()
+	if !ok {
+		return T
+	}
+	switch {
+	case d.kind == TupleKind:
+		var elements []primitiveType
+		for _, e := range d.elements {
+			elements = append(elements, substitute(e, bindings))
+		}
+		return tupleType(TuplePattern{dataTypes: elements})
+	case d.kind == ArrayKind:
+		return arrayTypeID(ArrayType{
+			baseType:   substitute(d.array.baseType, bindings),
+			dimensions: d.array.dimensions,
+		})
+	case d.kind == FunctionKind:
+		var params []primitiveType
+		for _, param := range d.parameters {
+			params = append(params, substitute(param, bindings))
+		}
+		return functionType(params, substitute(d.returns, bindings))
+	case d.generic == "Option":
+		return optionType(substitute(typeArguments(d)[0], bindings))
+	case d.generic == "Result":
+		args := typeArguments(d)
+		return resultType(substitute(args[0], bindings), substitute(args[1], bindings))
+	}
+	return T
+}
+
+func isConversion(token string) bool {
+	open := strings.Index(token, "(")
+	if open <= 0 || token[len(token)-1] != ')' {
+		return false
+	}
+	switch token[:open] {
+	case "int", "float", "bool", "byte", "string", "i32", "i64", "u8", "u64", "f32", "f64":
+		// converting between numeric types, or converting a newtype back
+		// to the type it is defined as e.g. float(k)
+		return true
+	}
+	if T, ok := typeParameters[token[:open]]; ok {
+		return numericType(T)
+	}
+	T, ok := lookupType(token[:open])
+	return ok && isNewtype(T)
+}
+
+func parseConversion(conversion string, lineNum int, currentScope *Scope) Conversion {
+	open := strings.Index(conversion, "(")
+	T := readType(conversion[:open], lineNum)
+	value := parseExpression(conversion[open+1:len(conversion)-1], lineNum, currentScope)
+	switch {
+	case isTypeParameter(T):
+		if !numericType(value.dataType) {
+			panic(fmt.Sprintf("Line %d: cannot convert value of type %v to %v", lineNum+1, value.dataType, T))
+		}
+	case isNewtype(T):
+		// a newtype can only be made from the type it is defined as
+		if value.dataType != underlyingType(T) && value.dataType != T {
+			panic(fmt.Sprintf("Line %d: cannot convert value of type %v to %v", lineNum+1, value.dataType, T))
+		}
+	default:
+		convertible := func(T primitiveType) bool {
+			return numericType(T) || underlyingType(T) == Byte
+		}
+		if underlyingType(value.dataType) != T && !(convertible(T) && convertible(value.dataType)) {
+			panic(fmt.Sprintf("Line %d: cannot convert value of type %v to %v", lineNum+1, value.dataType, T))
+		}
+	}
+	return Conversion{
+		dataType: T,
+		value:    value,
+	}
+}
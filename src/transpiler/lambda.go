@@ -0,0 +1,335 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// function values implementation in Go
+/**
+function integrate(f: (float) -> float, a: float, b: float) -> float = {
+	...
+}
+
+func integrate(f func(float64) float64, a float64, b float64) float64 {
+	...
+}
+
+let mut k: float = 2.0
+let area: float = integrate(|x: float| k * x * x, 0.0, 1.0)
+
+var area float64 = integrate(func() func(float64) float64 {
+	k := k
+	return func(x float64) float64 {
+		return k * x * x
+	}
+}(), 0.0, 1.0)
+
+// Go closures capture variables by reference, so mutable variables
+// are copied when the lambda is created to capture them by value
+*/
+
+// |x: float| x * x
+type Lambda struct {
+	fn       Function // signature of the lambda, without an identifier
+	body     Transpileable
+	captured []string // mutable variables which need to be copied
+	dataType primitiveType
+}
+
+func functionType(params []primitiveType, returns primitiveType) primitiveType {
+	// the same signature always gives the same id
+	for i, d := range definedTypes {
+		if d.kind == FunctionKind && slices.Equal(d.parameters, params) && d.returns == returns && !d.variadic {
+			return firstDefinedType + primitiveType(i)
+		}
+	}
+
+	var paramStrings []string
+	for _, T := range params {
+		paramStrings = append(paramStrings, T.String())
+	}
+	definedTypes = append(definedTypes, definedType{
+		identifier: "(" + strings.Join(paramStrings, ", ") + ") -> " + returns.String(),
+		kind:       FunctionKind,
+		parameters: params,
+		returns:    returns,
+	})
+	return firstDefinedType + primitiveType(len(definedTypes)-1)
+}
+
+func isFunction(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == FunctionKind
+}
+
+func isFunctionType(dataType string) bool {
+	// (float, float) -> float
+	trimmed := strings.Trim(dataType, " ")
+	if len(trimmed) == 0 || trimmed[0] != '(' {
+		return false
+	}
+	end := closingBracket(trimmed)
+	return end != -1 && strings.HasPrefix(strings.Trim(trimmed[end+1:], " "), "->")
+}
+
+func readFunctionType(dataType string, lineNum int) primitiveType {
+	trimmed := strings.Trim(dataType, " ")
+	end := closingBracket(trimmed)
+
+	var params []primitiveType
+	for _, s := range splitTopLevel(trimmed[1:end], ',') {
+		T := readType(s, lineNum)
+		if T == IO {
+			panic(fmt.Sprintf("Line %d: function types cannot take parameters of type IO", lineNum+1))
+		}
+		params = append(params, T)
+	}
+
+	returns := readType(strings.Trim(trimmed[end+1:], " ")[2:], lineNum)
+	if returns == IO {
+		panic(fmt.Sprintf("Line %d: function types cannot return IO", lineNum+1))
+	}
+	return functionType(params, returns)
+}
+
+func signature(fn Function, lineNum int) primitiveType {
+	// type of a named function when it is used as a value
+	if len(fn.typeParams) != 0 {
+		panic(fmt.Sprintf("Line %d: generic function %s cannot be used as a value", lineNum+1, fn.identifier))
+	}
+	if fn.returnDomain == primitive && fn.returnType == IO {
+		panic(fmt.Sprintf("Line %d: function %s returns IO so it cannot be used as a value", lineNum+1, fn.identifier))
+	}
+	if isVariadic(fn) {
+		panic(fmt.Sprintf("Line %d: variadic function %s cannot be used as a value", lineNum+1, fn.identifier))
+	}
+
+	var params []primitiveType
+	var variableCount, arrayCount, tupleCount int
+	for _, p := range fn.paramsOrder {
+		switch p {
+		case VariableParameter:
+			params = append(params, fn.parameters[variableCount].dataType)
+			variableCount++
+		case ArrayParameter:
+			params = append(params, arrayTypeID(fn.arrays[arrayCount].dataType))
+			arrayCount++
+		default:
+			params = append(params, tupleType(fn.tuples[tupleCount].pattern))
+			tupleCount++
+		}
+	}
+
+	switch fn.returnDomain {
+	case derived:
+		return functionType(params, arrayTypeID(fn.derivedReturnType))
+	case tuple:
+		return functionType(params, tupleType(fn.tupleReturnType))
+	default:
+		return functionType(params, fn.returnType)
+	}
+}
+
+func functionOfType(identifier string, T primitiveType) Function {
+	// used to call a variable holding a function in the same way as a named function
+	d, _ := T.defined()
+	fn := Function{
+		identifier: identifier,
+		returnType: d.returns,
+	}
+	for i, param := range d.parameters {
+		name := fmt.Sprintf("_p%d", i)
+		switch {
+		case isArray(param):
+			a, _ := param.defined()
+			fn.arrays = append(fn.arrays, Array{identifier: name, dataType: a.array})
+			fn.paramsOrder = append(fn.paramsOrder, ArrayParameter)
+		case isTuple(param):
+			t, _ := param.defined()
+			fn.tuples = append(fn.tuples, Tuple{identifier: name, pattern: TuplePattern{dataTypes: t.elements}})
+			fn.paramsOrder = append(fn.paramsOrder, TupleParameter)
+		default:
+			fn.parameters = append(fn.parameters, Variable{identifier: name, dataType: param})
+			fn.paramsOrder = append(fn.paramsOrder, VariableParameter)
+		}
+	}
+
+	switch {
+	case isArray(d.returns):
+		a, _ := d.returns.defined()
+		fn.returnDomain = derived
+		fn.derivedReturnType = a.array
+	case isTuple(d.returns):
+		t, _ := d.returns.defined()
+		fn.returnDomain = tuple
+		fn.tupleReturnType = TuplePattern{dataTypes: t.elements}
+	}
+	return fn
+}
+
+func containsFunction(T primitiveType) bool {
+	// whether values of type T are or contain functions, which Go can't compare
+	d, ok := T.defined()
+	if !ok {
+		return false
+	}
+	switch d.kind {
+	case FunctionKind:
+		return true
+	case ArrayKind:
+		return containsFunction(d.array.baseType)
+	case TupleKind:
+		return slices.ContainsFunc(d.elements, containsFunction)
+	case RecordKind:
+		return slices.ContainsFunc(d.fields, func(f Variable) bool { return containsFunction(f.dataType) })
+	case EnumKind:
+		return slices.ContainsFunc(d.variants, func(v Variant) bool { return slices.ContainsFunc(v.payload, containsFunction) })
+	}
+	return false
+}
+
+func lookupFunction(identifier string, currentScope *Scope) (Function, bool) {
+	// finds a named function, or a variable holding a function
+	if fn, ok := currentScope.functions[identifier]; ok {
+		return fn, true
+	}
+	if v, ok := currentScope.vars[identifier]; ok && isFunction(v.dataType) {
+		return functionOfType(identifier, v.dataType), true
+	}
+	return Function{}, false
+}
+
+func isLambda(expression string) bool {
+	return strings.HasPrefix(strings.Trim(expression, " "), "|")
+}
+
+func parseLambda(lambda string, lineNum int, currentScope *Scope) Lambda {
+	trimmed := strings.Trim(lambda, " ")
+	end := strings.Index(trimmed[1:], "|") + 1
+	if end == 0 {
+		panic(fmt.Sprintf("Line %d: parameters of lambda opened with | but never closed", lineNum+1))
+	}
+	body := strings.Trim(trimmed[end+1:], " ")
+	if len(body) == 0 {
+		panic(fmt.Sprintf("Line %d: lambda has no body", lineNum+1))
+	}
+
+	// the types of parameters can be left out if the type of the lambda is already known
+	var expected definedType
+	if isFunction(contextType) {
+		expected, _ = contextType.defined()
+	}
+	paramStrings := splitTopLevel(trimmed[1:end], ',')
+	for i, p := range paramStrings {
+		if strings.Contains(p, ":") {
+			continue
+		}
+		if len(expected.parameters) != len(paramStrings) {
+			panic(fmt.Sprintf("Line %d: cannot infer the type of lambda parameter %s, give it a type annotation", lineNum+1, p))
+		}
+		paramStrings[i] = p + ": " + expected.parameters[i].String()
+	}
+	parameters, arrays, tuples, order := parseParameters(strings.Join(paramStrings, ", "), lineNum)
+	for _, p := range parameters {
+		if len(p.defaultValue) != 0 {
+			panic(fmt.Sprintf("Line %d: lambda parameters cannot have default values", lineNum+1))
+		}
+	}
+	if slices.Contains(order, VariadicParameter) {
+		panic(fmt.Sprintf("Line %d: lambda parameters cannot be variadic", lineNum+1))
+	}
+
+	lambdaScope := childScope(currentScope, FunctionScope)
+	for _, p := range parameters {
+		lambdaScope.vars[p.identifier] = p
+	}
+	for _, arr := range arrays {
+		lambdaScope.arrays[arr.identifier] = arr
+	}
+	for _, tup := range tuples {
+		if tup.destructuring != nil {
+			tup.destructuring.declare(lineNum, &lambdaScope)
+			continue
+		}
+		lambdaScope.tuples[tup.identifier] = tup
+	}
+
+	// ? and None etc. don't refer to the function the lambda is written in
+	previous := enclosingReturnType
+	enclosingReturnType = IO
+	var expectedReturn primitiveType
+	if len(expected.parameters) == len(paramStrings) {
+		expectedReturn = expected.returns
+	}
+	value, returnType := parseExpectedValue(body, expectedReturn, lineNum, &lambdaScope)
+	enclosingReturnType = previous
+
+	if returnType == IO {
+		panic(fmt.Sprintf("Line %d: lambda cannot return IO", lineNum+1))
+	}
+
+	fn := Function{
+		parameters:  parameters,
+		arrays:      arrays,
+		tuples:      tuples,
+		paramsOrder: order,
+		returnType:  returnType,
+	}
+
+	return Lambda{
+		fn:       fn,
+		body:     value,
+		captured: capturedVariables(body, fn, currentScope),
+		dataType: signature(fn, lineNum),
+	}
+}
+
+func capturedVariables(body string, fn Function, currentScope *Scope) []string {
+	// mutable variables used in the body of a lambda, which are copied so that
+	// changing them later doesn't change the lambda
+	params := make(map[string]struct{})
+	for _, p := range fn.parameters {
+		params[p.identifier] = struct{}{}
+	}
+	for _, arr := range fn.arrays {
+		params[arr.identifier] = struct{}{}
+	}
+	for _, tup := range fn.tuples {
+		params[tup.identifier] = struct{}{}
+	}
+
+	mutable := func(identifier string) bool {
+		if v, ok := currentScope.vars[identifier]; ok {
+			return v.mut
+		}
+		if arr, ok := currentScope.arrays[identifier]; ok {
+			return arr.mut
+		}
+		if tup, ok := currentScope.tuples[identifier]; ok {
+			return tup.mut
+		}
+		return false
+	}
+
+	var captured []string
+	var word string
+	var stringLiteral bool
+	for i := 0; i <= len(body); i++ {
+		if i < len(body) && body[i] == '"' {
+			stringLiteral = !stringLiteral
+		}
+		if i < len(body) && !stringLiteral && parseCharType(body[i]) != other {
+			word += string(body[i])
+			continue
+		}
+		// words after a . are fields rather than variables
+		field := len(word) != 0 && i-len(word) > 0 && body[i-len(word)-1] == '.'
+		if _, ok := params[word]; !ok && !field && mutable(word) && !slices.Contains(captured, word) {
+			captured = append(captured, word)
+		}
+		word = ""
+	}
+	return captured
+}
@@ -0,0 +1,112 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// functions declared inside other functions
+/**
+function main() -> IO = {
+	let mut scale: float = 2.0
+	function double(x: float) -> float = {
+		scale * x
+	}
+	scale = 3.0
+	println!(double(1.0))
+}
+
+func main() {
+	var scale float64 = 2.0
+	var double func(float64) float64
+	_ = double
+	double = func() func(float64) float64 {
+		scale := scale
+		return func(x float64) float64 {
+			return scale * x
+		}
+	}()
+	scale = 3.0
+	fmt.Println(double(1.0))
+}
+
+// local functions become Go closures, which copy the mutable variables they use in
+// the same way as lambdas. variables from the enclosing scope can't be changed inside them
+*/
+
+type LocalFunction struct {
+	fn       Function
+	body     Scope
+	captured []string // mutable variables which need to be copied
+	dataType primitiveType
+}
+
+func checkLocalFunction(line string, lineNum int) {
+	words := strings.Fields(line)
+	if strings.HasPrefix(words[1], "main(") {
+		panic(fmt.Sprintf("Line %d: main() must be declared in the global scope", lineNum+1))
+	}
+	if lt := strings.Index(line, "<"); lt != -1 && lt < strings.Index(line, "(") {
+		// Go closures can't be generic
+		panic(fmt.Sprintf("Line %d: functions declared inside other functions cannot have type parameters", lineNum+1))
+	}
+}
+
+func declareLocalFunction(lines []string, lineNum int, currentScope *Scope) {
+	// adds the signature of a local function to the scope without parsing its body
+	checkLocalFunction(lines[lineNum], lineNum)
+	signatureScope := childScope(currentScope, FunctionScope)
+	f := parseSignature(lines, lineNum, &signatureScope)
+	currentScope.functions[f.identifier] = f
+}
+
+func parseLocalFunction(lines []string, lineNum int, currentScope *Scope) LocalFunction {
+	checkLocalFunction(lines[lineNum], lineNum)
+
+	bodyScope := childScope(currentScope, FunctionScope)
+	for k, v := range bodyScope.vars {
+		v.mut = false
+		bodyScope.vars[k] = v
+	}
+	for k, arr := range bodyScope.arrays {
+		arr.mut = false
+		bodyScope.arrays[k] = arr
+	}
+	for k, tup := range bodyScope.tuples {
+		tup.mut = false
+		bodyScope.tuples[k] = tup
+	}
+
+	// the return type of the enclosing function is restored once the body has been parsed
+	previousReturned, previousEnclosing, previousRecovers := returnedType, enclosingReturnType, recovers
+	fn := parseFunction(lines, lineNum, &bodyScope)
+	body := parseScope(lines, lineNum, FunctionScope, &bodyScope)
+	fn.recovers = recovers
+	returnedType, enclosingReturnType, recovers = previousReturned, previousEnclosing, previousRecovers
+
+	end := findScopeEnd(lines, lineNum)
+	return LocalFunction{
+		fn:       fn,
+		body:     body,
+		captured: capturedVariables(strings.Join(lines[lineNum+1:end], "\n"), fn, currentScope),
+		dataType: closureType(fn, lineNum),
+	}
+}
+
+func closureType(fn Function, lineNum int) primitiveType {
+	// type of the variable that a local function is stored in
+	if !isVariadic(fn) {
+		return signature(fn, lineNum)
+	}
+	// Go function types can be variadic, but this type is never given to a value
+	// in Stella as variadic functions can't be used as values
+	fixed := fn
+	fixed.paramsOrder = append(slices.Clone(fn.paramsOrder[:len(fn.paramsOrder)-1]), ArrayParameter)
+	d, _ := signature(fixed, lineNum).defined()
+	rest, _ := d.parameters[len(d.parameters)-1].defined()
+	d.identifier = strings.Replace(d.identifier, rest.identifier+")", "..."+rest.array.baseType.String()+")", 1)
+	d.variadic = true
+	definedTypes = append(definedTypes, d)
+	return firstDefinedType + primitiveType(len(definedTypes)-1)
+}
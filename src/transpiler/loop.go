@@ -2,11 +2,56 @@ package transpiler
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
+// range loop implementation in Go
+/**
+loop i in 0..n step 2 {
+	...
+}
+loop x in points {
+	...
+}
+
+for i, _end := 0, n; i < _end; i += 2 {
+	...
+}
+for _, x := range points {
+	_ = x
+	...
+}
+
+// ranges include the start but not the end like slices, and a negative
+// step counts down from the start instead. The end and the step are only
+// evaluated once, so changing them inside the loop doesn't change the range
+*/
+
 type Loop struct {
 	condition Expression
+	iterator  *Iterator  // nil for loops with a condition
+	label     *LoopLabel // nil for loops without a label
+}
+
+// 'outer: loop ...
+type LoopLabel struct {
+	identifier string
+	goLabel    string // Go labels must be different for every loop in a function
+	used       bool   // Go doesn't allow labels which are never used
+}
+
+// number of loops given each label so far
+var loopLabels map[string]int
+
+// i in 0..n step 2 or x in points
+type Iterator struct {
+	identifier string
+	start      Expression
+	end        Expression
+	step       Expression       // empty if the step is 1
+	array      *ArrayExpression // array being looped over, nil for ranges
+	dataType   primitiveType    // type of the loop variable
 }
 
 type BreakType int
@@ -16,13 +61,27 @@ const (
 	Continue
 )
 
-// break must be only token on the line
+// break can only be followed by the label of a loop
 type BreakStatement struct {
-	T BreakType
+	T     BreakType
+	label *LoopLabel // nil for the innermost loop
+}
+
+func isLoopLabel(word string) bool {
+	return len(word) > 2 && word[0] == '\'' && word[len(word)-1] == ':'
 }
 
 func parseLoop(line string, lineNum int, currentScope *Scope) Loop {
 	words := strings.Fields(line)
+	var label *LoopLabel
+	if isLoopLabel(words[0]) {
+		if len(words) == 1 || words[1] != "loop" {
+			panic(fmt.Sprintf("Line %d: label %s must be followed by a loop", lineNum+1, words[0]))
+		}
+		label = parseLoopLabel(words[0], lineNum, currentScope)
+		line = strings.Trim(line, " ")[len(words[0]):]
+		words = words[1:]
+	}
 	if words[0] != "loop" {
 		panic("parseLoop() called without loop keyword")
 	}
@@ -43,31 +102,199 @@ func parseLoop(line string, lineNum int, currentScope *Scope) Loop {
 	}
 
 	expr := trimmed[4:exprEnd]
-	expressionFound := parseExpression(expr, lineNum, currentScope)
+	if isIterator(expr) {
+		iterator := parseIterator(expr, lineNum, currentScope)
+		return Loop{
+			iterator: &iterator,
+			label:    label,
+		}
+	}
+	// checked before every iteration
+	expressionFound := conditionally(func() Expression { return parseExpression(expr, lineNum, currentScope) })
 
 	if expressionFound.dataType != Bool {
 		panic(fmt.Sprintf("Line %d: use of loop statement without boolean condition", lineNum+1))
 	}
 	return Loop{
 		condition: expressionFound,
+		label:     label,
 	}
 }
 
-func parseBreak(line string, lineNum int) BreakStatement {
+func parseLoopLabel(word string, lineNum int, currentScope *Scope) *LoopLabel {
+	identifier := parseIdentifier(word[1:], lineNum)
+	if enclosingLoop(identifier, currentScope) != nil {
+		panic(fmt.Sprintf("Line %d: label '%s is already used by an enclosing loop", lineNum+1, identifier))
+	}
+
+	if loopLabels == nil {
+		loopLabels = make(map[string]int)
+	}
+	goLabel := identifier
+	if count := loopLabels[identifier]; count != 0 {
+		goLabel += fmt.Sprintf("%d", count)
+	}
+	loopLabels[identifier]++
+
+	return &LoopLabel{
+		identifier: identifier,
+		goLabel:    goLabel,
+	}
+}
+
+func enclosingLoop(label string, currentScope *Scope) *Scope {
+	// finds the innermost loop with the label, or the innermost loop if label is empty
+	// loops outside a local function can't be left from inside it
+	for scope := currentScope; scope != nil && scope.scopeType != Global && scope.scopeType != FunctionScope; scope = scope.parent {
+		if scope.scopeType != LoopScope {
+			continue
+		}
+		if label == "" || (scope.label != nil && scope.label.identifier == label) {
+			return scope
+		}
+	}
+	return nil
+}
+
+func parseBreak(line string, lineNum int, currentScope *Scope) BreakStatement {
 	words := strings.Fields(line)
-	if len(words) != 1 {
-		panic(fmt.Sprintf("Line %d: break statements must be the only token on the line", lineNum+1))
+	if len(words) > 2 {
+		panic(fmt.Sprintf("Line %d: break statements can only be followed by the label of a loop", lineNum+1))
 	}
+
+	var b BreakStatement
 	switch words[0] {
 	case "break":
-		return BreakStatement{
-			T: Break,
-		}
+		b.T = Break
 	case "continue":
-		return BreakStatement{
-			T: Continue,
-		}
+		b.T = Continue
 	default:
 		panic(fmt.Sprintf("Line %d: found break statement with invalid keyword %s", lineNum+1, words[0]))
 	}
+
+	if len(words) == 1 {
+		if enclosingLoop("", currentScope) == nil {
+			panic(fmt.Sprintf("Line %d: found break/continue statement not inside any loop", lineNum+1))
+		}
+		return b
+	}
+
+	// break 'outer
+	if len(words[1]) < 2 || words[1][0] != '\'' {
+		panic(fmt.Sprintf("Line %d: expected label of a loop e.g. 'outer after %s but found %s", lineNum+1, words[0], words[1]))
+	}
+	identifier := words[1][1:]
+	loop := enclosingLoop(identifier, currentScope)
+	if loop == nil {
+		panic(fmt.Sprintf("Line %d: %s '%s is not inside any loop with the label '%s", lineNum+1, words[0], identifier, identifier))
+	}
+	loop.label.used = true
+	b.label = loop.label
+	return b
+}
+
+func isIterator(expr string) bool {
+	words := strings.Fields(expr)
+	return len(words) > 2 && words[1] == "in"
+}
+
+func rangeSeparator(s string) int {
+	// index of .. in a range, ignoring slices such as xs[1..3]
+	var bracketCount int
+	for i := 0; i < len(s)-1; i++ {
+		switch s[i] {
+		case '(', '[', '{':
+			bracketCount++
+		case ')', ']', '}':
+			bracketCount--
+		case '.':
+			if bracketCount == 0 && s[i+1] == '.' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseIterator(expr string, lineNum int, currentScope *Scope) Iterator {
+	words := strings.Fields(expr)
+	identifier := parseIdentifier(words[0]+":", lineNum)
+	if isInScope(identifier, currentScope) {
+		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, identifier))
+	}
+	iterated := strings.Trim(expr, " ")[len(words[0]):]
+	iterated = strings.Trim(strings.Trim(iterated, " ")[len("in"):], " ")
+
+	separator := rangeSeparator(iterated)
+	if separator == -1 {
+		// loop over the elements of an array
+		if strings.HasPrefix(iterated, "[") {
+			panic(fmt.Sprintf("Line %d: array literals must be given a name before they can be looped over", lineNum+1))
+		}
+		arr := parseArrayExpression(iterated, 0, lineNum, currentScope)
+		it := Iterator{
+			identifier: identifier,
+			array:      &arr,
+			dataType:   elementType(arr.dataType),
+		}
+		it.declare(currentScope)
+		return it
+	}
+
+	var step string
+	end := iterated[separator+2:] + " "
+	if stepIndex := strings.Index(end, " step "); stepIndex != -1 {
+		step = end[stepIndex+len(" step "):]
+		end = end[:stepIndex]
+		if len(strings.Trim(step, " ")) == 0 {
+			panic(fmt.Sprintf("Line %d: found no value after step", lineNum+1))
+		}
+	}
+	if len(strings.Trim(iterated[:separator], " ")) == 0 || len(strings.Trim(end, " ")) == 0 {
+		panic(fmt.Sprintf("Line %d: range in loop must have a start and an end e.g. 0..n", lineNum+1))
+	}
+
+	it := Iterator{
+		identifier: identifier,
+		start:      parseExpression(iterated[:separator], lineNum, currentScope),
+		end:        parseExpression(end, lineNum, currentScope),
+	}
+	it.dataType = it.start.dataType
+	if !numericType(it.dataType) {
+		panic(fmt.Sprintf("Line %d: range in loop must be of numbers but found type %v", lineNum+1, it.dataType))
+	}
+	if it.end.dataType != it.dataType {
+		panic(fmt.Sprintf("Line %d: start and end of range have different types %v and %v", lineNum+1, it.dataType, it.end.dataType))
+	}
+	if step != "" {
+		it.step = parseExpression(step, lineNum, currentScope)
+		if it.step.dataType != it.dataType {
+			panic(fmt.Sprintf("Line %d: step of range has type %v but range has type %v", lineNum+1, it.step.dataType, it.dataType))
+		}
+		if v, ok := numberLiteral(it.step.transpile()); ok && v == 0 {
+			panic(fmt.Sprintf("Line %d: step of range cannot be zero", lineNum+1))
+		}
+	}
+	it.declare(currentScope)
+	return it
+}
+
+func (it Iterator) declare(currentScope *Scope) {
+	// the loop variable is immutable
+	switch {
+	case isArray(it.dataType):
+		a, _ := it.dataType.defined()
+		currentScope.arrays[it.identifier] = Array{identifier: it.identifier, dataType: a.array}
+	case isTuple(it.dataType):
+		t, _ := it.dataType.defined()
+		currentScope.tuples[it.identifier] = Tuple{identifier: it.identifier, pattern: TuplePattern{dataTypes: t.elements}}
+	default:
+		currentScope.vars[it.identifier] = Variable{identifier: it.identifier, dataType: it.dataType}
+	}
+}
+
+func numberLiteral(transpiled string) (float64, bool) {
+	// the value of a range bound which is a literal, which doesn't need to be stored before the loop
+	v, err := strconv.ParseFloat(strings.ReplaceAll(transpiled, " ", ""), 64)
+	return v, err == nil
 }
@@ -14,10 +14,11 @@ const (
 )
 
 // println!("Hello world")
+// println!("x =", x, "y =", y)
 
 type Macro struct {
-	value Expression
-	T     macroType
+	values []Expression // print!() and println!() can take any number of arguments
+	T      macroType
 }
 
 func parseMacro(line string, lineNum int, currentScope *Scope) Macro {
@@ -35,7 +36,18 @@ func parseMacro(line string, lineNum int, currentScope *Scope) Macro {
 	}
 
 	macro = strings.Trim(macro, " ")
-	expr := parseExpression(line[bangIndex+1:], lineNum, currentScope)
+	if bangIndex == len(line)-1 {
+		panic(fmt.Sprintf("Line %d: attempt to call macro %s with no argument", lineNum+1, macro))
+	}
+
+	arguments := strings.Trim(line[bangIndex+1:], " ")
+	if len(arguments) == 0 || arguments[0] != '(' || closingBracket(arguments) != len(arguments)-1 {
+		panic(fmt.Sprintf("Line %d: arguments of macro %s! must be given in brackets", lineNum+1, macro))
+	}
+	var values []Expression
+	for _, argument := range splitTopLevel(arguments[1:len(arguments)-1], ',') {
+		values = append(values, parseExpression(argument, lineNum, currentScope))
+	}
 
 	var T macroType
 	switch macro {
@@ -47,19 +59,23 @@ func parseMacro(line string, lineNum int, currentScope *Scope) Macro {
 		imports = append(imports, "fmt")
 	case "panic":
 		T = Panic
-		if expr.dataType != String {
+		if len(values) != 1 {
+			panic(fmt.Sprintf("Line %d: panic!() macro takes exactly one argument, found %d", lineNum+1, len(values)))
+		}
+		if values[0].dataType != String {
 			panic(fmt.Sprintf("Line %d: use of panic!() macro with non-string argument", lineNum+1))
 		}
 	default:
 		panic(fmt.Sprintf("Line %d: attempt to use invalid macro %s!", lineNum+1, macro))
 	}
 
-	if bangIndex == len(line)-1 {
+	// println!() on its own prints an empty line
+	if len(values) == 0 && T == Print {
 		panic(fmt.Sprintf("Line %d: attempt to call macro %s with no argument", lineNum+1, macro))
 	}
 
 	return Macro{
-		T:     T,
-		value: expr,
+		T:      T,
+		values: values,
 	}
 }
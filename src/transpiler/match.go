@@ -0,0 +1,644 @@
+package transpiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// match implementation in Go
+/**
+let area: float = match s {
+	Shape::Circle(r) => 3.14 * r * r,
+	Shape::Rect(w, h) if w == h => w * w,
+	_ => 0.0
+}
+
+var area float64 = func(_m Shape) float64 {
+	if _m.tag == Shape_Circle {
+		r := _m.Circle_0
+		_ = r
+		return 3.14 * r * r
+	}
+	if _m.tag == Shape_Rect {
+		w := _m.Rect_0
+		_ = w
+		h := _m.Rect_1
+		_ = h
+		if w == h {
+			return w * w
+		}
+	}
+	return 0.0
+}(s)
+
+// arms are tried in order so an if-chain is used rather than a switch
+// as it allows bindings to be used in guards
+*/
+
+type patternKind int
+
+const (
+	wildcardMatch patternKind = iota
+	bindingMatch
+	literalMatch
+	variantMatch
+	tupleMatch
+)
+
+type MatchPattern struct {
+	kind     patternKind
+	dataType primitiveType
+	literal  string         // literal value (already valid Go)
+	name     string         // identifier of binding
+	variant  int            // index of enum variant
+	children []MatchPattern // payload of variant or elements of tuple
+}
+
+type MatchArm struct {
+	pattern MatchPattern
+	guard   Expression // optional
+	guarded bool
+	value   Expression
+}
+
+type MatchExpression struct {
+	scrutinee     string // already transpiled
+	scrutineeType primitiveType
+	arms          []MatchArm
+	dataType      primitiveType
+}
+
+type matchBinding struct {
+	name     string
+	dataType primitiveType
+	path     string // where the value is found in the Go code
+}
+
+func stripBrackets(expr string) (string, int) {
+	// removes brackets which enclose the whole expression e.g. (match x { ... })
+	// and returns how many pairs were removed
+	trimmed := strings.TrimSpace(expr)
+	var pairs int
+	for len(trimmed) > 1 && trimmed[0] == '(' && closingBracket(trimmed) == len(trimmed)-1 {
+		trimmed = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+		pairs++
+	}
+	return trimmed, pairs
+}
+
+func closingBracket(s string) int {
+	// index of the bracket closing the one at the start of s, or -1 if it isn't closed
+	// unlike findBracketEnd() this doesn't panic as it is used to check if a match is enclosed by brackets
+	var bracketCount int
+	var stringLiteral bool
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			stringLiteral = !stringLiteral
+		case '(':
+			if !stringLiteral {
+				bracketCount++
+			}
+		case ')':
+			if !stringLiteral {
+				bracketCount--
+			}
+		}
+		if bracketCount == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func isMatchExpression(expr string) bool {
+	stripped, _ := stripBrackets(expr)
+	words := strings.Fields(stripped)
+	return len(words) != 0 && words[0] == "match"
+}
+
+func joinMatchBlocks(lines []string) []string {
+	// match blocks spread over multiple lines are joined onto the line where they are opened
+	// so that the rest of the parser only ever sees a match as one line.
+	// the lines which are joined are left blank so that line numbers don't change
+	joined := append([]string{}, lines...)
+	for n := 0; n < len(joined); n++ {
+		line := joined[n]
+		found := false
+		for _, word := range strings.Fields(line) {
+			if word == "match" {
+				found = true
+			}
+		}
+		if !found || strings.Count(line, "{") <= strings.Count(line, "}") {
+			continue
+		}
+		end := findScopeEnd(joined, n)
+		// arms can be separated by newlines or commas
+		for i := n + 1; i <= end; i++ {
+			line += "," + strings.TrimSpace(joined[i])
+			joined[i] = ""
+		}
+		joined[n] = line
+		n = end
+	}
+	return joined
+}
+
+func parseScrutinee(scrutinee string, lineNum int, currentScope *Scope) (string, primitiveType) {
+	// value being matched, which can also be a tuple
+	trimmed := strings.Trim(scrutinee, " ")
+	if t, ok := (*currentScope).tuples[trimmed]; ok {
+		return t.identifier, tupleType(t.pattern)
+	}
+
+	if trimmed[0] == '(' && closingBracket(trimmed) == len(trimmed)-1 {
+		elements := splitTopLevel(trimmed[1:len(trimmed)-1], ',')
+		if len(elements) > 1 {
+			// tuple literal
+			var pattern TuplePattern
+			var values []Expression
+			for _, e := range elements {
+				expr := parseExpression(e, lineNum, currentScope)
+				pattern.dataTypes = append(pattern.dataTypes, expr.dataType)
+				values = append(values, expr)
+			}
+			T := tupleType(pattern)
+			transpiled := T.goType() + "{"
+			for i, v := range values {
+				transpiled += fmt.Sprintf("v%d: ", i) + v.transpile()
+				if i != len(values)-1 {
+					transpiled += ", "
+				}
+			}
+			return transpiled + "}", T
+		}
+	}
+
+	if open := strings.Index(trimmed, "("); open > 0 {
+		if fn, ok := (*currentScope).functions[trimmed[:open]]; ok && fn.returnDomain == tuple {
+			call := parseFunctionCall(trimmed, lineNum, currentScope)
+			return call.transpile(), tupleType(fn.tupleReturnType)
+		}
+	}
+
+	expr := parseExpression(trimmed, lineNum, currentScope)
+	return expr.transpile(), expr.dataType
+}
+
+func parseMatchExpression(expression string, lineNum int, currentScope *Scope) MatchExpression {
+	trimmed, _ := stripBrackets(expression)
+	open := strings.Index(trimmed, "{")
+	if open == -1 || trimmed[len(trimmed)-1] != '}' {
+		panic(fmt.Sprintf("Line %d: expected match value { pattern => value, ... }", lineNum+1))
+	}
+	if findBracketEnd('{', []string{trimmed}, 0, open).charIndex != len(trimmed)-1 {
+		panic(fmt.Sprintf("Line %d: found unexpected tokens after match block", lineNum+1))
+	}
+
+	if len(strings.TrimSpace(trimmed[len("match"):open])) == 0 {
+		panic(fmt.Sprintf("Line %d: found no value to match on between match and '{'", lineNum+1))
+	}
+	scrutinee, T := parseScrutinee(trimmed[len("match"):open], lineNum, currentScope)
+	if T == IO {
+		panic(fmt.Sprintf("Line %d: cannot match on a value of type IO", lineNum+1))
+	}
+
+	var arms []MatchArm
+	var dataType primitiveType
+	for _, a := range splitTopLevel(trimmed[open+1:len(trimmed)-1], ',') {
+		if len(a) == 0 {
+			continue
+		}
+		// only evaluated when the arm is chosen
+		arm := conditionally(func() MatchArm { return parseMatchArm(a, T, lineNum, currentScope) })
+		if len(arms) == 0 {
+			dataType = arm.value.dataType
+		} else if arm.value.dataType != dataType {
+			panic(fmt.Sprintf("Line %d: arms of match have different types %v and %v", lineNum+1, dataType, arm.value.dataType))
+		}
+		arms = append(arms, arm)
+	}
+
+	if len(arms) == 0 {
+		panic(fmt.Sprintf("Line %d: match has no arms", lineNum+1))
+	}
+
+	checkArms(arms, T, lineNum)
+
+	return MatchExpression{
+		scrutinee:     scrutinee,
+		scrutineeType: T,
+		arms:          arms,
+		dataType:      dataType,
+	}
+}
+
+func parseMatchArm(arm string, T primitiveType, lineNum int, currentScope *Scope) MatchArm {
+	arrow := -1
+	var bracketCount int
+	var stringLiteral bool
+	for i := 0; i < len(arm)-1; i++ {
+		switch arm[i] {
+		case '"':
+			stringLiteral = !stringLiteral
+		case '(', '[', '{':
+			bracketCount++
+		case ')', ']', '}':
+			bracketCount--
+		}
+		if !stringLiteral && bracketCount == 0 && arm[i:i+2] == "=>" {
+			arrow = i
+			break
+		}
+	}
+	if arrow == -1 {
+		panic(fmt.Sprintf("Line %d: expected => after pattern in match arm %s", lineNum+1, arm))
+	}
+
+	patternString := strings.Trim(arm[:arrow], " ")
+	var guardString string
+	words := strings.Fields(patternString)
+	for i, word := range words {
+		if word == "if" {
+			patternString = strings.Join(words[:i], " ")
+			guardString = strings.Join(words[i+1:], " ")
+			if len(guardString) == 0 {
+				panic(fmt.Sprintf("Line %d: expected condition after if in match arm", lineNum+1))
+			}
+			break
+		}
+	}
+
+	bindings := make(map[string]primitiveType)
+	pattern := parseMatchPattern(patternString, T, bindings, lineNum)
+
+	armScope := childScope(currentScope, SelectionScope)
+	for name, dataType := range bindings {
+		if isArray(dataType) {
+			arr, _ := dataType.defined()
+			armScope.arrays[name] = Array{
+				identifier: name,
+				dataType:   arr.array,
+			}
+			continue
+		}
+		if isTuple(dataType) {
+			tup, _ := dataType.defined()
+			armScope.tuples[name] = Tuple{
+				identifier: name,
+				pattern:    TuplePattern{dataTypes: tup.elements},
+			}
+			continue
+		}
+		armScope.vars[name] = Variable{
+			identifier: name,
+			dataType:   dataType,
+		}
+	}
+
+	var guard Expression
+	if len(guardString) != 0 {
+		guard = parseExpression(guardString, lineNum, &armScope)
+		if guard.dataType != Bool {
+			panic(fmt.Sprintf("Line %d: guard of match arm must be a boolean expression", lineNum+1))
+		}
+	}
+
+	value := strings.Trim(arm[arrow+2:], " ")
+	if len(value) == 0 {
+		panic(fmt.Sprintf("Line %d: found no value after => in match arm", lineNum+1))
+	}
+
+	return MatchArm{
+		pattern: pattern,
+		guard:   guard,
+		guarded: len(guardString) != 0,
+		value:   parseExpression(value, lineNum, &armScope),
+	}
+}
+
+func parseMatchPattern(pattern string, T primitiveType, bindings map[string]primitiveType, lineNum int) MatchPattern {
+	p := strings.Trim(pattern, " ")
+	if len(p) == 0 {
+		panic(fmt.Sprintf("Line %d: empty pattern in match arm", lineNum+1))
+	}
+
+	if p == "_" {
+		return MatchPattern{kind: wildcardMatch, dataType: T}
+	}
+
+	if isConstructor(p) {
+		// Some(x), None, Ok(x) or Err(e)
+		if !isOption(T) && !isResult(T) {
+			panic(fmt.Sprintf("Line %d: cannot match pattern %s against value of type %v", lineNum+1, p, T))
+		}
+		return parseVariantPattern(p, T, bindings, lineNum)
+	}
+
+	if p[0] != '(' && strings.Contains(p, "::") {
+		// a tuple pattern can contain variants too e.g. (Cell::X, n)
+		if !isEnumValue(p) {
+			panic(fmt.Sprintf("Line %d: %s is not a variant of an enum", lineNum+1, p))
+		}
+		separator := strings.Index(p, "::")
+		if E, _ := lookupType(p[:separator]); E != T {
+			panic(fmt.Sprintf("Line %d: cannot match variant of %v against value of type %v", lineNum+1, E, T))
+		}
+		return parseVariantPattern(p[separator+2:], T, bindings, lineNum)
+	}
+
+	// a name binds the whole value, whatever its type
+	if parseCharType(p[0]) == letter && p != "true" && p != "false" {
+		name := parseIdentifier(p+":", lineNum)
+		if _, ok := bindings[name]; ok {
+			panic(fmt.Sprintf("Line %d: %s bound more than once in the same pattern", lineNum+1, name))
+		}
+		bindings[name] = T
+		return MatchPattern{kind: bindingMatch, dataType: T, name: name}
+	}
+
+	if isTuple(T) {
+		tup, _ := T.defined()
+		if p[0] != '(' || p[len(p)-1] != ')' {
+			panic(fmt.Sprintf("Line %d: expected tuple pattern for value of type %v but found %s", lineNum+1, T, p))
+		}
+		elements := splitTopLevel(p[1:len(p)-1], ',')
+		if len(elements) != len(tup.elements) {
+			panic(fmt.Sprintf("Line %d: tuple pattern has %d elements but the value has %d", lineNum+1, len(elements), len(tup.elements)))
+		}
+		var children []MatchPattern
+		for i, e := range elements {
+			children = append(children, parseMatchPattern(e, tup.elements[i], bindings, lineNum))
+		}
+		return MatchPattern{kind: tupleMatch, dataType: T, children: children}
+	}
+
+	// must be a literal
+	var literalType primitiveType
+	if p[0] == '-' {
+		if _, err := strconv.Atoi(p); err != nil {
+			panic(fmt.Sprintf("Line %d: invalid pattern %s", lineNum+1, p))
+		}
+		literalType = Int
+	} else {
+		literalType = getValType(p, lineNum)
+	}
+
+	if literalType == Float || literalType == F32 || literalType == Complex {
+		panic(fmt.Sprintf("Line %d: float values cannot be used as patterns because they are not compared exactly", lineNum+1))
+	}
+	if literalType != T {
+		panic(fmt.Sprintf("Line %d: cannot match pattern of type %v against value of type %v", lineNum+1, literalType, T))
+	}
+	if isSuffixedLiteral(p) {
+		p = parseNumericLiteral(p, lineNum).transpile()
+	}
+	return MatchPattern{kind: literalMatch, dataType: T, literal: p}
+}
+
+// exhaustiveness and reachability are checked using the usefulness algorithm
+// described by Maranget in "Warnings for pattern matching". A pattern is useful
+// with respect to the rows before it if there is a value which it matches
+// and none of the rows do
+
+func (p MatchPattern) constructor() string {
+	// identifies the constructor of refutable patterns
+	switch p.kind {
+	case literalMatch:
+		return "l" + p.literal
+	case variantMatch:
+		return fmt.Sprintf("v%d", p.variant)
+	case tupleMatch:
+		return "t"
+	default:
+		return ""
+	}
+}
+
+func allConstructors(T primitiveType) []MatchPattern {
+	// returns one pattern for each constructor of T, or nil if there are infinitely many
+	wildcards := func(types []primitiveType) []MatchPattern {
+		var children []MatchPattern
+		for _, t := range types {
+			children = append(children, MatchPattern{kind: wildcardMatch, dataType: t})
+		}
+		return children
+	}
+
+	if T == Bool {
+		return []MatchPattern{
+			{kind: literalMatch, dataType: Bool, literal: "true"},
+			{kind: literalMatch, dataType: Bool, literal: "false"},
+		}
+	}
+	if d, ok := T.defined(); ok {
+		switch d.kind {
+		case EnumKind:
+			var constructors []MatchPattern
+			for i, v := range d.variants {
+				constructors = append(constructors, MatchPattern{kind: variantMatch, dataType: T, variant: i, children: wildcards(v.payload)})
+			}
+			return constructors
+		case TupleKind:
+			return []MatchPattern{{kind: tupleMatch, dataType: T, children: wildcards(d.elements)}}
+		}
+	}
+	return nil
+}
+
+func specialise(row []MatchPattern, c MatchPattern) ([]MatchPattern, bool) {
+	// row with the first pattern replaced by its children if it matches constructor c
+	first := row[0]
+	switch first.kind {
+	case wildcardMatch, bindingMatch:
+		var expanded []MatchPattern
+		for _, child := range c.children {
+			expanded = append(expanded, MatchPattern{kind: wildcardMatch, dataType: child.dataType})
+		}
+		return append(expanded, row[1:]...), true
+	default:
+		if first.constructor() != c.constructor() {
+			return nil, false
+		}
+		return append(append([]MatchPattern{}, first.children...), row[1:]...), true
+	}
+}
+
+func useful(rows [][]MatchPattern, v []MatchPattern) bool {
+	if len(v) == 0 {
+		return len(rows) == 0
+	}
+
+	specialiseAll := func(c MatchPattern) ([][]MatchPattern, []MatchPattern) {
+		var specialised [][]MatchPattern
+		for _, row := range rows {
+			if s, ok := specialise(row, c); ok {
+				specialised = append(specialised, s)
+			}
+		}
+		s, _ := specialise(v, c)
+		return specialised, s
+	}
+
+	if v[0].kind != wildcardMatch && v[0].kind != bindingMatch {
+		return useful(specialiseAll(v[0]))
+	}
+
+	used := make(map[string]struct{})
+	for _, row := range rows {
+		if c := row[0].constructor(); c != "" {
+			used[c] = struct{}{}
+		}
+	}
+
+	constructors := allConstructors(v[0].dataType)
+	complete := len(constructors) != 0
+	for _, c := range constructors {
+		if _, ok := used[c.constructor()]; !ok {
+			complete = false
+		}
+	}
+
+	if complete {
+		for _, c := range constructors {
+			if useful(specialiseAll(c)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// default matrix, rows which match anything in the first column
+	var defaults [][]MatchPattern
+	for _, row := range rows {
+		if row[0].kind == wildcardMatch || row[0].kind == bindingMatch {
+			defaults = append(defaults, row[1:])
+		}
+	}
+	return useful(defaults, v[1:])
+}
+
+func checkArms(arms []MatchArm, T primitiveType, lineNum int) {
+	var rows [][]MatchPattern
+	for i, arm := range arms {
+		if !useful(rows, []MatchPattern{arm.pattern}) {
+			panic(fmt.Sprintf("Line %d: arm %d of match is unreachable because its pattern is covered by the arms before it", lineNum+1, i+1))
+		}
+		if !arm.guarded {
+			// arms with guards don't count as covering their pattern
+			rows = append(rows, []MatchPattern{arm.pattern})
+		}
+	}
+
+	if useful(rows, []MatchPattern{{kind: wildcardMatch, dataType: T}}) {
+		var missing []string
+		for _, c := range allConstructors(T) {
+			if useful(rows, []MatchPattern{c}) {
+				missing = append(missing, c.String())
+			}
+		}
+		if len(missing) != 0 && len(missing) != len(allConstructors(T)) {
+			panic(fmt.Sprintf("Line %d: match is not exhaustive, missing %s", lineNum+1, strings.Join(missing, ", ")))
+		}
+		panic(fmt.Sprintf("Line %d: match is not exhaustive, add a wildcard arm _ => ... to cover all values of type %v", lineNum+1, T))
+	}
+}
+
+func parseVariantPattern(pattern string, T primitiveType, bindings map[string]primitiveType, lineNum int) MatchPattern {
+	// pattern is the part after :: e.g. Circle(r)
+	enum, _ := T.defined()
+	name := strings.Trim(pattern, " ")
+	var args []string
+	if open := strings.Index(name, "("); open != -1 {
+		if name[len(name)-1] != ')' {
+			panic(fmt.Sprintf("Line %d: brackets opened but never closed", lineNum+1))
+		}
+		args = splitTopLevel(name[open+1:len(name)-1], ',')
+		name = strings.Trim(name[:open], " ")
+	}
+
+	variant, ok := findVariant(T, name)
+	if !ok {
+		panic(fmt.Sprintf("Line %d: enum %s has no variant %s", lineNum+1, enum.identifier, name))
+	}
+	payload := enum.variants[variant].payload
+	if len(args) != len(payload) {
+		panic(fmt.Sprintf("Line %d: variant %s::%s carries %d values but the pattern has %d", lineNum+1, enum.identifier, name, len(payload), len(args)))
+	}
+
+	var children []MatchPattern
+	for i, arg := range args {
+		children = append(children, parseMatchPattern(arg, payload[i], bindings, lineNum))
+	}
+	return MatchPattern{kind: variantMatch, dataType: T, variant: variant, children: children}
+}
+
+func (p MatchPattern) String() string {
+	// pattern as it would be written in Stella, used in error messages
+	switch p.kind {
+	case literalMatch:
+		return p.literal
+	case bindingMatch:
+		return p.name
+	case variantMatch:
+		enum, _ := p.dataType.defined()
+		s := enum.variants[p.variant].identifier
+		if len(enum.generic) == 0 {
+			// Option and Result variants are written without the name of the type
+			s = enum.identifier + "::" + s
+		}
+		if len(p.children) == 0 {
+			return s
+		}
+		var children []string
+		for _, c := range p.children {
+			children = append(children, c.String())
+		}
+		return s + "(" + strings.Join(children, ", ") + ")"
+	case tupleMatch:
+		var children []string
+		for _, c := range p.children {
+			children = append(children, c.String())
+		}
+		return "(" + strings.Join(children, ", ") + ")"
+	default:
+		return "_"
+	}
+}
+
+func (p MatchPattern) conditions(path string) ([]string, []matchBinding) {
+	// Go conditions for a value at path to match the pattern,
+	// and the variables bound by the pattern
+	switch p.kind {
+	case bindingMatch:
+		return nil, []matchBinding{{name: p.name, dataType: p.dataType, path: path}}
+	case literalMatch:
+		return []string{path + " == " + p.literal}, nil
+	case variantMatch:
+		var conditions []string
+		var bindings []matchBinding
+		if !hasPayload(p.dataType) {
+			return []string{path + " == " + variantConstant(p.dataType, p.variant)}, nil
+		}
+		conditions = append(conditions, path+".tag == "+variantConstant(p.dataType, p.variant))
+		for i, child := range p.children {
+			c, b := child.conditions(path + "." + payloadField(p.dataType, p.variant, i))
+			conditions = append(conditions, c...)
+			bindings = append(bindings, b...)
+		}
+		return conditions, bindings
+	case tupleMatch:
+		var conditions []string
+		var bindings []matchBinding
+		for i, child := range p.children {
+			c, b := child.conditions(fmt.Sprintf("%s.v%d", path, i))
+			conditions = append(conditions, c...)
+			bindings = append(bindings, b...)
+		}
+		return conditions, bindings
+	default:
+		return nil, nil
+	}
+}
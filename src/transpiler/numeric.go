@@ -0,0 +1,168 @@
+package transpiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sized numeric types implementation in Go
+/**
+let x: i32 = 5i32
+let grid: u8[3] = [0u8, 255u8, 7u8]
+let z: complex = complex(1.0, 2.0) * 3.0i
+
+var x int32 = int32(5)
+var grid [3]uint8 = [3]uint8{uint8(0), uint8(255), uint8(7)}
+var z complex128 = complex(1.0, 2.0) * complex128(3.0i)
+
+// values of different numeric types can't be mixed, so a suffix gives
+// a literal its type and conversions such as f32(x) change the type
+*/
+
+// 5i32
+type NumericLiteral struct {
+	value    string // number without the suffix
+	dataType primitiveType
+}
+
+// complex(re, im), real(z) or imag(z)
+type ComplexFunction struct {
+	identifier string
+	args       []Expression
+	dataType   primitiveType
+}
+
+func numericSuffixes() map[string]primitiveType {
+	return map[string]primitiveType{
+		"i32": I32,
+		"i64": I64,
+		"u8":  U8,
+		"u64": U64,
+		"f32": F32,
+		"f64": Float,
+		"i":   Complex, // imaginary number e.g. 2.0i
+	}
+}
+
+func literalSuffix(value string) (string, primitiveType, bool) {
+	// splits a literal such as 5i32 into the number and the type given by its suffix
+	for suffix, T := range numericSuffixes() {
+		end := len(value) - len(suffix)
+		if end > 0 && strings.HasSuffix(value, suffix) && parseCharType(value[end-1]) == number {
+			return value[:end], T, true
+		}
+	}
+	return "", 0, false
+}
+
+func isSuffixedLiteral(token string) bool {
+	_, _, ok := literalSuffix(token)
+	return ok && parseCharType(token[0]) == number
+}
+
+func checkSuffixedVal(number string, T primitiveType, lineNum int) {
+	// checks the number before the suffix, including that it fits in the type
+	var err error
+	switch T {
+	case I32, I64, U8, U64:
+		checkIntVal(number, lineNum)
+		bits := map[primitiveType]int{I32: 32, I64: 64, U8: 8, U64: 64}[T]
+		if T == I32 || T == I64 {
+			_, err = strconv.ParseInt(number, 10, bits)
+		} else {
+			_, err = strconv.ParseUint(number, 10, bits)
+		}
+	default:
+		if strings.ContainsAny(number, ".eE") {
+			checkFloatVal(number, lineNum)
+		} else {
+			checkIntVal(number, lineNum)
+		}
+		if T == F32 {
+			_, err = strconv.ParseFloat(number, 32)
+		}
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Line %d: %s is out of range for type %v", lineNum+1, number, T))
+	}
+}
+
+func parseNumericLiteral(literal string, lineNum int) NumericLiteral {
+	number, T, _ := literalSuffix(literal)
+	checkSuffixedVal(number, T, lineNum)
+	if T == Complex {
+		number += "i" // Go also uses i for imaginary numbers
+	}
+	return NumericLiteral{
+		value:    number,
+		dataType: T,
+	}
+}
+
+func unsignedType(T primitiveType) bool {
+	U := underlyingType(T)
+	return U == U8 || U == U64
+}
+
+func integerType(T primitiveType) bool {
+	// types which can be used with % and bitwise operators
+	switch underlyingType(T) {
+	case Int, I32, I64, U8, U64:
+		return true
+	}
+	return false
+}
+
+func arithmeticType(T primitiveType) bool {
+	// types which can be used with + - * /
+	return numericType(T) || underlyingType(T) == Complex
+}
+
+func isComplexFunction(token string) bool {
+	for _, name := range []string{"complex", "real", "imag"} {
+		if strings.HasPrefix(token, name+"(") && closingBracket(token[len(name):]) == len(token)-len(name)-1 {
+			return true
+		}
+	}
+	return false
+}
+
+func parseComplexFunction(token string, lineNum int, currentScope *Scope) ComplexFunction {
+	open := strings.Index(token, "(")
+	name := token[:open]
+	var args []Expression
+	for _, arg := range splitTopLevel(token[open+1:len(token)-1], ',') {
+		args = append(args, parseExpression(arg, lineNum, currentScope))
+	}
+
+	if name == "complex" {
+		// complex(re, im)
+		if len(args) != 2 {
+			panic(fmt.Sprintf("Line %d: complex() takes a real part and an imaginary part but found %d arguments", lineNum+1, len(args)))
+		}
+		for _, arg := range args {
+			if arg.dataType != Float {
+				panic(fmt.Sprintf("Line %d: both parts of a complex number must have type float but found type %v", lineNum+1, arg.dataType))
+			}
+		}
+		return ComplexFunction{
+			identifier: name,
+			args:       args,
+			dataType:   Complex,
+		}
+	}
+
+	// real(z) or imag(z)
+	if len(args) != 1 {
+		panic(fmt.Sprintf("Line %d: %s() takes 1 argument but found %d", lineNum+1, name, len(args)))
+	}
+	if args[0].dataType != Complex {
+		panic(fmt.Sprintf("Line %d: %s() takes a value of type complex but found type %v", lineNum+1, name, args[0].dataType))
+	}
+	return ComplexFunction{
+		identifier: name,
+		args:       args,
+		dataType:   Float,
+	}
+}
@@ -0,0 +1,97 @@
+package transpiler
+
+import (
+	"slices"
+	"strings"
+)
+
+// implementation in Go of operators which Go doesn't have
+/**
+let area: float = r ** 2.0
+let n: int = 2 ** 10
+let whole: float = 7.5 div 2.0
+
+var area float64 = math.Pow(r, 2.0)
+var n int = _pow(2, 10)
+var whole float64 = math.Trunc(7.5 / 2.0)
+
+// % and the bitwise operators & | ^ << >> are written the same way in Go
+*/
+
+// x ** 2 or a div b
+type BinaryOperation struct {
+	operator string
+	left     Expression
+	right    Expression
+	dataType primitiveType
+}
+
+func groupOperations(parsed []string, lineNum int, currentScope *Scope) ([]string, map[int]Transpileable) {
+	// replaces each operation which Go doesn't have with a single item so that it can be transpiled differently
+	nodes := make(map[int]Transpileable)
+	if !slices.Contains(parsed, "**") && !slices.Contains(parsed, "div") {
+		return parsed, nodes
+	}
+
+	checker := typeChecker{
+		tokens:       parsed,
+		lineNum:      lineNum,
+		currentScope: currentScope,
+	}
+	_ = checker.or()
+
+	// operations inside other operations are parsed along with the outer one
+	var outermost [][3]int
+	for _, operation := range checker.operations {
+		inside := false
+		for _, other := range checker.operations {
+			if other != operation && other[0] <= operation[0] && operation[2] <= other[2] {
+				inside = true
+			}
+		}
+		if !inside {
+			outermost = append(outermost, operation)
+		}
+	}
+	slices.SortFunc(outermost, func(a, b [3]int) int {
+		return a[0] - b[0]
+	})
+
+	// operations after && or || aren't always evaluated
+	shortCircuit := slices.IndexFunc(parsed, func(token string) bool { return token == "&&" || token == "||" })
+
+	grouped := slices.Clone(parsed)
+	removed := 0 // number of items that earlier operations were replaced with
+	for _, operation := range outermost {
+		start, end := operation[0]-removed, operation[2]-removed
+		items := slices.Clone(grouped[start:end])
+		parse := func() BinaryOperation {
+			return parseBinaryOperation(items, operation[1]-operation[0], lineNum, currentScope)
+		}
+		if shortCircuit != -1 && operation[0] > shortCircuit {
+			nodes[start] = conditionally(parse)
+		} else {
+			nodes[start] = parse()
+		}
+		grouped = slices.Replace(grouped, start, end, strings.Join(items, " "))
+		removed += end - start - 1
+	}
+	return grouped, nodes
+}
+
+func isBinaryOperation(node Transpileable) bool {
+	_, ok := node.(BinaryOperation)
+	return ok
+}
+
+func parseBinaryOperation(items []string, operatorIndex int, lineNum int, currentScope *Scope) BinaryOperation {
+	// types have already been checked by expressionType()
+	left := parseExpression(strings.Join(items[:operatorIndex], " "), lineNum, currentScope)
+	right := parseExpression(strings.Join(items[operatorIndex+1:], " "), lineNum, currentScope)
+	return BinaryOperation{
+		operator: items[operatorIndex],
+		left:     left,
+		right:    right,
+		dataType: left.dataType,
+	}
+}
@@ -0,0 +1,298 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Option and Result implementation in Go
+/**
+let x: Option<float> = Some(2.0)
+
+type Option[T any] struct {
+	tag    int
+	Some_0 T
+}
+
+var x Option[float64] = Option[float64]{tag: Option_Some, Some_0: 2.0}
+
+// Option and Result are enums with a type parameter, so each use
+// such as Option<float> gets its own type id like any other enum
+
+function root(x: float) -> Result<float, string> = {
+	let r: float = sqrt(x)?
+	Ok(r)
+}
+
+func root(x float64) Result[float64, string] {
+	_p0 := sqrt(x)
+	if _p0.tag == Result_Err {
+		return Result[float64, string]{tag: Result_Err, Err_0: _p0.Err_0}
+	}
+	var r float64 = _p0.Ok_0
+	return Result[float64, string]{tag: Result_Ok, Ok_0: r}
+}
+
+// a value which isn't always evaluated, such as a match arm, can't be moved
+// before the statement, so there ? panics and the function recovers from it
+*/
+
+// value? returns early from the function if the value is None or Err
+type Propagation struct {
+	value      Expression
+	dataType   primitiveType
+	returnType primitiveType // of the function that is returned from
+	hoisted    bool          // checked before the statement it is part of, otherwise ? panics
+}
+
+var (
+	enclosingReturnType primitiveType // return type of the function currently being parsed, used by ? and to infer the types of None, Ok and Err
+	contextType         primitiveType // type expected by the context of the expression currently being parsed e.g. a type annotation
+	conditionalDepth    int           // greater than 0 while parsing values which aren't always evaluated e.g. match arms
+	recovers            bool          // whether the function currently being parsed uses ? where it has to panic
+	propagationCount    int           // used to give each value checked by ? a different name
+	hoistedChecks       []string      // checks made by ? before the statement currently being transpiled
+)
+
+func optionType(T primitiveType) primitiveType {
+	return builtinEnum("Option", []Variant{
+		{identifier: "Some", payload: []primitiveType{T}},
+		{identifier: "None"},
+	})
+}
+
+func resultType(T, E primitiveType) primitiveType {
+	return builtinEnum("Result", []Variant{
+		{identifier: "Ok", payload: []primitiveType{T}},
+		{identifier: "Err", payload: []primitiveType{E}},
+	})
+}
+
+func builtinEnum(name string, variants []Variant) primitiveType {
+	// the same type arguments always give the same id
+	d := definedType{
+		kind:     EnumKind,
+		variants: variants,
+		generic:  name,
+	}
+	args := typeArguments(d)
+	for i, T := range definedTypes {
+		if T.generic == name && slices.Equal(typeArguments(T), args) {
+			return firstDefinedType + primitiveType(i)
+		}
+	}
+
+	var argStrings []string
+	for _, T := range args {
+		argStrings = append(argStrings, T.String())
+	}
+	d.identifier = name + "<" + strings.Join(argStrings, ", ") + ">"
+
+	builtinImports = append(builtinImports, name)
+	imports = append(imports, "fmt") // needed for String() method
+
+	definedTypes = append(definedTypes, d)
+	return firstDefinedType + primitiveType(len(definedTypes)-1)
+}
+
+func typeArguments(d definedType) []primitiveType {
+	// the type arguments of Option or Result are the values carried by their variants
+	var args []primitiveType
+	for _, v := range d.variants {
+		args = append(args, v.payload...)
+	}
+	return args
+}
+
+func isOption(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.generic == "Option"
+}
+
+func isResult(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.generic == "Result"
+}
+
+func readBuiltinType(dataType string, lineNum int) (primitiveType, bool) {
+	// reads Option<T> or Result<T, E>
+	open := strings.Index(dataType, "<")
+	if open == -1 || !opensTypeArguments(dataType, open) {
+		return 0, false
+	}
+	if dataType[len(dataType)-1] != '>' {
+		panic(fmt.Sprintf("Line %d: type arguments of %s opened with < but never closed", lineNum+1, dataType[:open]))
+	}
+
+	var args []primitiveType
+	for _, s := range splitTopLevel(dataType[open+1:len(dataType)-1], ',') {
+		T := readType(s, lineNum)
+		if T == IO {
+			panic(fmt.Sprintf("Line %d: IO cannot be used as a type argument", lineNum+1))
+		}
+		args = append(args, T)
+	}
+
+	switch dataType[:open] {
+	case "Option":
+		if len(args) != 1 {
+			panic(fmt.Sprintf("Line %d: Option takes 1 type argument but found %d", lineNum+1, len(args)))
+		}
+		return optionType(args[0]), true
+	default:
+		if len(args) != 2 {
+			panic(fmt.Sprintf("Line %d: Result takes 2 type arguments but found %d", lineNum+1, len(args)))
+		}
+		return resultType(args[0], args[1]), true
+	}
+}
+
+func opensTypeArguments(s string, i int) bool {
+	// whether the < at index i opens the type arguments of Option or Result
+	// rather than being a comparison
+	return s[i] == '<' && (strings.HasSuffix(s[:i], "Option") || strings.HasSuffix(s[:i], "Result"))
+}
+
+func parseContextualExpression(expression string, expected primitiveType, lineNum int, currentScope *Scope) Expression {
+	// parses an expression whose type is known from its context
+	// so that None, Ok and Err can be given a type
+	previous := contextType
+	contextType = expected
+	defer func() {
+		contextType = previous
+	}()
+	return parseExpression(expression, lineNum, currentScope)
+}
+
+func isConstructor(token string) bool {
+	t := strings.Trim(token, " ")
+	if t == "None" {
+		return true
+	}
+	for _, c := range []string{"Some", "Ok", "Err"} {
+		if strings.HasPrefix(t, c+"(") && closingBracket(t[len(c):]) == len(t)-len(c)-1 {
+			return true
+		}
+	}
+	return false
+}
+
+func inferConstructorType(name string, lineNum int) primitiveType {
+	// None, Ok and Err don't contain enough information to know their type
+	// so it is taken from the context or from the return type of the function
+	isType := isResult
+	if name == "None" {
+		isType = isOption
+	}
+	if isType(contextType) {
+		return contextType
+	}
+	if isType(enclosingReturnType) {
+		return enclosingReturnType
+	}
+	panic(fmt.Sprintf("Line %d: cannot infer the type of %s, use it where the type is known e.g. a declaration with a type annotation", lineNum+1, name))
+}
+
+func parseConstructor(value string, lineNum int, currentScope *Scope) EnumValue {
+	trimmed := strings.Trim(value, " ")
+	if trimmed == "None" {
+		return EnumValue{
+			dataType: inferConstructorType("None", lineNum),
+			variant:  1,
+		}
+	}
+
+	open := strings.Index(trimmed, "(")
+	name := trimmed[:open]
+	args := splitTopLevel(trimmed[open+1:len(trimmed)-1], ',')
+	if len(args) != 1 {
+		panic(fmt.Sprintf("Line %d: %s carries 1 value but %d were given", lineNum+1, name, len(args)))
+	}
+
+	if name == "Some" {
+		var expected primitiveType
+		if option, ok := contextType.defined(); ok && isOption(contextType) {
+			expected = typeArguments(option)[0]
+		}
+		payload, T := parseExpectedValue(args[0], expected, lineNum, currentScope)
+		if T == IO {
+			panic(fmt.Sprintf("Line %d: Some cannot carry a value of type IO", lineNum+1))
+		}
+		return EnumValue{
+			dataType: optionType(T),
+			variant:  0,
+			values:   []Transpileable{payload},
+		}
+	}
+
+	T := inferConstructorType(name, lineNum)
+	variant, _ := findVariant(T, name)
+	result, _ := T.defined()
+	expected := result.variants[variant].payload[0]
+
+	payload, found := parseExpectedValue(args[0], expected, lineNum, currentScope)
+	if found != expected {
+		panic(fmt.Sprintf("Line %d: %s of %v must carry a value of type %v but found type %v", lineNum+1, name, T, expected, found))
+	}
+	return EnumValue{
+		dataType: T,
+		variant:  variant,
+		values:   []Transpileable{payload},
+	}
+}
+
+func isPropagation(token string) bool {
+	return len(token) > 1 && token[len(token)-1] == '?'
+}
+
+func parsePropagation(token string, lineNum int, currentScope *Scope) Propagation {
+	value := parseExpression(token[:len(token)-1], lineNum, currentScope)
+	T := value.dataType
+	d, _ := T.defined()
+
+	switch {
+	case isOption(T):
+		if !isOption(enclosingReturnType) {
+			panic(fmt.Sprintf("Line %d: ? can only be used on an Option inside a function which returns an Option", lineNum+1))
+		}
+	case isResult(T):
+		if !isResult(enclosingReturnType) {
+			panic(fmt.Sprintf("Line %d: ? can only be used on a Result inside a function which returns a Result", lineNum+1))
+		}
+		returned, _ := enclosingReturnType.defined()
+		E, returnedE := typeArguments(d)[1], typeArguments(returned)[1]
+		if E != returnedE {
+			panic(fmt.Sprintf("Line %d: cannot use ? on a Result with error type %v inside a function which returns error type %v", lineNum+1, E, returnedE))
+		}
+	default:
+		panic(fmt.Sprintf("Line %d: ? can only be used on values of type Option or Result but found type %v", lineNum+1, T))
+	}
+
+	propagation := Propagation{
+		value:      value,
+		dataType:   typeArguments(d)[0],
+		returnType: enclosingReturnType,
+		hoisted:    conditionalDepth == 0,
+	}
+	if !propagation.hoisted {
+		// returning before the statement would return even when the value isn't used
+		recovers = true
+		builtinImports = append(builtinImports, "propagation")
+	}
+	return propagation
+}
+
+func conditionally[T any](parse func() T) T {
+	// parses a value which isn't always evaluated, so ? inside it can't return before the statement
+	conditionalDepth++
+	defer func() { conditionalDepth-- }()
+	return parse()
+}
+
+func isValueToken(token string) bool {
+	// whether a token from parseExpression() is a value rather than an operator or bracket
+	_, binary := binaryOperators()[token]
+	_, unary := unaryOperators()[token]
+	return !binary && !unary && token != "(" && token != ")" && token != "{" && token != "}"
+}
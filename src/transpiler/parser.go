@@ -3,6 +3,7 @@ package transpiler
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 )
 
@@ -33,6 +34,15 @@ const (
 	TupleReturnStatement
 	ScopeClose
 	MacroItem
+	RecordDeclarationItem
+	EnumDeclarationItem
+	ConstantDeclarationItem
+	TypeDefinitionItem
+	DestructuringDeclaration
+	MemberAssignmentItem
+	EarlyReturnStatement
+	ValueBlockItem
+	BlockResultItem
 	Empty
 )
 
@@ -53,6 +63,7 @@ const (
 	VariableParameter parameterType = iota
 	ArrayParameter
 	TupleParameter
+	VariadicParameter // stored with the array parameters
 )
 
 const (
@@ -63,9 +74,10 @@ const (
 
 type Variable struct {
 	// where variables cannot be collections
-	identifier string
-	dataType   primitiveType
-	mut        bool
+	identifier   string
+	dataType     primitiveType
+	mut          bool
+	defaultValue string // only for function parameters, empty if the parameter has no default value
 }
 
 type Declaration struct {
@@ -76,10 +88,8 @@ type Declaration struct {
 // cannot parse array literals into function because of type inference
 type FunctionCall struct {
 	functionName string
-	parameters   []Expression
-	arrays       []Array // can't be a literal as type would need to be inferred from literal
-	tuples       []Tuple // as above
-	order        []parameterType
+	arguments    []Transpileable // in the order of the parameters, with any variadic arguments last
+	dataType     primitiveType   // return type, with type parameters of generic functions substituted
 }
 
 type Operator struct {
@@ -97,6 +107,7 @@ type SelectionStatement struct {
 }
 
 type Function struct {
+	typeParams        []primitiveType // optional - only used by generic functions
 	parameters        []Variable
 	arrays            []Array
 	tuples            []Tuple
@@ -106,23 +117,20 @@ type Function struct {
 	derivedReturnType ArrayType     // optional
 	returnType        primitiveType // optional - at least one of optionals must be present (dictated by returnDomain field)
 	returnDomain      returnDomain
+	recovers          bool // whether ? is used in the function body where it panics
+	lineNum           int  // line where the function is declared
 }
 
 type Expression struct {
 	items    []string
 	dataType primitiveType
+	nodes    map[int]Transpileable // parsed form of items which can't be transpiled as they are
 }
 
 type Assignment struct {
-	v Variable
-	e Expression
-}
-
-type Iterator[T int64 | float64] struct {
-	start    T
-	step     T
-	end      T
-	dataType primitiveType
+	v        Variable
+	e        Expression // empty for ++ and --
+	operator string     // = or a compound assignment operator such as +=
 }
 
 func parseCharType(char byte) charType {
@@ -181,9 +189,6 @@ func parseVariableDeclaration(line string, lineNum int, currentScope *Scope) Dec
 		mut = true
 	}
 
-	typeIndex := identifierIndex + 1 // type must come after identifier
-	equalsIndex := typeIndex + 1     //"=" must come after type
-
 	id := parseIdentifier(words[identifierIndex], lineNum)
 
 	if _, v := (*currentScope).vars[id]; v {
@@ -196,16 +201,17 @@ func parseVariableDeclaration(line string, lineNum int, currentScope *Scope) Dec
 		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
 	}
 
-	expectedType := readType(words[typeIndex], lineNum)
+	annotation := typeAnnotation(line) // can contain spaces e.g. Result<float, string>
+	if len(annotation) == 0 {
+		panic(fmt.Sprintf("Line %d: expected token '=' after type annotation", lineNum))
+	}
+
+	expectedType := readType(annotation, lineNum)
 
 	if expectedType == IO {
 		panic(fmt.Sprintf("Line %d: variables cannot have data type IO", lineNum+1))
 	}
 
-	if words[equalsIndex] != "=" {
-		panic(fmt.Sprintf("Line %d: expected token '=' after type annotation", lineNum))
-	}
-
 	var equalsCharIndex int // expression is everything after equals
 	for i := 0; i < len(line); i++ {
 		if line[i] == '=' {
@@ -216,10 +222,10 @@ func parseVariableDeclaration(line string, lineNum int, currentScope *Scope) Dec
 	}
 
 	expression := line[equalsCharIndex+1:]
-	exprFound := parseExpression(expression, lineNum, currentScope)
+	exprFound := parseContextualExpression(expression, expectedType, lineNum, currentScope)
 
 	if exprFound.dataType != expectedType {
-		panic(fmt.Sprintf("Line %d: expected type %s because of type annotation, found type %s", lineNum, expectedType.String(), exprFound.dataType.String()))
+		panic(fmt.Sprintf("Line %d: expected type %s because of type annotation, found type %s", lineNum+1, expectedType.String(), exprFound.dataType.String()))
 	}
 
 	v := Variable{
@@ -238,6 +244,31 @@ func parseVariableDeclaration(line string, lineNum int, currentScope *Scope) Dec
 
 func parseExpression(expression string, lineNum int, currentScope *Scope) Expression {
 	// parses any expression with any number of tokens
+	if isLambda(expression) {
+		// the body of the lambda is the rest of the expression
+		lambda := parseLambda(expression, lineNum, currentScope)
+		return Expression{
+			items:    []string{"lambda"},
+			dataType: lambda.dataType,
+			nodes:    map[int]Transpileable{0: lambda},
+		}
+	}
+	if isMatchExpression(expression) {
+		// the whole expression is the match so it is kept as one item
+		// apart from any brackets around it
+		m := parseMatchExpression(expression, lineNum, currentScope)
+		_, pairs := stripBrackets(expression)
+		items := []string{"match"}
+		for i := 0; i < pairs; i++ {
+			items = append(append([]string{"("}, items...), ")")
+		}
+		return Expression{
+			items:    items,
+			dataType: m.dataType,
+			nodes:    map[int]Transpileable{pairs: m},
+		}
+	}
+
 	var parsed []string
 	var currentItem string
 	var bracketCount int
@@ -258,6 +289,23 @@ func parseExpression(expression string, lineNum int, currentScope *Scope) Expres
 			}
 			parsed = append(parsed, stringLiteral)
 		case '(', '{':
+			if expression[i] == '{' {
+				// record literal e.g. Particle { x: 0.0, v: 1.0, mass: 2.0 }
+				name := currentItem
+				if len(name) == 0 && len(parsed) != 0 {
+					name = parsed[len(parsed)-1]
+				}
+				if T, ok := lookupType(name); ok && isRecord(T) {
+					end := findBracketEnd('{', []string{expression}, 0, i).charIndex
+					if len(currentItem) == 0 {
+						parsed = parsed[:len(parsed)-1]
+					}
+					parsed = append(parsed, name+" "+expression[i:end+1])
+					currentItem = ""
+					i = end
+					continue
+				}
+			}
 			if len(currentItem) != 0 { // parse it as function call, error will arise in parseFunctionCall() if there is one
 				// if currentItem is not empty it must be a function name
 				fnBracketCount := 0
@@ -281,6 +329,12 @@ func parseExpression(expression string, lineNum int, currentScope *Scope) Expres
 				currentItem = ""
 				continue
 			}
+			if end := i + closingBracket(expression[i:]); expression[i] == '(' && isTupleLiteral(expression[i:end+1]) {
+				// tuple literal compared with another tuple e.g. t == (1, 2)
+				parsed = append(parsed, expression[i:end+1])
+				i = end
+				continue
+			}
 			bracketCount++
 			currentItem = ""
 			parsed = append(parsed, string(expression[i]))
@@ -291,25 +345,38 @@ func parseExpression(expression string, lineNum int, currentScope *Scope) Expres
 			bracketCount--
 			currentItem = ""
 			parsed = append(parsed, string(expression[i]))
-		case '+', '-', '*', '/': // can only be alone
+		case '+', '-', '*', '/', '%', '^': // can only be alone
 			c := string(expression[i])
+			if isExponent(currentItem) && (c == "+" || c == "-") {
+				// sign of the exponent in a float literal e.g. 6.674e-11
+				currentItem += c
+				continue
+			}
+			if c == "*" && i != len(expression)-1 && expression[i+1] == '*' {
+				// ** is the only operator made of two of these
+				c = "**"
+				i++
+			}
 			if currentItem != "" {
 				parsed = append(parsed, currentItem)
 			}
 			parsed = append(parsed, c)
 			currentItem = ""
-		case '&', '|', '=': // can only be 2 next to each other
+		case '&', '|', '=': // = can only be 2 next to each other
 			c := string(expression[i])
 			if len(expression)-1 == i {
 				panic(fmt.Sprintf("line %d: use of invalid operator %s in expression", lineNum+1, string(c)))
 			}
+			if len(currentItem) != 0 {
+				parsed = append(parsed, currentItem)
+			}
+			currentItem = ""
 			if string(expression[i+1]) == c { //
-				if len(currentItem) != 0 {
-					parsed = append(parsed, currentItem)
-				}
 				parsed = append(parsed, c+c)
-				currentItem = ""
 				i++ // skip next character because already added here
+			} else if c != "=" {
+				// bitwise and/or
+				parsed = append(parsed, c)
 			} else {
 				panic(fmt.Sprintf("Line %d: use of invalid operator '%s' in expression", lineNum+1, string(c)))
 			}
@@ -318,7 +385,15 @@ func parseExpression(expression string, lineNum int, currentScope *Scope) Expres
 			if i == len(expression)-1 {
 				panic(fmt.Sprintf("Line %d: operator %s found at end of expression with no value after", lineNum+1, string(expression[i])))
 			}
-			if expression[i+1] == '=' {
+			if c != "!" && expression[i+1] == expression[i] {
+				// bit shift
+				if len(currentItem) != 0 {
+					parsed = append(parsed, currentItem)
+				}
+				currentItem = ""
+				parsed = append(parsed, c+c)
+				i++
+			} else if expression[i+1] == '=' {
 				if len(currentItem) != 0 {
 					parsed = append(parsed, currentItem)
 				}
@@ -332,12 +407,35 @@ func parseExpression(expression string, lineNum int, currentScope *Scope) Expres
 				currentItem = ""
 				parsed = append(parsed, c)
 			}
+		case '?': // applies to the value directly before it e.g. root(x)?
+			if currentItem != "" {
+				parsed = append(parsed, currentItem+"?")
+			} else if len(parsed) != 0 && isValueToken(parsed[len(parsed)-1]) {
+				parsed[len(parsed)-1] += "?"
+			} else {
+				panic(fmt.Sprintf("Line %d: ? must come directly after a value", lineNum+1))
+			}
+			currentItem = ""
 		case ' ':
 			if currentItem != "" {
 				parsed = append(parsed, currentItem)
 			}
 			currentItem = ""
 		default:
+			if len(currentItem) == 0 && isIfExpression(expression[i:]) {
+				// if expression used as a value, which is kept as one item
+				end := ifExpressionEnd(expression, i, lineNum)
+				parsed = append(parsed, expression[i:end+1])
+				i = end
+				continue
+			}
+			if len(currentItem) == 0 && expression[i] == '[' {
+				// array literal compared with another array e.g. xs == [1, 2, 3]
+				end := squareBracketEnd(expression, i, lineNum)
+				parsed = append(parsed, expression[i:end+1])
+				i = end
+				continue
+			}
 			currentItem += string(expression[i])
 			if i == len(expression)-1 {
 				parsed = append(parsed, currentItem)
@@ -396,7 +494,97 @@ func parseExpression(expression string, lineNum int, currentScope *Scope) Expres
 		}
 	}
 	T := expressionType(parsed, lineNum, currentScope)
-	return Expression{parsed, T}
+	collections := comparedCollections(parsed, lineNum, currentScope)
+
+	parsed, nodes := groupOperations(parsed, lineNum, currentScope)
+	defer func(depth int) { conditionalDepth = depth }(conditionalDepth)
+	for i, token := range parsed {
+		if token == "&&" || token == "||" {
+			// the rest of the expression isn't always evaluated
+			conditionalDepth++
+		}
+		if _, ok := nodes[i]; ok {
+			// ** or div
+			continue
+		} else if isComparedCollection(token, currentScope) {
+			// can't be part of an operation so they are still in the same order
+			nodes[i] = collections[0]
+			collections = collections[1:]
+		} else if isIfExpression(token) {
+			nodes[i] = parseIfExpression(token, lineNum, currentScope)
+		} else if isSuffixedLiteral(token) {
+			nodes[i] = parseNumericLiteral(token, lineNum)
+		} else if isPropagation(token) {
+			nodes[i] = parsePropagation(token, lineNum, currentScope)
+		} else if isRecordLiteral(token) {
+			nodes[i] = parseRecordLiteral(token, lineNum, currentScope)
+		} else if isEnumValue(token) {
+			nodes[i] = parseEnumValue(token, lineNum, currentScope)
+		} else if isMemberAccess(token, currentScope) {
+			nodes[i] = parseMemberAccess(token, lineNum, currentScope)
+		} else if isConversion(token) {
+			nodes[i] = parseConversion(token, lineNum, currentScope)
+		} else if isComplexFunction(token) {
+			nodes[i] = parseComplexFunction(token, lineNum, currentScope)
+		} else if open := strings.Index(token, "("); open > 0 {
+			// arguments can contain values which need to be transpiled e.g. enum values
+			if _, ok := lookupFunction(token[:open], currentScope); ok {
+				nodes[i] = parseFunctionCall(token, lineNum, currentScope)
+			}
+		}
+	}
+
+	parsedExpression := Expression{
+		items:    parsed,
+		dataType: T,
+		nodes:    nodes,
+	}
+	for _, node := range nodes {
+		switch node.(type) {
+		case NumericLiteral, Conversion:
+			// Go works these out while compiling if they are constant, and fails if they don't fit in their type
+			if isConstantExpression(parsedExpression) {
+				_ = evaluateExpression(parsedExpression, "constant expression", lineNum)
+				return parsedExpression
+			}
+		}
+	}
+	return parsedExpression
+}
+
+func parseTypedValue(value string, expectedType primitiveType, lineNum int, currentScope *Scope) Transpileable {
+	// parses a value where the type is already known, which is needed
+	// for values such as tuple and array literals whose types can't be inferred
+	if isTuple(expectedType) {
+		T, _ := expectedType.defined()
+		return parseTupleExpression(value, TuplePattern{dataTypes: T.elements}, lineNum, currentScope)
+	}
+	if isArray(expectedType) {
+		T, _ := expectedType.defined()
+		return copyArray(parseArrayExpression(value, T.array.baseType, lineNum, currentScope), T.array, lineNum)
+	}
+	expr := parseContextualExpression(value, expectedType, lineNum, currentScope)
+	if expr.dataType != expectedType {
+		panic(fmt.Sprintf("Line %d: expected value of type %v but found value of type %v", lineNum+1, expectedType, expr.dataType))
+	}
+	return expr
+}
+
+func parseExpectedValue(value string, expected primitiveType, lineNum int, currentScope *Scope) (Transpileable, primitiveType) {
+	// parses a value which could be a tuple or an array, where the expected type might not be known
+	// e.g. the value carried by Some or the body of a lambda
+	if isTuple(expected) || isArray(expected) {
+		return parseTypedValue(value, expected, lineNum, currentScope), expected
+	}
+	if trimmed := strings.Trim(value, " "); isTupleLiteral(trimmed) {
+		T := literalTupleType(trimmed, lineNum, currentScope)
+		return parseTypedValue(trimmed, T, lineNum, currentScope), T
+	} else if len(trimmed) != 0 && trimmed[0] == '[' {
+		array := parseArrayExpression(trimmed, literalBaseType(trimmed, lineNum, currentScope), lineNum, currentScope)
+		return array, arrayTypeID(array.dataType)
+	}
+	expr := parseContextualExpression(value, expected, lineNum, currentScope)
+	return expr, expr.dataType
 }
 
 func checkValue(value, previous, next string, lineNum int, currentScope *Scope) {
@@ -410,8 +598,49 @@ func checkValue(value, previous, next string, lineNum int, currentScope *Scope)
 		return
 	}
 
+	if isIfExpression(value) {
+		_ = parseIfExpression(value, lineNum, currentScope)
+		return
+	}
+
+	if isComparedCollection(value, currentScope) {
+		// parsed by expressionType() once the type it is compared with is known
+		return
+	}
+
 	identifier := false
 
+	if isPropagation(value) {
+		_ = parsePropagation(value, lineNum, currentScope)
+		return
+	}
+
+	if isRecordLiteral(value) {
+		_ = parseRecordLiteral(value, lineNum, currentScope)
+		return
+	}
+
+	if isEnumValue(value) {
+		_ = parseEnumValue(value, lineNum, currentScope)
+		return
+	}
+
+	if isMemberAccess(value, currentScope) {
+		// checked before the loop below as it can contain array indexes e.g. points[i].0
+		_ = parseMemberAccess(value, lineNum, currentScope)
+		return
+	}
+
+	if isConversion(value) {
+		_ = parseConversion(value, lineNum, currentScope)
+		return
+	}
+
+	if isComplexFunction(value) {
+		_ = parseComplexFunction(value, lineNum, currentScope)
+		return
+	}
+
 	var stringLiteral bool
 	for i := 0; i < len(value); i++ {
 		if value[i] == '"' {
@@ -420,7 +649,7 @@ func checkValue(value, previous, next string, lineNum int, currentScope *Scope)
 		if !stringLiteral {
 			if value[i] == '(' { // cannot be tuple literal as indexing raw tuple literal in a primitive expression isn't allowed
 				fnCall := parseFunctionCall(value, lineNum, currentScope)
-				if _, ok := currentScope.functions[fnCall.functionName]; ok {
+				if _, ok := lookupFunction(fnCall.functionName, currentScope); ok {
 					identifier = true
 				}
 				return
@@ -429,13 +658,18 @@ func checkValue(value, previous, next string, lineNum int, currentScope *Scope)
 				// check for valid array indexing
 				return
 			} else if value[i] == '.' {
-				_ = parseTupleIndexing(value, lineNum, currentScope)
+				_ = parseMemberAccess(value, lineNum, currentScope)
 				return
 			}
 		}
 	}
 
-	if _, ok := currentScope.vars[value]; ok {
+	if isInScope(value, currentScope) {
+		// arrays and tuples can be compared with == and !=
+		identifier = true
+	}
+	if _, ok := currentScope.functions[value]; ok {
+		// function used as a value
 		identifier = true
 	}
 	if !identifier {
@@ -521,9 +755,19 @@ func isStatement(line string) bool {
 		return true
 	}
 	switch words[0] {
-	case "if", "loop", "let", "}":
+	case "if", "loop", "let", "return", "function", "{", "}":
 		// } is scope closer which counts as a statement
 		return true
+	case "match":
+		// contains => but is an expression
+		return false
+	}
+	if isIncrement(line) || isLoopLabel(words[0]) {
+		return true
+	}
+	if isLambda(line) {
+		// body can contain == etc.
+		return false
 	}
 	assignment := false
 	stringCount := 0
@@ -557,6 +801,7 @@ func parseMultiLineExpression(lines []string, lineNum int, currentScope *Scope)
 	// so that when variable declarations are actually parsed they don't throw an already declared error
 	arraysCopy := make(map[string]Array)
 	tuplesCopy := make(map[string]Tuple)
+	functionsCopy := make(map[string]Function) // local functions
 
 	for k, v := range (*currentScope).vars {
 		varsCopy[k] = v
@@ -569,6 +814,9 @@ func parseMultiLineExpression(lines []string, lineNum int, currentScope *Scope)
 	for k, v := range (*currentScope).tuples {
 		tuplesCopy[k] = v
 	}
+	for k, v := range (*currentScope).functions {
+		functionsCopy[k] = v
+	}
 
 	// manual copy as maps are reference types
 
@@ -580,6 +828,7 @@ func parseMultiLineExpression(lines []string, lineNum int, currentScope *Scope)
 
 	for n := lineNum; n < len(lines); n++ {
 		line := lines[n]
+		depth := bracketCount // before the line, so that lines opening blocks are included
 		for i := 0; i < len(line); i++ {
 			if line[i] == '{' {
 				bracketCount++
@@ -590,7 +839,7 @@ func parseMultiLineExpression(lines []string, lineNum int, currentScope *Scope)
 		if bracketCount == 0 {
 			break
 		}
-		if bracketCount != 1 {
+		if depth != 1 {
 			// ignore lines which are not in main scope
 			continue
 		}
@@ -600,6 +849,12 @@ func parseMultiLineExpression(lines []string, lineNum int, currentScope *Scope)
 			_ = parseArrayDeclaration(lines[n], n, currentScope)
 		} else if getItemType(lines[n], n, currentScope) == TupDeclaration {
 			_ = parseTupleDeclaration(lines[n], n, currentScope)
+		} else if getItemType(lines[n], n, currentScope) == DestructuringDeclaration {
+			_ = parseDestructuringDeclaration(lines[n], n, currentScope)
+		} else if getItemType(lines[n], n, currentScope) == ValueBlockItem && strings.Fields(lines[n])[0] == "let" {
+			declareValue(parseValueDeclaration(lines[n], n, currentScope), currentScope)
+		} else if getItemType(lines[n], n, currentScope) == FunctionDeclaration {
+			declareLocalFunction(lines, n, currentScope)
 		}
 		if exprCount >= 1 {
 			if len(strings.Trim(line, " ")) > 0 {
@@ -617,18 +872,13 @@ func parseMultiLineExpression(lines []string, lineNum int, currentScope *Scope)
 		exprCount++
 	}
 	if exprLine == -1 {
-		(*currentScope).vars = varsCopy
-		(*currentScope).arrays = arraysCopy
-		(*currentScope).tuples = tuplesCopy
-		return Expression{
-			items:    []string{},
-			dataType: IO,
-		}
+		panic(fmt.Sprintf("Line %d: found no returned value in block", lineNum+1))
 	}
 	to_return := parseExpression(expr, exprLine, currentScope)
 	(*currentScope).vars = varsCopy
 	(*currentScope).arrays = arraysCopy
 	(*currentScope).tuples = tuplesCopy
+	(*currentScope).functions = functionsCopy
 	// return maps to original
 	return to_return
 }
@@ -647,6 +897,17 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 			stringLiteral = !stringLiteral
 		}
 		switch params[i] {
+		case '<':
+			// type arguments e.g. Result<float, string>
+			if opensTypeArguments(params, i) {
+				bracketCount++
+			}
+			currentString += string(params[i])
+		case '>':
+			if bracketCount > 0 && params[i-1] != '-' {
+				bracketCount--
+			}
+			currentString += string(params[i])
 		case '(':
 			// brackets can enclose tokens e.g. tuple, function call
 			if !stringLiteral {
@@ -693,7 +954,28 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 	var tuples []Tuple
 	var paramTypes []parameterType
 
-	for _, param := range fields {
+	for index, param := range fields {
+		var defaultValue string
+		if equals := strings.Index(param, "="); equals != -1 {
+			// default value e.g. tol: float = 0.0001
+			// types can't contain =, so the first one comes before the value
+			defaultValue = strings.Trim(param[equals+1:], " ")
+			param = strings.Trim(param[:equals], " ")
+			if len(defaultValue) == 0 {
+				panic(fmt.Sprintf("Line %d: found no default value after '=' in parameter %s", lineNum+1, param))
+			}
+		}
+
+		if param[0] == '(' {
+			// destructured tuple e.g. (x, y): (float, float)
+			if len(defaultValue) != 0 {
+				panic(fmt.Sprintf("Line %d: only parameters with a numeric type, bool, byte or string can have default values", lineNum+1))
+			}
+			tuples = append(tuples, parseDestructuredParameter(param, index, lineNum))
+			paramTypes = append(paramTypes, TupleParameter)
+			continue
+		}
+
 		var name string
 		var nameEnd int
 		for i := 0; i < len(param); i++ {
@@ -706,7 +988,13 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 		if nameEnd == len(param)-1 {
 			panic(fmt.Sprintf("Line %d: found no type annotation after function parameter", lineNum+1))
 		}
-		dataType := strings.Trim(param[nameEnd+1:], " ")
+		dataType := param[nameEnd+1:]
+		isVariadic := strings.HasPrefix(dataType, "...")
+		if isVariadic {
+			// any number of arguments e.g. xs: ...float
+			dataType = dataType[3:]
+		}
+		dataType = expandAlias(dataType)
 
 		if name[len(name)-1] != ':' {
 			panic(fmt.Sprintf("Line %d: the last character of the parameter declaration %s is not a colon ':', which is required for a type annotation of the parameter", lineNum+1, name))
@@ -714,14 +1002,42 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 		ident := parseIdentifier(name, lineNum)
 
 		var isTup, isArr bool
-		if dataType[0] == '(' {
+		isFn := isFunctionType(dataType) // brackets are the parameters of the function type
+		if !isFn && dataType[len(dataType)-1] == ']' {
+			// could be an array of tuples
+			isArr = true
+		} else if !isFn && dataType[0] == '(' {
 			isTup = true
-		} else {
-			for i := 0; i < len(dataType); i++ {
-				if dataType[i] == '[' {
-					isArr = true
-				}
-			}
+		}
+		if len(defaultValue) != 0 && (isTup || isArr || isVariadic) {
+			panic(fmt.Sprintf("Line %d: only parameters with a numeric type, bool, byte or string can have default values", lineNum+1))
+		}
+
+		if isVariadic {
+			// received as an array of any length
+			if index != len(fields)-1 {
+				panic(fmt.Sprintf("Line %d: only the last parameter of a function can be variadic", lineNum+1))
+			}
+			var lengthParam string
+			if open := strings.LastIndex(dataType, "["); isArr && parseCharType(dataType[open+1]) == letter {
+				// number of arguments e.g. rest: ...float[n]
+				lengthParam = parseIdentifier(dataType[open+1:len(dataType)-1]+":", lineNum)
+				dataType = dataType[:open]
+				isArr = dataType[len(dataType)-1] == ']'
+			}
+			if isArr || isFn {
+				panic(fmt.Sprintf("Line %d: variadic parameter %s cannot take arrays or functions", lineNum+1, ident))
+			}
+			elements := parseArrayType(dataType+"[0]", lineNum)
+			elements.dimensions[0] = -1
+			arrays = append(arrays, Array{
+				identifier:  ident,
+				dataType:    elements,
+				mut:         false,
+				lengthParam: lengthParam,
+			})
+			paramTypes = append(paramTypes, VariadicParameter)
+			continue
 		}
 
 		// arrays, variable and tuple parameters put in separate slices
@@ -736,11 +1052,22 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 			tuples = append(tuples, newTup)
 			paramTypes = append(paramTypes, TupleParameter)
 		} else if isArr {
+			var lengthParam string
+			open := strings.LastIndex(dataType, "[")
+			if parseCharType(dataType[open+1]) == letter {
+				// array of any length e.g. xs: float[n]
+				lengthParam = parseIdentifier(dataType[open+1:len(dataType)-1]+":", lineNum)
+				dataType = dataType[:open] + "[0]"
+			}
 			arrT := parseArrayType(dataType, lineNum)
+			if len(lengthParam) != 0 {
+				arrT.dimensions[len(arrT.dimensions)-1] = -1
+			}
 			newArr := Array{
-				identifier: ident,
-				dataType:   arrT,
-				mut:        false,
+				identifier:  ident,
+				dataType:    arrT,
+				mut:         false,
+				lengthParam: lengthParam,
 			}
 			arrays = append(arrays, newArr)
 			paramTypes = append(paramTypes, ArrayParameter)
@@ -750,9 +1077,10 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 				panic(fmt.Sprintf("Line %d: function parameters cannot have type IO", lineNum+1))
 			}
 			newP := Variable{
-				identifier: ident,
-				dataType:   T,
-				mut:        false,
+				identifier:   ident,
+				dataType:     T,
+				mut:          false,
+				defaultValue: defaultValue,
 			}
 
 			variables = append(variables, newP)
@@ -763,10 +1091,8 @@ func parseParameters(params string, lineNum int) ([]Variable, []Array, []Tuple,
 	return variables, arrays, tuples, paramTypes
 }
 
-//	function square(x: int) -> int = {
-//	  x * x
-//	}
-func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
+func parseSignature(lines []string, lineNum int, currentScope *Scope) Function {
+	// parses the line where a function is declared, adding its parameters to currentScope
 	var allLines string
 	for _, l := range lines {
 		allLines += l
@@ -784,18 +1110,35 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 
 	var id []byte
 	for i := 0; i < len(words[1]); i++ {
-		if words[1][i] == '(' {
+		if words[1][i] == '(' || words[1][i] == '<' {
 			break
 		}
 		id = append(id, words[1][i])
 	}
 
+	var typeParams []primitiveType
+	// type parameters of an enclosing function stay in scope for local functions
+	if lt := strings.Index(line, "<"); lt != -1 && lt < strings.Index(line, "(") {
+		// generic function e.g. function sum<T: numeric>(xs: T[n]) -> T
+		gt := strings.Index(line[lt:], ">")
+		if gt == -1 {
+			panic(fmt.Sprintf("Line %d: list of type parameters opened with < but never closed", lineNum+1))
+		}
+		typeParams = parseTypeParameters(line[lt+1:lt+gt], lineNum)
+		typeParameters = make(map[string]primitiveType)
+		for _, T := range typeParams {
+			typeParameters[T.String()] = T
+		}
+		// stay in scope until the body of the function has been parsed
+	}
+
 	identifier := parseIdentifier(string(id)+":", lineNum)
 	// colon added so it doesn't throw an expected type annotation error
 
 	if _, v := (*currentScope).vars[identifier]; v {
 		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
-	} else if _, f := (*currentScope).functions[identifier]; f {
+	} else if f, ok := (*currentScope).functions[identifier]; ok && f.lineNum != lineNum {
+		// the signatures of global functions are collected before they are parsed
 		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
 	} else if _, a := (*currentScope).arrays[identifier]; a {
 		panic(fmt.Sprintf("Line %d: %s already defined in this scope", lineNum+1, id))
@@ -833,15 +1176,52 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 	pStr := string(paramsBytes[1 : len(paramsBytes)-1])
 	parameters, arrays, tuples, order := parseParameters(pStr, lineNum)
 
+	// default values are evaluated before the parameters are in scope, so they can only use constants
+	var variableCount int
+	var hasDefault bool
+	for _, kind := range order {
+		if kind == VariadicParameter {
+			// can come after parameters with default values
+			continue
+		}
+		if kind != VariableParameter || len(parameters[variableCount].defaultValue) == 0 {
+			if hasDefault {
+				panic(fmt.Sprintf("Line %d: parameters without default values must come before parameters with default values", lineNum+1))
+			}
+			if kind == VariableParameter {
+				variableCount++
+			}
+			continue
+		}
+		p := &parameters[variableCount]
+		if !constantType(p.dataType) {
+			panic(fmt.Sprintf("Line %d: only parameters with a numeric type, bool, byte or string can have default values", lineNum+1))
+		}
+		value := evaluateConstant(p.defaultValue, p.dataType, "default value of parameter "+p.identifier, lineNum, currentScope)
+		p.defaultValue = suffixedLiteral(value)
+		hasDefault = true
+		variableCount++
+	}
+
 	for _, p := range parameters {
 		(*currentScope).vars[p.identifier] = p
 	}
 
 	for _, arr := range arrays {
 		(*currentScope).arrays[arr.identifier] = arr
+		if len(arr.lengthParam) != 0 {
+			(*currentScope).vars[arr.lengthParam] = Variable{
+				identifier: arr.lengthParam,
+				dataType:   Int,
+			}
+		}
 	}
 
 	for _, tup := range tuples {
+		if tup.destructuring != nil {
+			tup.destructuring.declare(lineNum, currentScope)
+			continue
+		}
 		(*currentScope).tuples[tup.identifier] = tup
 	}
 
@@ -862,6 +1242,16 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 			// can enclose token such as tuple pattern
 			currentString += string(line[i])
 			bracketCount2++
+		case '<':
+			if opensTypeArguments(line, i) {
+				bracketCount2++
+			}
+			currentString += string(line[i])
+		case '>':
+			if bracketCount2 > 0 && line[i-1] != '-' {
+				bracketCount2--
+			}
+			currentString += string(line[i])
 		case ')':
 			currentString += string(line[i])
 			bracketCount2--
@@ -894,20 +1284,30 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 		panic(fmt.Sprintf("Line %d: expected return type annotation with '->'", lineNum+1))
 	}
 
-	typeAnnotation := afterWords[1]
+	// the return type can contain spaces e.g. (float) -> float
+	typeEnd := 1
+	for typeEnd < len(afterWords) && afterWords[typeEnd] != "=" {
+		typeEnd++
+	}
+	afterWords = append([]string{"->", strings.Join(afterWords[1:typeEnd], " ")}, afterWords[typeEnd:]...)
+	if len(afterWords) < 4 {
+		panic(fmt.Sprintf("Line %d: expected return type annotation '->', type, equals sign '=' and '{' after function identifier", lineNum+1))
+	}
+
+	typeAnnotation := expandAlias(afterWords[1])
 	var returnDomain returnDomain
 	// separate struct fields for derived, primitive, tuple return type
 	// one will get value assigned and other will take default value
 	// returnsDerived struct field dictated which is used
 
-	if typeAnnotation[0] == '(' {
+	if isFunctionType(typeAnnotation) {
+		returnDomain = primitive // brackets are the parameters of the function type
+	} else if typeAnnotation[len(typeAnnotation)-1] == ']' {
+		// checked first as the elements could be tuples
+		returnDomain = derived
+	} else if typeAnnotation[0] == '(' {
 		returnDomain = tuple
 	}
-	for i := 0; i < len(typeAnnotation); i++ {
-		if typeAnnotation[i] == '[' {
-			returnDomain = derived
-		}
-	}
 
 	var derivedReturnType ArrayType
 	var returnType primitiveType
@@ -919,7 +1319,7 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 	} else if returnDomain == tuple {
 		tuplePattern = parseTuplePattern(typeAnnotation, lineNum)
 	} else {
-		returnType = readType(afterWords[1], lineNum)
+		returnType = readType(typeAnnotation, lineNum)
 		if returnType == IO {
 			if identifier != "main" {
 				panic(fmt.Sprintf("Line %d: only the main() function can have return type IO", lineNum+1))
@@ -946,28 +1346,52 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 		panic(fmt.Sprintf("Line %d: found no returned expression from function", lineNum+1))
 	}
 
-	if returnDomain == tuple {
-		_ = parseMultiLineTupleExpression(lines, lineNum, tuplePattern, currentScope)
+	return Function{
+		typeParams:        typeParams,
+		parameters:        parameters,
+		arrays:            arrays,
+		tuples:            tuples,
+		paramsOrder:       order,
+		returnType:        returnType,
+		identifier:        identifier,
+		returnDomain:      returnDomain,
+		derivedReturnType: derivedReturnType,
+		tupleReturnType:   tuplePattern,
+		lineNum:           lineNum,
+	}
+}
+
+//	function square(x: int) -> int = {
+//	  x * x
+//	}
+func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
+	f := parseSignature(lines, lineNum, currentScope)
+	// in scope before the value is checked so that the function can call itself
+	(*currentScope).functions[f.identifier] = f
+
+	enclosingReturnType = f.returnType
+	if f.returnDomain != primitive {
+		enclosingReturnType = IO // so that ? and None etc. can't use it
+	}
+	recovers = false
+	returnedType = functionReturnType(f.returnDomain, f.returnType, f.tupleReturnType, f.derivedReturnType)
+	exprStart := strings.LastIndex(lines[lineNum], "=") + 1
+
+	checkControlFlow(lines, lineNum, f.identifier)
+	if !endsWithExpression(lines, lineNum) {
+		// value is given by return statements or if expressions, whose types are checked when they are parsed
+	} else if f.returnDomain == tuple {
+		_ = parseMultiLineTupleExpression(lines, lineNum, f.tupleReturnType, currentScope)
 		// ^ already checks pattern match
-	} else if returnDomain == derived {
+	} else if f.returnDomain == derived {
 		// parse multi-line array expression and check match to return type
 		var arrExpression ArrayExpression
 		if strings.Trim(lines[lineNum][exprStart:], " ")[0] != '{' {
-			arrExpression = parseArrayExpression(lines[lineNum][exprStart:], derivedReturnType.baseType, lineNum, currentScope)
+			arrExpression = parseArrayExpression(lines[lineNum][exprStart:], f.derivedReturnType.baseType, lineNum, currentScope)
 		} else {
-			arrExpression = parseMultiLineArrayExpression(lines, lineNum, derivedReturnType.baseType, currentScope)
-		}
-		if arrExpression.dataType.baseType != derivedReturnType.baseType {
-			panic(fmt.Sprintf("Line %d: expected return base type %v but found %v", lineNum+1, derivedReturnType.baseType, arrExpression.dataType.baseType))
-		}
-		if len(arrExpression.dataType.dimensions) != len(derivedReturnType.dimensions) {
-			panic(fmt.Sprintf("Line %d: expected returned array with %d dimensions but found %d dimensions", lineNum+1, len(derivedReturnType.dimensions), len(arrExpression.dataType.dimensions)))
-		}
-		for i := 0; i < len(derivedReturnType.dimensions); i++ {
-			if derivedReturnType.dimensions[i] != arrExpression.dataType.dimensions[i] {
-				panic(fmt.Sprintf("Line %d: dimensions size of array returned from function does not match return type annotation", lineNum+1))
-			}
+			arrExpression = parseMultiLineArrayExpression(lines, lineNum, f.derivedReturnType.baseType, currentScope)
 		}
+		_ = copyArray(arrExpression, f.derivedReturnType, lineNum)
 	} else {
 		// returns primitive type
 		var expression Expression
@@ -976,25 +1400,11 @@ func parseFunction(lines []string, lineNum int, currentScope *Scope) Function {
 		} else {
 			expression = parseMultiLineExpression(lines, lineNum, currentScope)
 		}
-		if expression.dataType != returnType {
-			panic(fmt.Sprintf("Line %d: expected return type %v but found return type %v", lineNum+1, returnType, expression.dataType))
+		if expression.dataType != f.returnType {
+			panic(fmt.Sprintf("Line %d: expected return type %v but found return type %v", lineNum+1, f.returnType, expression.dataType))
 		}
 	}
 
-	f := Function{
-		parameters:        parameters,
-		arrays:            arrays,
-		tuples:            tuples,
-		paramsOrder:       order,
-		returnType:        returnType,
-		identifier:        identifier,
-		returnDomain:      returnDomain,
-		derivedReturnType: derivedReturnType,
-		tupleReturnType:   tuplePattern,
-	}
-
-	(*currentScope).functions[f.identifier] = f
-
 	return f
 }
 
@@ -1025,156 +1435,230 @@ func parseFunctionCall(functionCall string, lineNum int, currentScope *Scope) Fu
 		panic(fmt.Sprintf("Line %d: brackets opened but never closed", lineNum+1))
 	}
 
-	fn, ok := currentScope.functions[ident]
+	fn, ok := lookupFunction(ident, currentScope)
 
 	if !ok {
 		panic(fmt.Sprintf("Line %d: function %s not in scope", lineNum+1, ident+"()"))
 	}
 
-	if len(params) > 2 { // remove brackets in case of function with more than zero parameters
+	if len(params) >= 2 { // remove brackets
 		params = params[1 : len(params)-1]
 	}
+	parameterExprs, variadicExprs := matchArguments(fn, splitTopLevel(params, ','), lineNum)
 
-	bracketCount = 0
-	var parameterExprs []string
-	var currentParam string
-
-	var stringLiteral, byteLiteral bool
-
-	// tokenise list of parameters
-	for i := 0; i < len(params); i++ {
-		if stringLiteral {
-			currentParam += string(params[i])
-			if params[i] == 34 {
-				stringLiteral = false
-			}
-			goto a
-		} else if byteLiteral {
-			currentParam += string(params[i])
-			if params[i] == 39 {
-				byteLiteral = false
-			}
-			goto a
-		}
-		switch params[i] {
-		case 34:
-			stringLiteral = true
-			currentParam += string(params[i])
-		case 39:
-			byteLiteral = true
-			currentParam += string(params[i])
-		case '(':
-			bracketCount++
-			currentParam += string(params[i])
-		case ')':
-			bracketCount--
-			currentParam += string(params[i])
-		case ',':
-			if bracketCount == 0 {
-				parameterExprs = append(parameterExprs, currentParam)
-				currentParam = ""
-			}
-		case ' ':
-			if len(currentParam) != 0 {
-				currentParam += string(params[i])
-			}
-		default:
-			currentParam += string(params[i])
-		}
-
-	a:
-		if i == len(params)-1 {
-			parameterExprs = append(parameterExprs, currentParam)
-			break
-		}
-	}
-
-	// match parameter list found to expected parameters of the function
-
-	if len(parameterExprs) != len(fn.paramsOrder) {
-		panic(fmt.Sprintf("Line %d: function %s takes %d arguments but %d were given", lineNum+1, fn.identifier, len(fn.parameters), len(parameterExprs)))
-	}
-
-	var parameterExpressions []Expression
-	var arrays []Array
-	var tuples []Tuple
-
+	var arguments []Transpileable
 	var variableCount, arrayCount, tupleCount int
+	bindings := make(map[primitiveType]primitiveType) // type arguments of generic functions
 
-	for i := 0; i < len(fn.paramsOrder); i++ {
+	for i := 0; i < len(parameterExprs); i++ {
 		// check that order and types of parameters matches expected order of
 		// derived/primitive-typed parameters
 		if fn.paramsOrder[i] == VariableParameter {
+			expected := substitute(fn.parameters[variableCount].dataType, bindings)
+			if isTypeParameter(expected) && isTupleLiteral(parameterExprs[i]) {
+				// the type of a tuple literal is inferred from its elements
+				expected = literalTupleType(strings.Trim(parameterExprs[i], " "), lineNum, currentScope)
+			}
+			if isTuple(expected) {
+				// type parameter which takes a tuple
+				tup, _ := expected.defined()
+				tupleExpression := parseTupleExpression(parameterExprs[i], TuplePattern{dataTypes: tup.elements}, lineNum, currentScope)
+				_ = unify(fn.parameters[variableCount].dataType, expected, bindings)
+				arguments = append(arguments, tupleExpression)
+				variableCount++
+				continue
+			}
 			// match variable parameter type
-			expression := parseExpression(parameterExprs[i], lineNum, currentScope)
-			if expression.dataType != fn.parameters[variableCount].dataType {
-				panic(fmt.Sprintf("Line %d: cannot use expression of type %v as argument of type %v", lineNum+1, expression.dataType.String(), fn.parameters[i].dataType.String()))
+			expression := parseContextualExpression(parameterExprs[i], fn.parameters[variableCount].dataType, lineNum, currentScope)
+			if !unify(fn.parameters[variableCount].dataType, expression.dataType, bindings) {
+				panic(fmt.Sprintf("Line %d: cannot use expression of type %v as argument of type %v", lineNum+1, expression.dataType.String(), substitute(fn.parameters[variableCount].dataType, bindings).String()))
 			}
-			parameterExpressions = append(parameterExpressions, expression)
+			arguments = append(arguments, expression)
 			variableCount++
 		} else if fn.paramsOrder[i] == ArrayParameter {
-			if strings.Trim(parameterExprs[i], " ")[0] == '[' {
-				panic(fmt.Sprintf("Line %d: cannot use array literal as function parameter. Try making a variable (with a type annotation) and passing it instead", lineNum+1))
-			}
-
-			for j := 0; j < len(parameterExprs[i]); j++ {
-				if parameterExprs[i][j] == '(' {
-					panic(fmt.Sprintf("Line %d: function calls returning non-primitive types are not supported as function parameters", lineNum+1))
-				}
-			}
 			// match derived parameter type
-			expectedType := fn.arrays[arrayCount].dataType.baseType
-			arrayExpression := parseArrayExpression(parameterExprs[i], expectedType, lineNum, currentScope)
-			if arrayExpression.dataType.baseType == fn.arrays[arrayCount].dataType.baseType {
-				if len(arrayExpression.dataType.dimensions) != len(fn.arrays[arrayCount].dataType.dimensions) {
-					panic(fmt.Sprintf("Line %d: expression does not have same number of dimensions as array parameter", lineNum+1))
-				}
-				for i := 0; i < len(arrayExpression.dataType.dimensions); i++ {
-					if arrayExpression.dataType.dimensions[i] != fn.arrays[arrayCount].dataType.dimensions[i] {
-						panic(fmt.Sprintf("Line %d: expression does not have same dimension size as array parameter", lineNum+1))
-					}
-				}
-			} else {
+			expectedType := fn.arrays[arrayCount].dataType
+			baseType := substitute(expectedType.baseType, bindings)
+			if isTypeParameter(baseType) && parameterExprs[i][0] == '[' {
+				// the type of the elements of a literal is inferred from its first element
+				baseType = literalBaseType(parameterExprs[i], lineNum, currentScope)
+			}
+			arrayExpression := parseArrayExpression(parameterExprs[i], baseType, lineNum, currentScope)
+			if !unify(expectedType.baseType, arrayExpression.dataType.baseType, bindings) {
 				panic(fmt.Sprintf("Line %d: expression does not have same base type as array parameter", lineNum+1))
 			}
+			// checked with the base type of the argument as the parameter's can be a type parameter
+			arrayExpression = copyArray(arrayExpression, ArrayType{
+				baseType:   arrayExpression.dataType.baseType,
+				dimensions: expectedType.dimensions,
+			}, lineNum)
 
-			arr := Array{
-				identifier: strings.Trim(parameterExprs[i], " "),
-				dataType:   arrayExpression.dataType,
+			if expectedType.dimensions[0] == -1 {
+				// parameter takes arrays of any length so it is passed as a slice
+				arguments = append(arguments, SliceArgument{array: arrayExpression})
+			} else {
+				arguments = append(arguments, arrayExpression)
 			}
-			arrays = append(arrays, arr)
 			arrayCount++
 		} else {
-			if strings.Trim(parameterExprs[i], " ")[0] == '(' {
-				panic(fmt.Sprintf("Line %d: cannot use tuple literal as function parameter. Try making a variable (with a type annotation) and passing it instead", lineNum+1))
-			}
-
-			for j := 0; j < len(parameterExprs[i]); j++ {
-				if parameterExprs[i][j] == '(' {
-					panic(fmt.Sprintf("Line %d: function calls returning non-primitive types are not supported as function parameters", lineNum+1))
-				}
-			}
-
 			// match tuple type
 			expectedPattern := fn.tuples[tupleCount].pattern
-			_ = parseTupleExpression(parameterExprs[i], expectedPattern, lineNum, currentScope)
-			// ^ already checks that it matches the pattern
-			tup := Tuple{
-				identifier: strings.Trim(parameterExprs[i], " "),
-				pattern:    expectedPattern,
+			if expected := substitute(tupleType(expectedPattern), bindings); containsTypeParameter(expected) {
+				// elements which are type parameters are inferred from the argument
+				var found primitiveType
+				if isTupleLiteral(parameterExprs[i]) {
+					found = literalTupleType(strings.Trim(parameterExprs[i], " "), lineNum, currentScope)
+				} else {
+					found = parseExpression(parameterExprs[i], lineNum, currentScope).dataType
+				}
+				if !unify(tupleType(expectedPattern), found, bindings) {
+					panic(fmt.Sprintf("Line %d: cannot use expression of type %v as argument of type %v", lineNum+1, found, substitute(tupleType(expectedPattern), bindings)))
+				}
+				tup, _ := found.defined()
+				expectedPattern = TuplePattern{dataTypes: tup.elements}
 			}
-			tuples = append(tuples, tup)
+			tupleExpression := parseTupleExpression(parameterExprs[i], expectedPattern, lineNum, currentScope)
+			// ^ already checks that it matches the pattern
+			arguments = append(arguments, tupleExpression)
 			tupleCount++
 		}
 	}
 
+	if isVariadic(fn) {
+		variadic := parseVariadicArguments(fn.arrays[arrayCount], variadicExprs, bindings, lineNum, currentScope)
+		arguments = append(arguments, variadic...)
+	}
+
+	if len(fn.typeParams) != 0 {
+		checkBindings(fn, bindings, lineNum)
+	}
+
+	returnType := fn.returnType
+	if fn.returnDomain == derived {
+		returnType = arrayTypeID(fn.derivedReturnType)
+	} else if fn.returnDomain == tuple {
+		returnType = tupleType(fn.tupleReturnType)
+	}
+
 	return FunctionCall{
 		functionName: ident,
-		parameters:   parameterExpressions,
-		arrays:       arrays,
-		tuples:       tuples,
-		order:        fn.paramsOrder,
+		arguments:    arguments,
+		dataType:     substitute(returnType, bindings),
+	}
+}
+
+func matchArguments(fn Function, arguments []string, lineNum int) ([]string, []string) {
+	// finds the argument given for each parameter, either by position or by name e.g. tol: 0.001
+	// parameters with default values can be left out, in which case the default value is used
+	// any arguments after the other parameters are returned separately for the variadic parameter
+	names, defaults := parameterNames(fn)
+	fixed := len(fn.paramsOrder)
+	if isVariadic(fn) {
+		fixed--
+	}
+	matched := make([]string, fixed)
+	var variadic []string
+	var named bool
+	for i, argument := range arguments {
+		if name, value, ok := namedArgument(argument); ok {
+			index := slices.Index(names, name)
+			if index == -1 {
+				panic(fmt.Sprintf("Line %d: function %s has no parameter called %s", lineNum+1, fn.identifier, name))
+			}
+			if index == fixed {
+				panic(fmt.Sprintf("Line %d: arguments of variadic parameter %s cannot be given by name", lineNum+1, name))
+			}
+			if len(matched[index]) != 0 {
+				panic(fmt.Sprintf("Line %d: parameter %s of function %s is given more than once", lineNum+1, name, fn.identifier))
+			}
+			if len(value) == 0 {
+				panic(fmt.Sprintf("Line %d: found no value for argument %s of function %s", lineNum+1, name, fn.identifier))
+			}
+			matched[index] = value
+			named = true
+			continue
+		}
+		if named {
+			panic(fmt.Sprintf("Line %d: positional arguments must come before named arguments", lineNum+1))
+		}
+		if len(argument) == 0 {
+			panic(fmt.Sprintf("Line %d: argument %d of function %s is empty", lineNum+1, i+1, fn.identifier))
+		}
+		if i >= fixed {
+			if !isVariadic(fn) {
+				panic(fmt.Sprintf("Line %d: function %s takes %d arguments but %d were given", lineNum+1, fn.identifier, len(fn.paramsOrder), len(arguments)))
+			}
+			variadic = append(variadic, argument)
+			continue
+		}
+		matched[i] = argument
+	}
+
+	for i, argument := range matched {
+		if len(argument) != 0 {
+			continue
+		}
+		if len(defaults[i]) == 0 {
+			name := names[i]
+			if len(name) == 0 {
+				name = fmt.Sprintf("%d", i+1)
+			}
+			panic(fmt.Sprintf("Line %d: missing argument for parameter %s of function %s", lineNum+1, name, fn.identifier))
+		}
+		matched[i] = defaults[i]
+	}
+	return matched, variadic
+}
+
+func isVariadic(fn Function) bool {
+	return len(fn.paramsOrder) != 0 && fn.paramsOrder[len(fn.paramsOrder)-1] == VariadicParameter
+}
+
+func parameterNames(fn Function) ([]string, []string) {
+	// name and default value of each parameter in order, destructured tuples have no name
+	var names, defaults []string
+	var variableCount, arrayCount, tupleCount int
+	for _, kind := range fn.paramsOrder {
+		switch kind {
+		case VariableParameter:
+			names = append(names, fn.parameters[variableCount].identifier)
+			defaults = append(defaults, fn.parameters[variableCount].defaultValue)
+			variableCount++
+		case ArrayParameter, VariadicParameter:
+			names = append(names, fn.arrays[arrayCount].identifier)
+			defaults = append(defaults, "")
+			arrayCount++
+		default:
+			var name string
+			if fn.tuples[tupleCount].destructuring == nil {
+				name = fn.tuples[tupleCount].identifier
+			}
+			names = append(names, name)
+			defaults = append(defaults, "")
+			tupleCount++
+		}
+	}
+	return names, defaults
+}
+
+func namedArgument(argument string) (string, string, bool) {
+	// name: value
+	colon := strings.Index(argument, ":")
+	if colon <= 0 || strings.HasPrefix(argument[colon:], "::") {
+		// :: is used for the variants of enums
+		return "", "", false
+	}
+	name := strings.Trim(argument[:colon], " ")
+	if len(name) == 0 || parseCharType(name[0]) != letter {
+		return "", "", false
+	}
+	for i := 0; i < len(name); i++ {
+		if parseCharType(name[i]) == other {
+			// e.g. record literal or lambda
+			return "", "", false
+		}
 	}
+	return name, strings.Trim(argument[colon+1:], " "), true
 }
 
 func parseIfStatement(lineNum int, lines []string, currentScope *Scope) IfStatement {
@@ -1277,6 +1761,9 @@ func parseSelection(lineNum int, lines []string, currentScope *Scope) SelectionS
 			items:    []string{},
 			dataType: Bool,
 		}
+	} else if T == ElseIf {
+		// only checked when the branches before aren't taken
+		condition = conditionally(func() Expression { return parseExpression(expr, lineNum, currentScope) })
 	} else {
 		condition = parseExpression(expr, lineNum, currentScope)
 	}
@@ -1294,15 +1781,11 @@ func parseSelection(lineNum int, lines []string, currentScope *Scope) SelectionS
 func parseAssignment(lines []string, lineNum int, currentScope *Scope) Assignment {
 	// parses assignment to variables only, tuples and arrays have seperate functions
 	line := lines[lineNum]
-	words := strings.Fields(line)
-
-	if len(words) < 3 {
-		panic(fmt.Sprintf("Line %d: invalid assignment", lineNum+1))
-	}
+	identifier, operator, expr := splitAssignment(line, lineNum)
 
 	// check that variable is in scope and that expression matches correct type:
 
-	v, ok := (currentScope).vars[words[0]]
+	v, ok := (currentScope).vars[identifier]
 	if !ok {
 		panic(fmt.Sprintf("Line %d: first token of assignment does not match any variables in current scope", lineNum+1))
 	} else {
@@ -1311,33 +1794,71 @@ func parseAssignment(lines []string, lineNum int, currentScope *Scope) Assignmen
 		}
 	}
 
-	if words[1] != "=" {
-		panic(fmt.Sprintf("Line %d: invalid assignment: equals sign must come directly after variable", lineNum+1))
+	if isIncrement(line) {
+		checkAssignmentOperator(operator, v.dataType, v.dataType, lineNum)
+		return Assignment{
+			v:        v,
+			operator: operator,
+		}
 	}
 
-	var exprStart int
+	expression := parseContextualExpression(expr, v.dataType, lineNum, currentScope)
 
-	for i := 0; i < len(line); i++ {
-		if line[i] == '=' {
-			exprStart = i + 1
-			break
-		}
+	if expression.dataType != v.dataType {
+		panic(fmt.Sprintf("Line %d: cannot assign expression of type %v to variable of type %v", lineNum+1, expression.dataType, v.dataType))
 	}
+	checkAssignmentOperator(operator, v.dataType, expression.dataType, lineNum)
 
-	if exprStart == 0 || exprStart == len(line)-1 {
-		panic(fmt.Sprintf("Line %d: found no expression in assignment to variable %s", lineNum+1, v.identifier))
+	return Assignment{
+		v:        v,
+		e:        expression,
+		operator: operator,
 	}
+}
 
-	expr := line[exprStart:]
-	expression := parseExpression(expr, lineNum, currentScope)
+func splitAssignment(line string, lineNum int) (string, string, string) {
+	// splits an assignment into what is assigned to, the operator and the expression
+	// e.g. x += 1 gives x, += and 1, and x++ gives x, ++ and no expression
+	trimmed := strings.Trim(line, " ")
+	if isIncrement(trimmed) {
+		return trimmed[:len(trimmed)-2], trimmed[len(trimmed)-2:], ""
+	}
 
-	if expression.dataType != v.dataType {
-		panic(fmt.Sprintf("Line %d: cannot assign expression of type %v to variable of type %v", lineNum+1, expression.dataType, v.dataType))
+	equals := strings.Index(trimmed, "=")
+	if equals == -1 {
+		panic(fmt.Sprintf("Line %d: found no equals sign in assignment", lineNum+1))
+	}
+	left, operator := trimmed[:equals], "="
+	if equals != 0 && strings.ContainsRune("+-*/%", rune(trimmed[equals-1])) {
+		// compound assignment e.g. +=
+		left, operator = trimmed[:equals-1], trimmed[equals-1:equals+1]
 	}
+	left = strings.Trim(left, " ")
+	if len(left) == 0 {
+		panic(fmt.Sprintf("Line %d: found nothing to assign to before %s", lineNum+1, operator))
+	}
+	if len(strings.Trim(trimmed[equals+1:], " ")) == 0 {
+		panic(fmt.Sprintf("Line %d: found no expression in assignment to %s", lineNum+1, left))
+	}
+	return left, operator, trimmed[equals+1:]
+}
 
-	return Assignment{
-		v: v,
-		e: expression,
+func isIncrement(line string) bool {
+	// x++ or x--
+	trimmed := strings.Trim(line, " ")
+	return len(trimmed) > 2 && len(strings.Fields(trimmed)) == 1 && (strings.HasSuffix(trimmed, "++") || strings.HasSuffix(trimmed, "--"))
+}
+
+func checkAssignmentOperator(operator string, left, right primitiveType, lineNum int) {
+	// checks that the operator of a compound assignment can be used with the types
+	switch operator {
+	case "=":
+	case "++", "--":
+		if !integerType(left) {
+			panic(fmt.Sprintf("Line %d: %s can only be used with integer types but found type %v", lineNum+1, operator, left))
+		}
+	default:
+		_ = binaryOperatorType(operator[:1], left, right, lineNum)
 	}
 }
 
@@ -1367,17 +1888,26 @@ func declarationType(line string, lineNum int) itemType {
 	if words[1] == "mut" {
 		identifierIndex = 2
 	}
+	if isDestructuring(line) {
+		return DestructuringDeclaration
+	}
 	typeIndex := identifierIndex + 1
-	typeWord := words[typeIndex]
+	typeWord := typeAnnotation(line)
+	if len(typeWord) == 0 {
+		typeWord = words[typeIndex]
+	}
 
-	if typeWord[0] == '(' {
-		return TupDeclaration
+	if isFunctionType(typeWord) {
+		return VariableDeclaration
 	}
 
-	for i := 0; i < len(typeWord); i++ {
-		if typeWord[i] == '[' {
-			return ArrDeclaration
-		}
+	if typeWord[len(typeWord)-1] == ']' {
+		// checked first because of arrays of tuples
+		return ArrDeclaration
+	}
+
+	if typeWord[0] == '(' {
+		return TupDeclaration
 	}
 	return VariableDeclaration
 }
@@ -1387,24 +1917,27 @@ func assignmentType(line string, lineNum int, currentScope *Scope) itemType {
 	// helper function for parseScope so that it knows which function to call
 	// this function doesn't necessarily check that a line is syntactically valid
 	// it just needs to correctly identify which type of line it is
-	words := strings.Fields(line)
-	if len(words) == 0 {
+	if len(strings.Fields(line)) == 0 {
 		panic("assignmentType() called on empty line %d")
 	}
+	left, _, _ := splitAssignment(line, lineNum)
 
 	// check for assignment to scoped identifier
-	_, isVar := (*currentScope).vars[words[0]]
-	_, isArray := (*currentScope).arrays[words[0]]
-	_, isTuple := (*currentScope).tuples[words[0]]
+	_, isVar := (*currentScope).vars[left]
+	_, isArray := (*currentScope).arrays[left]
+	_, isTuple := (*currentScope).tuples[left]
 	if isVar {
 		return VariableAssignment
 	} else if isArray {
 		return ArrAssignment
 	} else if isTuple {
 		return TupAssignment
+	} else if isMemberAccess(left, currentScope) {
+		// e.g. t.0 += 1 or grid[i][j] = 0
+		return MemberAssignmentItem
 	}
-	for i := 0; i < len(words[0]); i++ {
-		if words[0][i] == '[' {
+	for i := 0; i < len(left); i++ {
+		if left[i] == '[' {
 			if i == 0 {
 				panic(fmt.Sprintf("Line %d: unexpected token [ at start of line", lineNum+1))
 			}
@@ -1413,12 +1946,15 @@ func assignmentType(line string, lineNum int, currentScope *Scope) itemType {
 			return ArrIndexAssignment
 		}
 	}
-	panic(fmt.Sprintf("Line %d: assignment to variable %s not in scope", lineNum+1, words[0]))
+	panic(fmt.Sprintf("Line %d: assignment to variable %s not in scope", lineNum+1, left))
 }
 
 func returnStatementType(l string, lineNum int, currentScope *Scope) itemType {
 	// identify whether a line is a primitive, derived or tuple return statement
 	line := strings.Trim(l, " ")
+	if isRecordLiteral(line) || isEnumValue(line) || isMatchExpression(line) || isLambda(line) || isConversion(line) || isComplexFunction(line) {
+		return ReturnStatement
+	}
 	var currentString string
 	var stringCount int
 Loop:
@@ -1450,7 +1986,7 @@ Loop:
 	// check if return statement contains function call
 
 	if len(currentString) != 0 {
-		if fn, ok := (*currentScope).functions[currentString]; ok {
+		if fn, ok := lookupFunction(currentString, currentScope); ok {
 			// match to return type of called function
 			if fn.returnDomain == derived {
 				return DerivedReturnStatement
@@ -1472,9 +2008,8 @@ Loop:
 		panic("returnStatementType() called on blank line")
 	}
 
-	if _, ok := (*currentScope).vars[words[0]]; ok {
-		return ReturnStatement
-	} else if _, ok := (*currentScope).arrays[words[0]]; ok {
+	if len(words) == 1 && isArrayValue(words[0], lineNum, currentScope) {
+		// arrays can also be compared so only a single array is a derived return statement
 		return DerivedReturnStatement
 	}
 	// first token not array literal or identifier -> must be some primitive literal
@@ -1492,7 +2027,21 @@ func getItemType(line string, lineNum int, currentScope *Scope) itemType {
 	switch words[0] {
 	case "function":
 		return FunctionDeclaration
+	case "type":
+		if isTypeAlias(line) {
+			return TypeDefinitionItem
+		}
+		return RecordDeclarationItem
+	case "newtype":
+		return TypeDefinitionItem
+	case "enum":
+		return EnumDeclarationItem
+	case "const":
+		return ConstantDeclarationItem
 	case "let":
+		if _, ok := blockValue(line); ok {
+			return ValueBlockItem
+		}
 		return declarationType(line, lineNum)
 	case "if":
 		return SelectionIf
@@ -1500,6 +2049,13 @@ func getItemType(line string, lineNum int, currentScope *Scope) itemType {
 		return LoopStatement
 	case "break", "continue":
 		return LoopBreakStatement
+	case "return":
+		if _, ok := blockValue(line); ok {
+			return ValueBlockItem
+		}
+		return EarlyReturnStatement
+	case "{":
+		return ValueBlockItem
 	case "}":
 		if len(words) == 1 {
 			return ScopeClose
@@ -1516,12 +2072,23 @@ func getItemType(line string, lineNum int, currentScope *Scope) itemType {
 		}
 		return SelectionElse
 	default:
+		if isLoopLabel(words[0]) {
+			// 'outer: loop ...
+			return LoopStatement
+		}
 		if len(words) == 1 && words[0] == "}" {
 			return ScopeClose
 		}
 		if !isStatement(line) {
+			if currentScope.target != nil {
+				// value of an if expression or block
+				return BlockResultItem
+			}
 			return returnStatementType(line, lineNum, currentScope)
 		}
+		if _, ok := blockValue(line); ok {
+			return ValueBlockItem
+		}
 
 		for i := 0; i < len(line); i++ {
 			if line[i] == '!' {
@@ -1529,8 +2096,11 @@ func getItemType(line string, lineNum int, currentScope *Scope) itemType {
 			}
 		}
 
+		if isIncrement(line) {
+			return assignmentType(line, lineNum, currentScope)
+		}
 		for _, word := range words {
-			if word == "=" {
+			if _, ok := assignmentOperators()[word]; ok {
 				return assignmentType(line, lineNum, currentScope)
 			}
 		}
@@ -1576,6 +2146,58 @@ func typeOfItem(item Transpileable) string {
 	return T
 }
 
+func parseGlobalDeclarations(lines []string, globalScope *Scope) []Transpileable {
+	// parses the types and constants in the global scope and then collects the signature
+	// of every function, so that functions can be used before they are declared
+	var items []Transpileable
+	var functions []int // lines where functions are declared
+	var bracketCount int
+	globalConstants = make(map[string]bool)
+	for _, line := range lines {
+		// constants can only use the constants declared before them, so a later one gets a clear error
+		if words := strings.Fields(line); len(words) > 1 && words[0] == "const" {
+			globalConstants[strings.TrimSuffix(words[1], ":")] = true
+		}
+	}
+	for n := 0; n < len(lines); n++ {
+		line := lines[n]
+		words := strings.Fields(line)
+		if bracketCount != 0 || len(words) == 0 {
+			bracketCount += strings.Count(line, "{") - strings.Count(line, "}")
+			continue
+		}
+
+		switch words[0] {
+		case "type", "newtype", "enum", "const":
+			switch getItemType(line, n, globalScope) {
+			case RecordDeclarationItem:
+				items = append(items, parseRecordDeclaration(line, n))
+			case EnumDeclarationItem:
+				items = append(items, parseEnumDeclaration(line, n))
+			case ConstantDeclarationItem:
+				items = append(items, parseConstantDeclaration(line, n, globalScope))
+			case TypeDefinitionItem:
+				items = append(items, parseTypeDefinition(line, n))
+			}
+		case "function":
+			functions = append(functions, n)
+		}
+		bracketCount += strings.Count(line, "{") - strings.Count(line, "}")
+	}
+
+	for _, n := range functions {
+		// parameters are added to a copy of the scope
+		signatureScope := childScope(globalScope, FunctionScope)
+		f := parseSignature(lines, n, &signatureScope)
+		typeParameters = nil
+		if _, ok := globalScope.functions[f.identifier]; ok {
+			panic(fmt.Sprintf("Line %d: %s already defined in this scope", n+1, f.identifier))
+		}
+		globalScope.functions[f.identifier] = f
+	}
+	return items
+}
+
 func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope) Scope {
 	// parses whole scope parsing all lines in scope as items
 	// TODO: copy tuple maps etc.
@@ -1613,6 +2235,17 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 
 	}
 
+	if scopeType == LoopScope && parent != nil {
+		// label of the loop is given to the scope containing the loop variable
+		newScope.label = parent.label
+	}
+	if scopeType == BlockScope && parent != nil {
+		newScope.target = parent.target
+	}
+	if scopeType == Global {
+		newScope.items = parseGlobalDeclarations(lines, &newScope)
+	}
+
 	// NOTE: should be called inluding opening line
 	scopeEnd := findScopeEnd(lines, lineNum)
 
@@ -1645,6 +2278,9 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 		if !inMainScope && T != ScopeClose {
 			continue
 		}
+		if T == SelectionIf && newScope.scopeType != Global && isValueIf(lines, n) {
+			T = ValueBlockItem
+		}
 
 		// match item type to how to parse the line:
 		switch T {
@@ -1669,7 +2305,23 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 				panic(fmt.Sprintf("line %d: global tuples are not allowed in Stella", n))
 			}
 
+		case DestructuringDeclaration:
+			if newScope.scopeType == Global {
+				panic(fmt.Sprintf("Line %d: global variables are not allowed in Stella", n+1))
+			}
+			declaration := parseDestructuringDeclaration(line, n, &newScope)
+			newScope.items = append(newScope.items, declaration)
+
 		case FunctionDeclaration:
+			if newScope.scopeType != Global {
+				local := parseLocalFunction(lines, n, &newScope)
+				newScope.functions[local.fn.identifier] = local.fn
+				newScope.items = append(newScope.items, local)
+				// the closing } is part of the local function rather than this scope
+				n = findScopeEnd(lines, n)
+				bracketCount--
+				break
+			}
 			subScope := Scope{}
 
 			// copy manually as maps are reference types
@@ -1690,16 +2342,17 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 
 			subScope.tuples = make(map[string]Tuple)
 			for k, v := range newScope.tuples {
-				newScope.tuples[k] = v
+				subScope.tuples[k] = v
 			}
 
 			fn := parseFunction(lines, n, &subScope)
 			newScope.functions[fn.identifier] = fn
-			newScope.items = append(newScope.items, fn)
 
 			subScope = parseScope(lines, n, FunctionScope, &subScope)
+			fn.recovers = recovers // only known once the body has been parsed
 			// kinda scuffed but I don't think this causes any problems
-			newScope.items = append(newScope.items, subScope)
+			newScope.items = append(newScope.items, fn, subScope)
+			typeParameters = nil // type parameters go out of scope at the end of the function
 			ended := findScopeEnd(lines, n)
 			n = ended - 1
 
@@ -1731,6 +2384,35 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 				panic(fmt.Sprintf("Line %d: global arrays are not allowed in Stella", n))
 			}
 
+		case MemberAssignmentItem:
+			assignment := parseMemberAssignment(lines[n], n, &newScope)
+			newScope.items = append(newScope.items, assignment)
+			if newScope.scopeType == Global {
+				panic(fmt.Sprintf("Line %d: global variables are not allowed in Stella", n+1))
+			}
+
+		case EarlyReturnStatement:
+			if newScope.scopeType == Global {
+				panic(fmt.Sprintf("Line %d: found return statement outside function", n+1))
+			}
+			ret := parseEarlyReturn(line, n, &newScope)
+			newScope.items = append(newScope.items, ret)
+
+		case ValueBlockItem:
+			if newScope.scopeType == Global {
+				panic(fmt.Sprintf("Line %d: global variables are not allowed in Stella", n+1))
+			}
+			v, last := parseValueBlockItem(lines, n, end, &newScope)
+			newScope.items = append(newScope.items, v)
+			if v.multiLine {
+				// closing } is parsed as a scope closer
+				n = last - 1
+			}
+
+		case BlockResultItem:
+			result := parseBlockResult(line, n, &newScope)
+			newScope.items = append(newScope.items, result)
+
 		case ReturnStatement:
 			if scopeType != FunctionScope {
 				panic(fmt.Sprintf("Line %d: Found return statement outside function scope", n+1))
@@ -1746,7 +2428,7 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 			// find expected type so that the statement can be parsed in case it is a literal
 			expectedType := findExpectedType(lines, n)
 
-			arrExpr := parseArrayExpression(line, expectedType, lineNum, &newScope)
+			arrExpr := copyArray(parseArrayExpression(line, expectedType.baseType, n, &newScope), expectedType, n)
 			newScope.items = append(newScope.items, arrExpr)
 
 		case TupleReturnStatement:
@@ -1785,7 +2467,7 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 
 			subScope.tuples = make(map[string]Tuple)
 			for k, v := range newScope.tuples {
-				newScope.tuples[k] = v
+				subScope.tuples[k] = v
 			}
 
 			ifStatement := parseSelection(n, lines, &subScope)
@@ -1847,7 +2529,7 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 
 			subScope.tuples = make(map[string]Tuple)
 			for k, v := range newScope.tuples {
-				newScope.tuples[k] = v
+				subScope.tuples[k] = v
 			}
 
 			ifStatement := parseSelection(n, lines, &subScope)
@@ -1884,39 +2566,26 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 
 			subScope.tuples = make(map[string]Tuple)
 			for k, v := range newScope.tuples {
-				newScope.tuples[k] = v
+				subScope.tuples[k] = v
 			}
+			subScope.scopeType = newScope.scopeType
+			subScope.parent = &newScope
 
 			loop := parseLoop(lines[n], n, &subScope)
 			newScope.items = append(newScope.items, loop)
+			subScope.label = loop.label
 
-			subScope = parseScope(lines, n, LoopScope, &newScope)
+			// subScope contains the loop variable if there is one
+			subScope = parseScope(lines, n, LoopScope, &subScope)
 			newScope.items = append(newScope.items, subScope)
 			ended := findScopeEnd(lines, n)
 			n = ended - 1
 
 		case LoopBreakStatement:
-			b := parseBreak(lines[n], n)
+			// also checks that break statement is inside a loop with the label
+			b := parseBreak(lines[n], n, &newScope)
 			newScope.items = append(newScope.items, b)
 
-			currentScope := newScope
-
-			// check that break statement is inside at least one loop
-
-			for {
-				if currentScope.scopeType == Global {
-					panic(fmt.Sprintf("Line %d: found break/continue statement not inside any loop", lineNum+1))
-				} else if currentScope.scopeType == LoopScope {
-					break
-				}
-				if currentScope.parent == nil {
-					panic(fmt.Sprintf("Line %d: found break/continue statement not inside any loop", lineNum+1))
-				} else if (*currentScope.parent).scopeType == LoopScope {
-					break
-				}
-				currentScope = *currentScope.parent
-			}
-
 		case MacroItem:
 
 			if newScope.scopeType == Global {
@@ -1929,6 +2598,30 @@ func parseScope(lines []string, lineNum int, scopeType ScopeType, parent *Scope)
 			}
 			newScope.items = append(newScope.items, macro)
 
+		case RecordDeclarationItem:
+			if newScope.scopeType != Global {
+				panic(fmt.Sprintf("Line %d: record types can only be declared in the global scope", n+1))
+			}
+			// already parsed by parseGlobalDeclarations()
+
+		case EnumDeclarationItem:
+			if newScope.scopeType != Global {
+				panic(fmt.Sprintf("Line %d: enums can only be declared in the global scope", n+1))
+			}
+			// already parsed by parseGlobalDeclarations()
+
+		case ConstantDeclarationItem:
+			if newScope.scopeType != Global {
+				panic(fmt.Sprintf("Line %d: constants can only be declared in the global scope", n+1))
+			}
+			// already parsed by parseGlobalDeclarations()
+
+		case TypeDefinitionItem:
+			if newScope.scopeType != Global {
+				panic(fmt.Sprintf("Line %d: types can only be defined in the global scope", n+1))
+			}
+			// already parsed by parseGlobalDeclarations()
+
 		case Empty:
 
 		case ScopeClose:
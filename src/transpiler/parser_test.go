@@ -1,11 +1,41 @@
 package transpiler
 
 import (
-	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
+func resetTestState(t *testing.T) {
+	// package level state is cleared before the test, and again once it has finished
+	resetState()
+	t.Cleanup(resetState)
+}
+
+func newTestScope(t *testing.T) Scope {
+	resetTestState(t)
+	return Scope{
+		arrays:    make(map[string]Array),
+		vars:      make(map[string]Variable),
+		functions: make(map[string]Function),
+		tuples:    make(map[string]Tuple),
+	}
+}
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s test failed", name)
+		}
+	}()
+	f()
+}
+
 func TestParseExpression(t *testing.T) {
+	resetTestState(t)
 	// most major errors will get caught here
 	// also fairly easy to test
 
@@ -93,3 +123,791 @@ func TestParseExpression(t *testing.T) {
 		t.Error("failed tuple indexing test")
 	}
 }
+
+func TestRecords(t *testing.T) {
+	testScope := newTestScope(t)
+
+	_ = parseRecordDeclaration("type Point = { x: float, y: float, label: string }", 0)
+	_ = parseVariableDeclaration("let p: Point = Point { x: 1.0, y: 2.0, label: \"origin\" }", 0, &testScope)
+
+	expr1 := parseExpression("p.x + p.y", 0, &testScope)
+	if expr1.dataType != Float {
+		t.Error("record field access test failed")
+	}
+
+	expr2 := parseExpression("Point { label: \"moved\", ..p } == p", 0, &testScope)
+	if expr2.dataType != Bool {
+		t.Error("record update test failed")
+	}
+	if expr2.transpile() != "func(_r Point) Point { _r.label = \"moved\"; return _r }(p) == p" {
+		t.Errorf("record update transpiled to %s", expr2.transpile())
+	}
+
+	// tuple and array fields take literals
+	_ = parseRecordDeclaration("type Entry = { t: (int, string), xs: int[2] }", 0)
+	expr3 := parseExpression("Entry { t: (1, \"a\"), xs: [1, 2] }", 0, &testScope)
+	if expr3.transpile() != "Entry{t:  tuple2[int, string]{v0: 1, v1: \"a\"}, xs: [2]int{1, 2}}" {
+		t.Errorf("record literal with tuple and array fields transpiled to %s", expr3.transpile())
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testScope := newTestScope(t)
+
+	_ = parseEnumDeclaration("enum Light { Red, Amber, Green }", 0)
+	_ = parseVariableDeclaration("let l: Light = Light::Amber", 0, &testScope)
+
+	expr1 := parseExpression("match l { Light::Red => 0, Light::Amber => 1, Light::Green => 2 }", 0, &testScope)
+	if expr1.dataType != Int {
+		t.Error("match type test failed")
+	}
+
+	expr2 := parseExpression("match l { Light::Green => true, _ => false }", 0, &testScope)
+	if expr2.transpile() != "func(_m Light) bool {\nif _m == Light_Green {\nreturn true\n}\nreturn false\n}(l)" {
+		t.Errorf("match transpiled to %s", expr2.transpile())
+	}
+
+	// names bind whole tuples and arrays, which can then be indexed
+	pair := tupleType(TuplePattern{dataTypes: []primitiveType{Int, Int}})
+	testScope.tuples["q"] = Tuple{identifier: "q", pattern: TuplePattern{dataTypes: []primitiveType{pair, Int}}}
+	expr3 := parseExpression("match q { (p, 3) => p.0, _ => 0 }", 0, &testScope)
+	if expr3.dataType != Int {
+		t.Error("match binding a tuple test failed")
+	}
+	testScope.arrays["a"] = Array{identifier: "a", dataType: ArrayType{baseType: Int, dimensions: []int{2}}}
+	expr4 := parseExpression("match (a, 1) { (xs, 1) => xs[1], _ => 0 }", 0, &testScope)
+	if expr4.dataType != Int {
+		t.Error("match binding an array test failed")
+	}
+
+	// tuple patterns can contain enum variants
+	_ = parseTupleDeclaration("let m: (Light, int) = (Light::Red, 2)", 0, &testScope)
+	expr5 := parseExpression("match m { (Light::Red, n) => n, (Light::Amber, _) => 0, (Light::Green, _) => 1 }", 0, &testScope)
+	if expr5.dataType != Int {
+		t.Error("match tuple of enums test failed")
+	}
+
+	expectPanic(t, "non-exhaustive match", func() {
+		_ = parseExpression("match l { Light::Red => 0, Light::Green => 2 }", 0, &testScope)
+	})
+}
+
+func TestNestedTuples(t *testing.T) {
+	testScope := newTestScope(t)
+
+	T := readType("(string, float[3], (int, int))", 0)
+	if T.goType() != "tuple3[string, [3]float64, tuple2[int, int]]" {
+		t.Errorf("nested tuple type transpiled to %s", T.goType())
+	}
+
+	_ = parseTupleDeclaration("let body: (string, float[3], (int, int)) = (\"p\", [1.0, 2.0, 3.0], (1, 2))", 0, &testScope)
+	expr := parseExpression("body.2.0 * body.2.1", 0, &testScope)
+	if expr.dataType != Int {
+		t.Error("nested tuple indexing test failed")
+	}
+	if expr.transpile() != "body.v2.v0 * body.v2.v1" {
+		t.Errorf("nested tuple indexing transpiled to %s", expr.transpile())
+	}
+}
+
+func TestArraysOfTuples(t *testing.T) {
+	testScope := newTestScope(t)
+
+	declaration := parseArrayDeclaration("let mut points: (float, float)[2] = [(0.0, 1.0), (2.0, 3.0)]", 0, &testScope)
+	if declaration.arr.dataType.goType() != "[2]tuple2[float64, float64]" {
+		t.Errorf("array of tuples type transpiled to %s", declaration.arr.dataType.goType())
+	}
+
+	expr := parseExpression("points[1].0 + points[0].1", 0, &testScope)
+	if expr.dataType != Float {
+		t.Error("array of tuples indexing test failed")
+	}
+	if expr.transpile() != "points[1].v0 + points[0].v1" {
+		t.Errorf("array of tuples indexing transpiled to %s", expr.transpile())
+	}
+}
+
+func TestGenerics(t *testing.T) {
+	testScope := newTestScope(t)
+
+	lines := []string{"function largest<T: ordered>(a: T, b: T) -> T = {", "  a", "}"}
+	testScope.functions["largest"] = parseFunction(lines, 0, &testScope)
+	typeParameters = nil
+
+	expr := parseExpression("largest(1, 2) + 3", 0, &testScope)
+	if expr.dataType != Int {
+		t.Error("generic function return type test failed")
+	}
+
+	// the type argument of a tuple literal is inferred from its elements
+	lines = []string{"function first<T>(a: T, b: T) -> T = {", "  a", "}"}
+	testScope.functions["first"] = parseFunction(lines, 0, &testScope)
+	typeParameters = nil
+	tuple := parseTupleExpression("first((1, 2), (3, 4))", TuplePattern{dataTypes: []primitiveType{Int, Int}}, 0, &testScope)
+	if tuple.transpile() != "first( tuple2[int, int]{v0: 1, v1: 2},  tuple2[int, int]{v0: 3, v1: 4})" {
+		t.Errorf("generic function returning a tuple transpiled to %s", tuple.transpile())
+	}
+
+	expectPanic(t, "generic constraint", func() {
+		_ = parseExpression("largest(true, false)", 0, &testScope)
+	})
+}
+
+func TestOption(t *testing.T) {
+	testScope := newTestScope(t)
+
+	declaration := parseVariableDeclaration("let r: Result<float, string> = Err(\"did not converge\")", 0, &testScope)
+	if declaration.v.dataType.goType() != "Result[float64, string]" {
+		t.Errorf("result type transpiled to %s", declaration.v.dataType.goType())
+	}
+
+	expr := parseExpression("Some(2)", 0, &testScope)
+	if expr.transpile() != "Option[int]{tag: Option_Some, Some_0: 2}" {
+		t.Errorf("option transpiled to %s", expr.transpile())
+	}
+
+	declaration = parseVariableDeclaration("let p: Option<(int, int)> = Some((1, 2))", 0, &testScope)
+	if declaration.transpile() != "var p Option[tuple2[int, int]]  = Option[tuple2[int, int]]{tag: Option_Some, Some_0:  tuple2[int, int]{v0: 1, v1: 2}}" {
+		t.Errorf("option carrying a tuple transpiled to %s", declaration.transpile())
+	}
+
+	// ? is checked before the statement, unless the value isn't always evaluated
+	enclosingReturnType = optionType(Int)
+	propagated := Scope{items: []Transpileable{parseVariableDeclaration("let q: int = Some(2)? + 1", 0, &testScope)}}
+	if propagated.transpile() != "_p0 := Option[int]{tag: Option_Some, Some_0: 2}\nif _p0.tag == Option_None {\nreturn Option[int]{tag: Option_None}\n}\nvar q int  = _p0.Some_0 + 1\n" {
+		t.Errorf("propagation transpiled to %s", propagated.transpile())
+	}
+	arm := parseExpression("match q { 0 => Some(1)?, _ => 2 }", 0, &testScope)
+	if !recovers || !strings.Contains(arm.transpile(), "Option[int]{tag: Option_Some, Some_0: 1}.propagate()") {
+		t.Errorf("propagation in match arm transpiled to %s", arm.transpile())
+	}
+
+	expectPanic(t, "propagation outside of function returning Option", func() {
+		enclosingReturnType = Int
+		_ = parseExpression("Some(2)? + 1", 0, &testScope)
+	})
+}
+
+func TestLambdas(t *testing.T) {
+	testScope := newTestScope(t)
+
+	declaration := parseVariableDeclaration("let f: (float, float) -> float = |x: float, y: float| x * y", 0, &testScope)
+	if declaration.v.dataType.goType() != "func(float64, float64) float64" {
+		t.Errorf("function type transpiled to %s", declaration.v.dataType.goType())
+	}
+
+	expr := parseExpression("f(2.0, 3.0) + 1.0", 0, &testScope)
+	if expr.dataType != Float {
+		t.Error("call to function value type test failed")
+	}
+
+	_ = parseVariableDeclaration("let mut k: int = 2", 0, &testScope)
+	lambda := parseExpression("|n: int| n * k", 0, &testScope)
+	if lambda.transpile() != "func() func(int) int {\nk := k\nreturn func(n int) int {\nreturn n * k\n}\n}()" {
+		t.Errorf("lambda capturing a mutable variable transpiled to %s", lambda.transpile())
+	}
+
+	_ = parseVariableDeclaration("let dup: (int) -> (int, int) = |x: int| (x, x)", 0, &testScope)
+	tuple := parseTupleExpression("dup(2)", TuplePattern{dataTypes: []primitiveType{Int, Int}}, 0, &testScope)
+	if tuple.transpile() != "dup(2)" {
+		t.Errorf("call to function value returning a tuple transpiled to %s", tuple.transpile())
+	}
+
+	_ = parseTupleDeclaration("let pair: (int, (int) -> (int, int)) = (1, dup)", 0, &testScope)
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "contain a function") {
+			t.Errorf("comparison of tuples containing functions test failed with %v", r)
+		}
+	}()
+	_ = parseExpression("pair == pair", 0, &testScope)
+}
+
+func TestConstants(t *testing.T) {
+	testScope := newTestScope(t)
+	testScope.scopeType = Global
+
+	declaration := parseConstantDeclaration("const G: float = 6.674e-11", 0, &testScope)
+	if declaration.transpile() != "const G float64 = 6.674e-11" {
+		t.Errorf("constant transpiled to %s", declaration.transpile())
+	}
+
+	declaration = parseConstantDeclaration("const N: int = (3 + 4) * 2 - 10 / 3", 0, &testScope)
+	if declaration.value != "11" {
+		t.Errorf("constant expression evaluated to %s", declaration.value)
+	}
+
+	declaration = parseConstantDeclaration("const HALF_G: float = G / 2.0", 0, &testScope)
+	if declaration.value != "3.337e-11" {
+		t.Errorf("constant using another constant evaluated to %s", declaration.value)
+	}
+
+	imports = nil
+	declaration = parseConstantDeclaration("const P: float = 2.0 ** 3.0", 0, &testScope)
+	if declaration.transpile() != "const P float64 = 8.0" || len(imports) != 0 {
+		t.Errorf("constant power transpiled to %s with imports %v", declaration.transpile(), imports)
+	}
+
+	for _, overflow := range []string{"const A: int = 1 << 64", "const B: int = 9223372036854775807 + 1", "const C: float = 1e308 * 10.0"} {
+		expectPanic(t, "overflowing constant "+overflow, func() {
+			_ = parseConstantDeclaration(overflow, 0, &testScope)
+		})
+	}
+
+	expectPanic(t, "non-constant expression", func() {
+		testScope.functions["f"] = Function{identifier: "f", returnType: Int}
+		_ = parseConstantDeclaration("const A: int = f() + 1", 0, &testScope)
+	})
+
+	// constants are evaluated in order, so a constant declared later cannot be used
+	globalConstants = map[string]bool{"LATER": true, "SELF": true}
+	expectPanic(t, "constant declared later", func() {
+		_ = parseConstantDeclaration("const EARLIER: int = LATER + 1", 0, &testScope)
+	})
+	expectPanic(t, "constant used in its own value", func() {
+		_ = parseConstantDeclaration("const SELF: int = SELF + 1", 0, &testScope)
+	})
+	declaration = parseConstantDeclaration(`const LABEL: string = "LATER"`, 0, &testScope)
+	if declaration.value != `"LATER"` {
+		t.Errorf("constant with a string mentioning a later constant evaluated to %s", declaration.value)
+	}
+}
+
+func TestTypeDefinitions(t *testing.T) {
+	testScope := newTestScope(t)
+
+	alias := parseTypeDefinition("type Meters = float", 0)
+	if alias.transpile() != "type Meters = float64" {
+		t.Errorf("type alias transpiled to %s", alias.transpile())
+	}
+	if readType("Meters", 0) != Float {
+		t.Error("type alias is not the same type test failed")
+	}
+
+	newtype := parseTypeDefinition("newtype Kelvin = float", 0)
+	if newtype.transpile() != "type Kelvin float64" {
+		t.Errorf("newtype transpiled to %s", newtype.transpile())
+	}
+
+	_ = parseVariableDeclaration("let k: Kelvin = Kelvin(300.0)", 0, &testScope)
+	expr := parseExpression("float(k) - 273.15", 0, &testScope)
+	if expr.transpile() != "float64(k) - 273.15" {
+		t.Errorf("newtype conversion transpiled to %s", expr.transpile())
+	}
+
+	expectPanic(t, "mixing newtype with its underlying type", func() {
+		_ = parseExpression("k + 1.0", 0, &testScope)
+	})
+}
+
+func TestNumericTypes(t *testing.T) {
+	testScope := newTestScope(t)
+
+	declaration := parseVariableDeclaration("let x: i32 = 5i32 * 2i32", 0, &testScope)
+	if declaration.transpile() != "var x int32  = int32(5) * int32(2)" {
+		t.Errorf("sized integer transpiled to %s", declaration.transpile())
+	}
+
+	expr := parseExpression("f32(x) / 2.5f32", 0, &testScope)
+	if expr.dataType != F32 {
+		t.Error("numeric conversion type test failed")
+	}
+
+	expr = parseExpression("complex(1.0, 2.0) * 3.0i", 0, &testScope)
+	if expr.transpile() != "complex(1.0, 2.0) * complex128(3.0i)" {
+		t.Errorf("complex expression transpiled to %s", expr.transpile())
+	}
+
+	constant := parseConstantDeclaration("const CELL: u8 = 200u8 + u8(55)", 0, &testScope)
+	if constant.transpile() != "const CELL uint8 = 255" {
+		t.Errorf("sized constant transpiled to %s", constant.transpile())
+	}
+
+	// Go works these out while compiling, so they have to fit in their type
+	for _, overflow := range []string{"u8(300)", "u8(-1)", "i32(3000000000)", "255u8 + 1u8", "CELL + 1u8", "int(2.5)"} {
+		expectPanic(t, "overflowing sized constant "+overflow, func() {
+			_ = parseExpression(overflow, 0, &testScope)
+		})
+	}
+
+	expectPanic(t, "out of range literal", func() {
+		_ = parseExpression("256u8", 0, &testScope)
+	})
+}
+
+func TestDestructuring(t *testing.T) {
+	testScope := newTestScope(t)
+
+	declaration := parseDestructuringDeclaration("let (mut x, _, s): (int, float, string) = (1, 2.0, \"a\")", 0, &testScope)
+	if !testScope.vars["x"].mut || testScope.vars["s"].dataType != String {
+		t.Error("destructured names added to scope test failed")
+	}
+	if _, ok := testScope.vars["_"]; ok {
+		t.Error("ignored position added to scope")
+	}
+	if !strings.Contains(declaration.transpile(), "var s string = "+declaration.tuple+".v2") {
+		t.Errorf("destructuring transpiled to %s", declaration.transpile())
+	}
+
+	_, _, tuples, _ := parseParameters("(a, b): (float, float), k: int", 0)
+	if len(tuples) != 1 || tuples[0].destructuring == nil || tuples[0].destructuring.names[1] != "b" {
+		t.Error("destructured parameter test failed")
+	}
+
+	expectPanic(t, "destructuring with wrong number of names", func() {
+		_ = parseDestructuringDeclaration("let (p, q): (int, int, int) = (1, 2, 3)", 0, &testScope)
+	})
+}
+
+func TestArrayCopy(t *testing.T) {
+	testScope := newTestScope(t)
+
+	_ = parseArrayDeclaration("let grid: int[2][3] = [[1, 2, 3], [4, 5, 6]]", 0, &testScope)
+	row := parseArrayDeclaration("let row: int[3] = grid[1]", 0, &testScope)
+	if row.expr.transpile() != "grid[1]" {
+		t.Errorf("row of array transpiled to %s", row.expr.transpile())
+	}
+
+	slice := parseArrayDeclaration("let middle: int[2] = row[1..3]", 0, &testScope)
+	if slice.expr.transpile() != "[2]int(row[1:3])" {
+		t.Errorf("slice of array transpiled to %s", slice.expr.transpile())
+	}
+
+	equal := parseExpression("row == grid[0]", 0, &testScope)
+	if equal.dataType != Bool {
+		t.Error("array comparison test failed")
+	}
+
+	// literals and slices are parsed using the type of the other side
+	literal := parseExpression("row == [4, 5, 6]", 0, &testScope)
+	if literal.transpile() != "row == ([3]int{4, 5, 6})" {
+		t.Errorf("comparison with array literal transpiled to %s", literal.transpile())
+	}
+	slices := parseExpression("row[0..2] == row[1..3]", 0, &testScope)
+	if slices.transpile() != "([2]int(row[0:2])) == ([2]int(row[1:3]))" {
+		t.Errorf("comparison of slices transpiled to %s", slices.transpile())
+	}
+	_ = parseTupleDeclaration("let pair: (int, int) = (1, 2)", 0, &testScope)
+	tuple := parseExpression("pair == (1, 2) && (2, 1) != pair", 0, &testScope)
+	if tuple.transpile() != "pair == ( tuple2[int, int]{v0: 1, v1: 2}) && ( tuple2[int, int]{v0: 2, v1: 1}) != pair" {
+		t.Errorf("comparison with tuple literal transpiled to %s", tuple.transpile())
+	}
+
+	expectPanic(t, "tuple literal which isn't compared", func() {
+		_ = parseExpression("(1, 2) && true", 0, &testScope)
+	})
+	expectPanic(t, "slice past end of array", func() {
+		_ = parseArrayDeclaration("let end: int[2] = row[2..4]", 0, &testScope)
+	})
+}
+
+func TestOperators(t *testing.T) {
+	testScope := newTestScope(t)
+	testScope.vars["n"] = Variable{identifier: "n", dataType: Int}
+
+	mixed := parseExpression("n % 3 == 1 && n << 2 > 8", 0, &testScope)
+	if mixed.dataType != Bool {
+		t.Error("precedence of operators test failed")
+	}
+
+	power := parseExpression("1 + 2 ** n * 3", 0, &testScope)
+	if power.transpile() != "1 + _pow(2, n) * 3" {
+		t.Errorf("integer power transpiled to %s", power.transpile())
+	}
+
+	division := parseExpression("7.5 div 2.0", 0, &testScope)
+	if division.dataType != Float || division.transpile() != "math.Trunc(7.5 / 2.0)" {
+		t.Errorf("float division transpiled to %s", division.transpile())
+	}
+
+	expectPanic(t, "remainder of floats", func() {
+		_ = parseExpression("7.5 % 2.0", 0, &testScope)
+	})
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	testScope := newTestScope(t)
+	testScope.vars["n"] = Variable{identifier: "n", dataType: Int, mut: true}
+	testScope.vars["x"] = Variable{identifier: "x", dataType: Float, mut: true}
+	testScope.tuples["pair"] = Tuple{identifier: "pair", pattern: TuplePattern{dataTypes: []primitiveType{Int, String}}, mut: true}
+
+	add := parseAssignment([]string{"n += 2 * n"}, 0, &testScope)
+	if add.transpile() != "n += 2 * n" {
+		t.Errorf("compound assignment transpiled to %s", add.transpile())
+	}
+
+	increment := parseAssignment([]string{"n++"}, 0, &testScope)
+	if increment.transpile() != "n++" {
+		t.Errorf("increment transpiled to %s", increment.transpile())
+	}
+
+	element := parseMemberAssignment("pair.1 += \"b\"", 0, &testScope)
+	if element.transpile() != "pair.v1 += \"b\"" {
+		t.Errorf("assignment to tuple element transpiled to %s", element.transpile())
+	}
+
+	expectPanic(t, "remainder assignment to float", func() {
+		_ = parseAssignment([]string{"x %= 2.0"}, 0, &testScope)
+	})
+}
+
+func TestRangeLoop(t *testing.T) {
+	testScope := newTestScope(t)
+	testScope.vars["n"] = Variable{identifier: "n", dataType: Int}
+	testScope.arrays["xs"] = Array{identifier: "xs", dataType: ArrayType{baseType: Float, dimensions: []int{3}}}
+
+	counter := parseLoop("loop i in 0..n step 2 {", 0, &testScope)
+	if counter.transpile() != "for i, _end := 0, n; i < _end; i += 2 {" {
+		t.Errorf("range loop transpiled to %s", counter.transpile())
+	}
+	if i, ok := testScope.vars["i"]; !ok || i.mut {
+		t.Error("loop variable of range loop test failed")
+	}
+
+	testScope.vars["s"] = Variable{identifier: "s", dataType: Int}
+	variableStep := parseLoop("loop j in 10..0 step s {", 0, &testScope)
+	if variableStep.transpile() != "for j, _step := 10, _checkStep(s); _step > 0 && j < 0 || _step < 0 && j > 0; j += _step {" {
+		t.Errorf("range loop with a variable step transpiled to %s", variableStep.transpile())
+	}
+
+	elements := parseLoop("loop x in xs {", 0, &testScope)
+	if elements.transpile() != "for _, x := range xs {\n_ = x" {
+		t.Errorf("loop over array transpiled to %s", elements.transpile())
+	}
+	if testScope.vars["x"].dataType != Float {
+		t.Error("type of loop variable test failed")
+	}
+
+	expectPanic(t, "range of different types", func() {
+		_ = parseLoop("loop j in 0..2.5 {", 0, &testScope)
+	})
+}
+
+func TestLabeledLoop(t *testing.T) {
+	resetTestState(t)
+	testScope := Scope{
+		arrays:    make(map[string]Array),
+		vars:      make(map[string]Variable),
+		functions: make(map[string]Function),
+		tuples:    make(map[string]Tuple),
+		scopeType: FunctionScope,
+	}
+
+	loop := parseLoop("'outer: loop true {", 0, &testScope)
+	loopScope := Scope{scopeType: LoopScope, parent: &testScope, label: loop.label}
+	innerScope := Scope{scopeType: SelectionScope, parent: &loopScope}
+
+	b := parseBreak("break 'outer", 0, &innerScope)
+	if b.transpile() != "break outer" {
+		t.Errorf("labeled break transpiled to %s", b.transpile())
+	}
+	if loop.transpile() != "outer:\nfor {" {
+		t.Errorf("labeled loop transpiled to %s", loop.transpile())
+	}
+
+	expectPanic(t, "break with label of no enclosing loop", func() {
+		_ = parseBreak("continue 'inner", 0, &innerScope)
+	})
+}
+
+func TestEarlyReturn(t *testing.T) {
+	testScope := newTestScope(t)
+	testScope.vars["x"] = Variable{identifier: "x", dataType: Int}
+
+	lines := []string{
+		"function sign(x: int) -> int = {",
+		"    if x < 0 {",
+		"        return -1",
+		"    } else {",
+		"        return 1",
+		"    }",
+		"}",
+	}
+	if buildControlFlowGraph(lines, 0).reachable()[flowExit] {
+		t.Error("return in every branch test failed")
+	}
+	withoutElse := []string{lines[0], lines[1], lines[2], "    }", "    println!(x)", "}"}
+	if !buildControlFlowGraph(withoutElse, 0).reachable()[flowExit] {
+		t.Error("if without else test failed")
+	}
+
+	returnedType = Int
+	ret := parseEarlyReturn("return x * 2", 0, &testScope)
+	if ret.transpile() != "return x * 2" {
+		t.Errorf("return statement transpiled to %s", ret.transpile())
+	}
+
+	expectPanic(t, "return of wrong type", func() {
+		_ = parseEarlyReturn("return true", 0, &testScope)
+	})
+}
+
+func TestIfExpression(t *testing.T) {
+	testScope := newTestScope(t)
+	testScope.vars["x"] = Variable{identifier: "x", dataType: Int}
+
+	lines := []string{"let sign: int = if x > 0 { 1 } else { -1 }"}
+	v, last := parseValueBlockItem(lines, 0, 1, &testScope)
+	expected := "var sign int\n_ = sign\nif x > 0 {\nsign = 1\n} else {\nsign = - 1\n}"
+	if last != 0 || v.transpile() != expected {
+		t.Errorf("if expression transpiled to %s", v.transpile())
+	}
+	if _, ok := testScope.vars["sign"]; !ok {
+		t.Error("variable declared by if expression not in scope")
+	}
+
+	statement := []string{
+		"if x > 0 {",
+		"    x = 1",
+		"} else {",
+		"    x",
+		"}",
+	}
+	if !isValueIf(statement, 0) {
+		t.Error("branch ending with value test failed")
+	}
+	if isValueIf([]string{statement[0], statement[1], "}"}, 0) {
+		t.Error("if statement test failed")
+	}
+
+	// inside another expression the branches return from a function literal
+	expr := parseExpression("2 * if x > 0 { x } else { 0 } + 1", 0, &testScope)
+	if expr.dataType != Int || expr.transpile() != "2 * func() int {\nif x > 0 {\nreturn x\n} else {\nreturn 0\n}\n}() + 1" {
+		t.Errorf("if expression inside another expression transpiled to %s", expr.transpile())
+	}
+	expectPanic(t, "return from if expression inside another expression", func() {
+		_ = parseExpression("1 + if x > 0 { return 1 } else { 2 }", 0, &testScope)
+	})
+
+	expectPanic(t, "if expression without else", func() {
+		_, _ = parseValueBlockItem([]string{"let y: int = if x > 0 { 1 }"}, 0, 1, &testScope)
+	})
+}
+
+func TestControlFlow(t *testing.T) {
+	resetTestState(t)
+	returnedType = Int
+	lines := []string{
+		"function find(x: int) -> int = {",
+		"    loop true {",
+		"        if x > 0 {",
+		"            return x",
+		"        }",
+		"    }",
+		"}",
+	}
+	checkControlFlow(lines, 0, "find")
+
+	conditional := slices.Clone(lines)
+	conditional[1] = "    loop x < 10 {"
+	if !buildControlFlowGraph(conditional, 0).reachable()[flowExit] {
+		t.Error("loop which might not run test failed")
+	}
+
+	withBreak := slices.Clone(lines)
+	withBreak[3] = "            break"
+	if !buildControlFlowGraph(withBreak, 0).reachable()[flowExit] {
+		t.Error("break out of infinite loop test failed")
+	}
+
+	// panic!() ends the function without needing a value
+	withPanic := []string{
+		"function sign(x: int) -> int = {",
+		"    if x > 0 {",
+		"        return 1",
+		"    } else {",
+		"        panic!(\"bad\")",
+		"    }",
+		"}",
+	}
+	checkControlFlow(withPanic, 0, "sign")
+
+	expectPanic(t, "dead code after return", func() {
+		deadCode := slices.Insert(slices.Clone(lines), 4, "            x")
+		checkControlFlow(deadCode, 0, "find")
+	})
+}
+
+func TestForwardReference(t *testing.T) {
+	resetTestState(t)
+	lines := []string{
+		"function is_even(n: int) -> bool = {",
+		"    if n == 0 {",
+		"        return true",
+		"    }",
+		"    is_odd(n - 1)",
+		"}",
+		"function is_odd(n: int) -> bool = {",
+		"    if n == 0 {",
+		"        return false",
+		"    }",
+		"    is_even(n - 1)",
+		"}",
+	}
+	globalScope := childScope(&Scope{}, Global)
+	_ = parseGlobalDeclarations(lines, &globalScope)
+	if f, ok := globalScope.functions["is_odd"]; !ok || f.returnType != Bool || f.lineNum != 6 {
+		t.Error("signature of function declared later not collected")
+	}
+	_ = parseScope(lines, 0, Global, nil)
+
+	expectPanic(t, "function declared twice", func() {
+		_ = parseGlobalDeclarations(append(lines, lines[:6]...), &Scope{functions: make(map[string]Function)})
+	})
+}
+
+func TestFunctionArguments(t *testing.T) {
+	resetTestState(t)
+	lines := []string{
+		"function scale(xs: float[n], (a, b): (float, float)) -> float = {",
+		"    xs[0] * a + b",
+		"}",
+		"function pair() -> (float, float) = {",
+		"    (2.0, 1.0)",
+		"}",
+	}
+	globalScope := childScope(&Scope{}, Global)
+	_ = parseGlobalDeclarations(lines, &globalScope)
+
+	call := parseFunctionCall("scale([1.5, 2.5], pair())", 0, &globalScope)
+	if call.transpile() != "scale([]float64{1.5, 2.5}, pair())" {
+		t.Error("literal and call arguments test failed")
+	}
+	call = parseFunctionCall("scale([scale([1.0], (1.0, 0.0))], (1.0, 2.0))", 0, &globalScope)
+	if call.dataType != Float || len(call.arguments) != 2 {
+		t.Error("nested call argument test failed")
+	}
+
+	expectPanic(t, "tuple argument of wrong type", func() {
+		_ = parseFunctionCall("scale([1.0], (1, 2))", 0, &globalScope)
+	})
+}
+
+func TestLocalFunction(t *testing.T) {
+	resetTestState(t)
+	lines := []string{
+		"function main() -> IO = {",
+		"    let mut scale: int = 2",
+		"    function scaled(x: int) -> int = {",
+		"        scale * x",
+		"    }",
+		"    println!(scaled(3))",
+		"}",
+	}
+	transpiled := parseScope(lines, 0, Global, nil).transpile()
+	if !strings.Contains(transpiled, "var scaled func(int) int") || !strings.Contains(transpiled, "scale := scale") {
+		t.Errorf("local function transpiled to %s", transpiled)
+	}
+
+	variadic := []string{
+		"function main() -> IO = {",
+		"    function total(rest: ...int) -> int = {",
+		"        rest[0]",
+		"    }",
+		"    println!(total(1, 2))",
+		"}",
+	}
+	transpiled = parseScope(variadic, 0, Global, nil).transpile()
+	if !strings.Contains(transpiled, "var total func(...int) int") || !strings.Contains(transpiled, "total(1, 2)") {
+		t.Errorf("variadic local function transpiled to %s", transpiled)
+	}
+
+	expectPanic(t, "local function changing enclosing variable", func() {
+		lines[3] = "        scale = x"
+		lines = slices.Insert(lines, 4, "        x")
+		_ = parseScope(lines, 0, Global, nil)
+	})
+}
+
+func TestDefaultArguments(t *testing.T) {
+	resetTestState(t)
+	lines := []string{
+		"function solve(a: float, b: float, tol: float = 0.001, max_iter: int = 10 * 10) -> float = {",
+		"    a + b",
+		"}",
+	}
+	globalScope := childScope(&Scope{}, Global)
+	_ = parseGlobalDeclarations(lines, &globalScope)
+
+	call := parseFunctionCall("solve(1.0, 2.0, max_iter: 5)", 0, &globalScope)
+	if call.transpile() != "solve(1.0, 2.0, 0.001, 5)" {
+		t.Errorf("call with default and named arguments transpiled to %s", call.transpile())
+	}
+	call = parseFunctionCall("solve(b: 2.0, a: 1.0)", 0, &globalScope)
+	if call.transpile() != "solve(1.0, 2.0, 0.001, 100)" {
+		t.Errorf("call with named arguments transpiled to %s", call.transpile())
+	}
+
+	for _, invalid := range []string{"solve(1.0)", "solve(1.0, 2.0, a: 3.0)", "solve(1.0, 2.0, step: 1.0)", "solve(b: 2.0, 1.0)"} {
+		expectPanic(t, "invalid call "+invalid, func() {
+			_ = parseFunctionCall(invalid, 0, &globalScope)
+		})
+	}
+}
+
+func TestVariadic(t *testing.T) {
+	resetTestState(t)
+	lines := []string{
+		"function largest(first: float, rest: ...float) -> float = {",
+		"    first",
+		"}",
+	}
+	globalScope := childScope(&Scope{}, Global)
+	globalScope.arrays["xs"] = Array{identifier: "xs", dataType: ArrayType{baseType: Float, dimensions: []int{3}}}
+	_ = parseGlobalDeclarations(lines, &globalScope)
+
+	call := parseFunctionCall("largest(1.0, 4.0, 2.0)", 0, &globalScope)
+	if call.transpile() != "largest(1.0, 4.0, 2.0)" {
+		t.Errorf("variadic call transpiled to %s", call.transpile())
+	}
+	call = parseFunctionCall("largest(1.0)", 0, &globalScope)
+	if call.transpile() != "largest(1.0)" {
+		t.Errorf("variadic call with no variadic arguments transpiled to %s", call.transpile())
+	}
+	call = parseFunctionCall("largest(1.0, xs...)", 0, &globalScope)
+	if call.transpile() != "largest(1.0, xs[:]...)" {
+		t.Errorf("variadic call with spread array transpiled to %s", call.transpile())
+	}
+
+	for _, invalid := range []string{"largest(1.0, 2)", "largest(1.0, 2.0, xs...)", "largest(1.0, rest: 2.0)", "largest()"} {
+		expectPanic(t, "invalid call "+invalid, func() {
+			_ = parseFunctionCall(invalid, 0, &globalScope)
+		})
+	}
+
+	// the number of arguments can be named in the same way as the length of an array parameter
+	_, arrays, _, _ := parseParameters("first: float, rest: ...float[n]", 0)
+	if len(arrays) != 1 || arrays[0].lengthParam != "n" || arrays[0].dataType.goType() != "[]float64" {
+		t.Error("variadic parameter with a length test failed")
+	}
+	expectPanic(t, "variadic parameter taking arrays", func() {
+		_, _, _, _ = parseParameters("rest: ...float[3]", 0)
+	})
+}
+
+func TestTranspileTarget(t *testing.T) {
+	// state left over from the first file mustn't change the second
+	resetTestState(t)
+	source := strings.Join([]string{
+		"type Particle = { x: float, mass: float }",
+		"const N: int = 3",
+		"",
+		"function main() -> IO = {",
+		"    let p: Particle = Particle { x: 1.0, mass: 2.0 }",
+		"    let (a, b): (int, float) = (N, p.mass ** 2.0)",
+		"    'outer: loop i in 0..N {",
+		"        if i == a {",
+		"            break 'outer",
+		"        }",
+		"    }",
+		"    println!(a, b)",
+		"}",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "main.ste")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	first := TranspileTarget(path)
+	if second := TranspileTarget(path); second != first {
+		t.Errorf("transpiling the same file twice gave different output:\n%s\n%s", first, second)
+	}
+}
@@ -0,0 +1,335 @@
+package transpiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// record implementation in Go
+/**
+type Particle struct {
+	x float64
+	v float64
+	mass float64
+}
+
+// records are registered as defined types, so a record value is just a
+// Variable/Expression whose data type is the id of the record
+*/
+
+// type Particle = { x: float, v: float, mass: float }
+type RecordDeclaration struct {
+	dataType primitiveType
+}
+
+// Particle { x: 0.0, v: 1.5, mass: 2.0 }
+// Particle { mass: 4.0, ..p } (functional update)
+type RecordLiteral struct {
+	dataType primitiveType
+	fields   []string
+	values   []Transpileable
+	base     Expression // optional - record being updated
+	update   bool
+}
+
+// p.mass, t.0, points[i].0 etc.
+type MemberAccess struct {
+	root      string
+	selectors []string // already in Go syntax e.g. ".mass", ".v0", "[i]"
+	dataType  primitiveType
+}
+
+// p.mass = 2.0, t.0 += 1, grid[i][j] = 0 etc.
+type MemberAssignment struct {
+	member   MemberAccess
+	value    Transpileable // nil for ++ and --
+	operator string
+}
+
+func parseRecordDeclaration(line string, lineNum int) RecordDeclaration {
+	words := strings.Fields(line)
+	if words[0] != "type" {
+		panic("parseRecordDeclaration() called without type keyword")
+	}
+	if len(words) < 4 || words[2] != "=" {
+		panic(fmt.Sprintf("Line %d: expected record declaration of the form type Name = { field: type, ... }", lineNum+1))
+	}
+
+	identifier := parseIdentifier(words[1]+":", lineNum)
+	// colon added so it doesn't throw an expected type annotation error
+
+	trimmed := strings.Trim(line, " ")
+	open := strings.Index(trimmed, "{")
+	if trimmed[len(trimmed)-1] != '}' {
+		panic(fmt.Sprintf("Line %d: record declaration must be closed on the same line with '}'", lineNum+1))
+	}
+
+	var fields []Variable
+	seen := make(map[string]struct{})
+	for _, field := range splitTopLevel(trimmed[open+1:len(trimmed)-1], ',') {
+		colon := strings.Index(field, ":")
+		if colon == -1 {
+			panic(fmt.Sprintf("Line %d: record field %s has no type annotation", lineNum+1, field))
+		}
+		name := parseIdentifier(strings.Trim(field[:colon+1], " "), lineNum)
+		if _, ok := seen[name]; ok {
+			panic(fmt.Sprintf("Line %d: field %s declared more than once in record %s", lineNum+1, name, identifier))
+		}
+		seen[name] = struct{}{}
+
+		T := readType(strings.Trim(field[colon+1:], " "), lineNum)
+		if T == IO {
+			panic(fmt.Sprintf("Line %d: record fields cannot have type IO", lineNum+1))
+		}
+		fields = append(fields, Variable{
+			identifier: name,
+			dataType:   T,
+		})
+	}
+
+	if len(fields) == 0 {
+		panic(fmt.Sprintf("Line %d: record %s has no fields", lineNum+1, identifier))
+	}
+
+	T := defineType(definedType{
+		identifier: identifier,
+		kind:       RecordKind,
+		fields:     fields,
+	}, lineNum)
+
+	return RecordDeclaration{
+		dataType: T,
+	}
+}
+
+func isRecordLiteral(token string) bool {
+	open := strings.Index(token, "{")
+	if open <= 0 || token[len(token)-1] != '}' {
+		return false
+	}
+	T, ok := lookupType(strings.Trim(token[:open], " "))
+	return ok && isRecord(T)
+}
+
+func recordField(T primitiveType, field string) (Variable, bool) {
+	record, _ := T.defined()
+	for _, f := range record.fields {
+		if f.identifier == field {
+			return f, true
+		}
+	}
+	return Variable{}, false
+}
+
+func parseRecordLiteral(literal string, lineNum int, currentScope *Scope) RecordLiteral {
+	open := strings.Index(literal, "{")
+	name := strings.Trim(literal[:open], " ")
+	T, ok := lookupType(name)
+	if !ok || !isRecord(T) {
+		panic(fmt.Sprintf("Line %d: %s is not a record type", lineNum+1, name))
+	}
+	record, _ := T.defined()
+
+	entries := splitTopLevel(literal[open+1:len(literal)-1], ',')
+
+	var fields []string
+	var values []Transpileable
+	var base Expression
+	var update bool
+
+	given := make(map[string]struct{})
+	for i, entry := range entries {
+		if strings.HasPrefix(entry, "..") {
+			if i != len(entries)-1 {
+				panic(fmt.Sprintf("Line %d: record being updated with .. must come after all of the fields", lineNum+1))
+			}
+			base = parseExpression(entry[2:], lineNum, currentScope)
+			if base.dataType != T {
+				panic(fmt.Sprintf("Line %d: cannot update record of type %v using value of type %v", lineNum+1, T, base.dataType))
+			}
+			update = true
+			continue
+		}
+
+		colon := strings.Index(entry, ":")
+		if colon == -1 {
+			panic(fmt.Sprintf("Line %d: expected field: value in record literal but found %s", lineNum+1, entry))
+		}
+		field := strings.Trim(entry[:colon], " ")
+		f, ok := recordField(T, field)
+		if !ok {
+			panic(fmt.Sprintf("Line %d: record %s has no field %s", lineNum+1, name, field))
+		}
+		if _, ok := given[field]; ok {
+			panic(fmt.Sprintf("Line %d: field %s given more than once in record literal", lineNum+1, field))
+		}
+		given[field] = struct{}{}
+
+		// parsed with the field's type so that tuple and array literals can be given
+		fields = append(fields, field)
+		values = append(values, parseTypedValue(entry[colon+1:], f.dataType, lineNum, currentScope))
+	}
+
+	if !update {
+		for _, f := range record.fields {
+			if _, ok := given[f.identifier]; !ok {
+				panic(fmt.Sprintf("Line %d: missing field %s in literal of record %s", lineNum+1, f.identifier, name))
+			}
+		}
+	}
+
+	return RecordLiteral{
+		dataType: T,
+		fields:   fields,
+		values:   values,
+		base:     base,
+		update:   update,
+	}
+}
+
+func parseMemberAccess(access string, lineNum int, currentScope *Scope) MemberAccess {
+	// parses chains of record field accesses, tuple indexes and array indexes
+	trimmed := strings.Trim(access, " ")
+	rootEnd := strings.IndexAny(trimmed, ".[")
+	if rootEnd == -1 {
+		panic(fmt.Sprintf("Line %d: no index operator '.' found in tuple indexing", lineNum+1))
+	}
+	root := trimmed[:rootEnd]
+
+	var selectors []string
+	var T primitiveType
+
+	if t, ok := (*currentScope).tuples[root]; ok {
+		T = tupleType(t.pattern)
+	} else if v, ok := (*currentScope).vars[root]; ok {
+		T = v.dataType
+	} else if arr, ok := (*currentScope).arrays[root]; ok {
+		T = arrayTypeID(arr.dataType)
+	} else {
+		panic(fmt.Sprintf("Line %d: %s is not a record or tuple in scope", lineNum+1, root))
+	}
+
+	rest := trimmed[rootEnd:]
+	for len(rest) != 0 {
+		if rest[0] == '[' {
+			// element of array e.g. points[i].0
+			end := squareBracketEnd(rest, 0, lineNum)
+			if !isArray(T) {
+				panic(fmt.Sprintf("Line %d: cannot index value of type %v because it is not an array", lineNum+1, T))
+			}
+			index := parseExpression(rest[1:end], lineNum, currentScope)
+			if index.dataType != Int {
+				panic(fmt.Sprintf("Line %d: attempt to index arrays with expression evaluating to non-integer type %v", lineNum+1, index.dataType))
+			}
+			arr, _ := T.defined()
+			selectors = append(selectors, "["+index.transpile()+"]")
+			T = elementType(arr.array)
+			rest = rest[end+1:]
+			continue
+		}
+		if rest[0] != '.' {
+			panic(fmt.Sprintf("Line %d: unexpected token %s in %s", lineNum+1, string(rest[0]), trimmed))
+		}
+		rest = rest[1:]
+		partEnd := strings.IndexAny(rest, ".[")
+		if partEnd == -1 {
+			partEnd = len(rest)
+		}
+		part := rest[:partEnd]
+		rest = rest[partEnd:]
+
+		if isTuple(T) {
+			// nested tuples e.g. t.2.0
+			tup, _ := T.defined()
+			i, err := strconv.Atoi(part)
+			if err != nil {
+				panic(fmt.Sprintf("Line %d: tuple index is invalid because it is not an integer literal", lineNum+1))
+			}
+			if i < 0 || i >= len(tup.elements) {
+				panic(fmt.Sprintf("Line %d: attempt to index element %d of tuple %v with %d elements", lineNum+1, i, T, len(tup.elements)))
+			}
+			selectors = append(selectors, ".v"+part)
+			T = tup.elements[i]
+			continue
+		}
+		if !isRecord(T) {
+			panic(fmt.Sprintf("Line %d: cannot access field %s of value with type %v", lineNum+1, part, T))
+		}
+		f, ok := recordField(T, part)
+		if !ok {
+			panic(fmt.Sprintf("Line %d: record %v has no field %s", lineNum+1, T, part))
+		}
+		selectors = append(selectors, "."+part)
+		T = f.dataType
+	}
+
+	return MemberAccess{
+		root:      root,
+		selectors: selectors,
+		dataType:  T,
+	}
+}
+
+func isMemberAccess(token string, currentScope *Scope) bool {
+	rootEnd := strings.IndexAny(token, ".[")
+	if rootEnd <= 0 {
+		return false
+	}
+	root := token[:rootEnd]
+	_, isTuple := (*currentScope).tuples[root]
+	_, isVar := (*currentScope).vars[root]
+	if isTuple || isVar {
+		return token[rootEnd] == '.'
+	}
+	if _, isArray := (*currentScope).arrays[root]; isArray && token[rootEnd] == '[' {
+		// arr[i] on its own is just array indexing
+		var bracketCount int
+		for i := rootEnd; i < len(token); i++ {
+			switch token[i] {
+			case '[':
+				bracketCount++
+			case ']':
+				bracketCount--
+			}
+			if bracketCount == 0 {
+				return i != len(token)-1
+			}
+		}
+	}
+	return false
+}
+
+func parseMemberAssignment(line string, lineNum int, currentScope *Scope) MemberAssignment {
+	left, operator, expr := splitAssignment(line, lineNum)
+	member := parseMemberAccess(left, lineNum, currentScope)
+
+	// the value the member belongs to has to be mutable
+	var mut bool
+	if t, ok := (*currentScope).tuples[member.root]; ok {
+		mut = t.mut
+	} else if v, ok := (*currentScope).vars[member.root]; ok {
+		mut = v.mut
+	} else if arr, ok := (*currentScope).arrays[member.root]; ok {
+		mut = arr.mut
+	}
+	if !mut {
+		panic(fmt.Sprintf("Line %d: attempt to assign new value to %s but %s is immutable", lineNum+1, left, member.root))
+	}
+
+	if isIncrement(line) {
+		checkAssignmentOperator(operator, member.dataType, member.dataType, lineNum)
+		return MemberAssignment{
+			member:   member,
+			operator: operator,
+		}
+	}
+
+	value := parseTypedValue(expr, member.dataType, lineNum, currentScope)
+	checkAssignmentOperator(operator, member.dataType, member.dataType, lineNum)
+	return MemberAssignment{
+		member:   member,
+		value:    value,
+		operator: operator,
+	}
+}
@@ -0,0 +1,65 @@
+package transpiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// early return implementation in Go
+/**
+function safe_divide(a: float, b: float) -> float = {
+	if b == 0.0 {
+		return 0.0
+	}
+	a / b
+}
+
+func safe_divide(a float64, b float64) float64 {
+	if b == 0.0 {
+		return 0.0
+	}
+	return a / b
+}
+
+// a function doesn't need a final expression if every path through it
+// ends in a return statement
+*/
+
+// return expr, or just return in functions with return type IO
+type EarlyReturn struct {
+	value Transpileable // nil in functions with return type IO
+}
+
+var returnedType primitiveType // type which return statements in the function currently being parsed must have
+
+func parseEarlyReturn(line string, lineNum int, currentScope *Scope) EarlyReturn {
+	trimmed := strings.Trim(line, " ")
+	if !strings.HasPrefix(trimmed, "return") {
+		panic("parseEarlyReturn() called without return keyword")
+	}
+	value := strings.Trim(trimmed[len("return"):], " ")
+
+	if returnedType == IO {
+		if len(value) != 0 {
+			panic(fmt.Sprintf("Line %d: cannot return a value from a function with return type IO", lineNum+1))
+		}
+		return EarlyReturn{}
+	}
+	if len(value) == 0 {
+		panic(fmt.Sprintf("Line %d: expected value of type %v after return", lineNum+1, returnedType))
+	}
+
+	return EarlyReturn{
+		value: parseTypedValue(value, returnedType, lineNum, currentScope),
+	}
+}
+
+func functionReturnType(returnDomain returnDomain, returnType primitiveType, tuplePattern TuplePattern, derivedReturnType ArrayType) primitiveType {
+	switch returnDomain {
+	case tuple:
+		return tupleType(tuplePattern)
+	case derived:
+		return arrayTypeID(derivedReturnType)
+	}
+	return returnType
+}
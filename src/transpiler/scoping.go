@@ -2,8 +2,11 @@ package transpiler
 
 import (
 	"bufio"
+	"cmp"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 )
 
 type ScopeType int
@@ -13,6 +16,7 @@ const (
 	SelectionScope
 	LoopScope
 	Global
+	BlockScope // branch of an if expression or block
 )
 
 type Scope struct {
@@ -23,6 +27,8 @@ type Scope struct {
 	parent    *Scope
 	items     []Transpileable
 	scopeType ScopeType
+	label     *LoopLabel   // only for loops
+	target    *blockTarget // only for branches of if expressions and blocks
 }
 
 type Location struct {
@@ -32,9 +38,11 @@ type Location struct {
 }
 
 var (
-	tupleImports []int
-	packageName  string
-	imports      []string
+	tupleImports      []int
+	constraintImports []string
+	builtinImports    []string
+	packageName       string
+	imports           []string
 )
 
 func check(err error) {
@@ -43,6 +51,31 @@ func check(err error) {
 	}
 }
 
+func childScope(parent *Scope, scopeType ScopeType) Scope {
+	// manually copy as maps are reference types
+	child := Scope{
+		vars:      make(map[string]Variable),
+		functions: make(map[string]Function),
+		arrays:    make(map[string]Array),
+		tuples:    make(map[string]Tuple),
+		parent:    parent,
+		scopeType: scopeType,
+	}
+	for k, v := range parent.vars {
+		child.vars[k] = v
+	}
+	for k, v := range parent.functions {
+		child.functions[k] = v
+	}
+	for k, v := range parent.arrays {
+		child.arrays[k] = v
+	}
+	for k, v := range parent.tuples {
+		child.tuples[k] = v
+	}
+	return child
+}
+
 func findScopeEnd(lines []string, begin int) int {
 	scopeCount := 0 // keeps track of scopes opened/scopes closed
 	opened := false // keeps track of if scope has been opened yet. important for lines where a scope if opened on the same line where another is closed
@@ -101,8 +134,75 @@ func findBracketEnd(bracketType byte, lines []string, lineNum int, charIndex int
 	panic(fmt.Sprintf("Line %d: bracket %s opened but never closed", lineNum+1, string(bracketType)))
 }
 
+func splitTopLevel(s string, separator byte) []string {
+	// splits s on separator, ignoring separators inside brackets or literals
+	var parts []string
+	var current string
+	var bracketCount, angleCount int
+	var stringLiteral, byteLiteral, lambdaParams bool
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case stringLiteral:
+			if s[i] == '"' {
+				stringLiteral = false
+			}
+		case byteLiteral:
+			if s[i] == 39 {
+				byteLiteral = false
+			}
+		case s[i] == '"':
+			stringLiteral = true
+		case s[i] == 39:
+			byteLiteral = true
+		case s[i] == '(' || s[i] == '[' || s[i] == '{':
+			bracketCount++
+		case s[i] == ')' || s[i] == ']' || s[i] == '}':
+			bracketCount--
+		case opensTypeArguments(s, i):
+			// e.g. the comma in Result<float, string>
+			angleCount++
+		case s[i] == '>' && angleCount > 0 && s[i-1] != '-':
+			angleCount--
+		case s[i] == '|' && separator != '|' && (len(strings.Trim(current, " ")) == 0 || lambdaParams):
+			// parameters of a lambda can be separated by commas
+			lambdaParams = !lambdaParams
+		case s[i] == separator && bracketCount == 0 && angleCount == 0 && !lambdaParams:
+			parts = append(parts, strings.Trim(current, " "))
+			current = ""
+			continue
+		}
+		current += string(s[i])
+	}
+	if len(strings.Trim(current, " ")) != 0 {
+		parts = append(parts, strings.Trim(current, " "))
+	}
+	return parts
+}
+
+func sortedUnique[T cmp.Ordered](xs []T) []T {
+	sorted := slices.Clone(xs)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+func resetState() {
+	// package level state is left over from any file transpiled before
+	tupleImports, constraintImports, builtinImports, imports = nil, nil, nil, nil
+	packageName = ""
+	definedTypes = nil
+	typeAliases = nil
+	constants, globalConstants = nil, nil
+	loopLabels = nil
+	destructuringCount = 0
+	typeParameters = nil
+	enclosingReturnType, contextType, returnedType = 0, 0, 0
+	conditionalDepth, recovers, propagationCount, hoistedChecks = 0, false, 0, nil
+}
+
 func TranspileTarget(path string) string {
 	// transpile from input of target file name
+	resetState()
 	src, err := os.Open(path)
 	check(err)
 	defer func(src *os.File) {
@@ -121,35 +221,40 @@ func TranspileTarget(path string) string {
 	}
 
 	lines = removeComments(lines)
+	lines = joinMatchBlocks(lines)
 
 	globalScope := parseScope(lines, 0, Global, nil)
 	if _, main := globalScope.functions["main"]; !main {
 		panic("Cannot transpile source file with no main() function")
 	}
 
-	transpiled := "package main" + "\n\n"
+	// transpiled first, as some imports are only known once the code using them is transpiled
+	body := globalScope.transpile()
 
-	importedLibs := make(map[string]struct{})
-	for i := 0; i < len(imports); i++ {
-		importedLibs[imports[i]] = struct{}{}
-	}
+	transpiled := "package main" + "\n\n"
 
-	for lib := range importedLibs {
+	// sorted so that transpiling the same file always gives the same output
+	for _, lib := range sortedUnique(imports) {
 		transpiled += "import " + string([]byte{34}) + lib + string([]byte{34}) // cast into slices fo gofumpt doesnt give annoying warning lol
 		transpiled += "\n"
 	}
 
-	tupImports := map[int]struct{}{}
-	for _, n := range tupleImports {
-		tupImports[n] = struct{}{}
+	for _, k := range sortedUnique(tupleImports) {
+		transpiled += generateTupleCode(k)
+		transpiled += "\n\n"
 	}
 
-	for k := range tupImports {
-		transpiled += generateTupleCode(k)
+	for _, c := range sortedUnique(constraintImports) {
+		transpiled += generateConstraintCode(c)
+		transpiled += "\n\n"
+	}
+
+	for _, b := range sortedUnique(builtinImports) {
+		transpiled += generateBuiltinCode(b)
 		transpiled += "\n\n"
 	}
 
 	transpiled += "\n"
-	transpiled += globalScope.transpile()
+	transpiled += body
 	return transpiled
 }
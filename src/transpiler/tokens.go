@@ -3,27 +3,49 @@ package transpiler
 func binaryOperators() map[string]struct{} {
 	// operators that need an expression on both sides
 	return map[string]struct{}{
-		"+":  {},
-		"-":  {},
-		"*":  {},
-		"/":  {},
-		"&&": {},
-		"||": {},
-		"==": {},
-		"!=": {},
-		">":  {},
-		"<":  {},
-		"<=": {},
-		">=": {},
+		"+":   {},
+		"-":   {},
+		"*":   {},
+		"/":   {},
+		"%":   {},
+		"**":  {},
+		"div": {},
+		"&":   {},
+		"|":   {},
+		"^":   {},
+		"<<":  {},
+		">>":  {},
+		"&&":  {},
+		"||":  {},
+		"==":  {},
+		"!=":  {},
+		">":   {},
+		"<":   {},
+		"<=":  {},
+		">=":  {},
 	}
 }
 
 func numericOperators() map[string]struct{} {
 	return map[string]struct{}{
-		"+": {},
-		"-": {},
-		"*": {},
-		"/": {},
+		"+":   {},
+		"-":   {},
+		"*":   {},
+		"/":   {},
+		"%":   {},
+		"**":  {},
+		"div": {},
+	}
+}
+
+func assignmentOperators() map[string]struct{} {
+	return map[string]struct{}{
+		"=":  {},
+		"+=": {},
+		"-=": {},
+		"*=": {},
+		"/=": {},
+		"%=": {},
 	}
 }
 
@@ -87,6 +109,7 @@ func assignmentKeywords() map[string]struct{} {
 func iterationKeywords() map[string]struct{} {
 	return map[string]struct{}{
 		"loop": {},
+		"in":   {},
 	}
 }
 
@@ -127,6 +150,7 @@ func illegalNames() map[string]struct{} {
 		"let":      {},
 		"mut":      {},
 		"loop":     {},
+		"in":       {},
 		"function": {},
 		"float":    {},
 		"if":       {},
@@ -140,6 +164,27 @@ func illegalNames() map[string]struct{} {
 		"string":   {},
 		"break":    {},
 		"continue": {},
+		"enum":     {},
+		"newtype":  {},
+		"i32":      {},
+		"i64":      {},
+		"u8":       {},
+		"u64":      {},
+		"f32":      {},
+		"f64":      {},
+		"complex":  {},
+		"real":     {},
+		"imag":     {},
+		"match":    {},
+		"div":      {},
+		"numeric":  {},
+		"ordered":  {},
+		"Option":   {},
+		"Result":   {},
+		"Some":     {},
+		"None":     {},
+		"Ok":       {},
+		"Err":      {},
 
 		// keywords in both
 
@@ -2,6 +2,8 @@ package transpiler
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 )
 
 //line which is just "}"
@@ -36,11 +38,162 @@ func generateTupleCode(n int) string {
 	return transpiled
 }
 
+func generateConstraintCode(constraint string) string {
+	// generates interface used as a constraint on type parameters
+	// any and comparable are already built into Go
+	transpiled := "type " + constraint + " interface {"
+	transpiled += "\n"
+	switch constraint {
+	case "numeric":
+		transpiled += "~int | ~int32 | ~int64 | ~uint8 | ~uint64 | ~float32 | ~float64"
+	case "ordered":
+		transpiled += "~int | ~int32 | ~int64 | ~uint8 | ~uint64 | ~float32 | ~float64 | ~string" // byte is the same as uint8 in Go
+	}
+	transpiled += "\n"
+	transpiled += "}"
+	return transpiled
+}
+
+func generateBuiltinCode(name string) string {
+	// generates Option, Result, the value used by ? to return early, ** for integers or the check on steps of ranges
+	if name == "power" {
+		transpiled := "func _pow[T ~int | ~int32 | ~int64 | ~uint8 | ~uint64](base T, exponent T) T {"
+		transpiled += "\n"
+		transpiled += "if exponent < 0 {"
+		transpiled += "\n"
+		transpiled += "panic(" + string([]byte{34}) + "negative exponent" + string([]byte{34}) + ")"
+		transpiled += "\n"
+		transpiled += "}"
+		transpiled += "\n"
+		transpiled += "result := T(1)"
+		transpiled += "\n"
+		transpiled += "for exponent > 0 {"
+		transpiled += "\n"
+		transpiled += "if exponent%2 == 1 {"
+		transpiled += "\n"
+		transpiled += "result *= base"
+		transpiled += "\n"
+		transpiled += "}"
+		transpiled += "\n"
+		transpiled += "base *= base"
+		transpiled += "\n"
+		transpiled += "exponent /= 2"
+		transpiled += "\n"
+		transpiled += "}"
+		transpiled += "\n"
+		transpiled += "return result"
+		transpiled += "\n"
+		transpiled += "}"
+		return transpiled
+	}
+	if name == "step" {
+		transpiled := "func _checkStep[T ~int | ~int32 | ~int64 | ~uint8 | ~uint64 | ~float32 | ~float64](step T) T {"
+		transpiled += "\n"
+		transpiled += "if step == 0 {"
+		transpiled += "\n"
+		transpiled += "panic(" + string([]byte{34}) + "step of range cannot be zero" + string([]byte{34}) + ")"
+		transpiled += "\n"
+		transpiled += "}"
+		transpiled += "\n"
+		transpiled += "return step"
+		transpiled += "\n"
+		transpiled += "}"
+		return transpiled
+	}
+	if name == "propagation" {
+		transpiled := "type propagation struct {"
+		transpiled += "\n"
+		transpiled += "err any // nil for None"
+		transpiled += "\n"
+		transpiled += "}"
+		// used by ? where it can't return before the statement
+		if slices.Contains(builtinImports, "Option") {
+			transpiled += "\n"
+			transpiled += "func (e Option[T]) propagate() T {"
+			transpiled += "\n"
+			transpiled += "if e.tag == Option_None {"
+			transpiled += "\n"
+			transpiled += "panic(propagation{})"
+			transpiled += "\n"
+			transpiled += "}"
+			transpiled += "\n"
+			transpiled += "return e.Some_0"
+			transpiled += "\n"
+			transpiled += "}"
+		}
+		if slices.Contains(builtinImports, "Result") {
+			transpiled += "\n"
+			transpiled += "func (e Result[T, E]) propagate() T {"
+			transpiled += "\n"
+			transpiled += "if e.tag == Result_Err {"
+			transpiled += "\n"
+			transpiled += "panic(propagation{e.Err_0})"
+			transpiled += "\n"
+			transpiled += "}"
+			transpiled += "\n"
+			transpiled += "return e.Ok_0"
+			transpiled += "\n"
+			transpiled += "}"
+		}
+		return transpiled
+	}
+
+	// variants of Option and Result, the second doesn't carry a value in Option
+	first, second := "Some", "None"
+	typeParams, typeArgs := "T any", "T"
+	if name == "Result" {
+		first, second = "Ok", "Err"
+		typeParams, typeArgs = "T any, E any", "T, E"
+	}
+	receiver := "(e " + name + "[" + typeArgs + "])"
+
+	transpiled := "const ("
+	transpiled += "\n"
+	transpiled += name + "_" + first + " = iota"
+	transpiled += "\n"
+	transpiled += name + "_" + second
+	transpiled += "\n"
+	transpiled += ")"
+	transpiled += "\n"
+
+	transpiled += "type " + name + "[" + typeParams + "] struct {"
+	transpiled += "\n"
+	transpiled += "tag int"
+	transpiled += "\n"
+	transpiled += first + "_0 T"
+	transpiled += "\n"
+	if name == "Result" {
+		transpiled += "Err_0 E"
+		transpiled += "\n"
+	}
+	transpiled += "}"
+	transpiled += "\n"
+
+	transpiled += "func " + receiver + " String() string {"
+	transpiled += "\n"
+	transpiled += "if e.tag == " + name + "_" + first + " {"
+	transpiled += "\n"
+	transpiled += "return " + string([]byte{34}) + first + "(" + string([]byte{34}) + " + fmt.Sprint(e." + first + "_0) + " + string([]byte{34}) + ")" + string([]byte{34})
+	transpiled += "\n"
+	transpiled += "}"
+	transpiled += "\n"
+	if name == "Result" {
+		transpiled += "return " + string([]byte{34}) + "Err(" + string([]byte{34}) + " + fmt.Sprint(e.Err_0) + " + string([]byte{34}) + ")" + string([]byte{34})
+	} else {
+		transpiled += "return " + string([]byte{34}) + "None" + string([]byte{34})
+	}
+	transpiled += "\n"
+	transpiled += "}"
+	return transpiled
+}
+
 func (E Expression) transpile() string {
 	items := E.items
 	var transpiled string
-	for _, item := range items {
-		if isTupleIndexing(item) {
+	for i, item := range items {
+		if node, ok := E.nodes[i]; ok {
+			transpiled += node.transpile()
+		} else if isTupleIndexing(item) {
 			transpiled += transpileTupleIndexing(item)
 		} else {
 			transpiled += item
@@ -56,28 +209,34 @@ func (E Expression) transpile() string {
 func (D Declaration) transpile() string {
 	transpiled := "var "
 	transpiled += D.v.identifier + " "
-	if D.v.dataType != Float {
-		transpiled += D.v.dataType.String() + " "
-	} else {
-		transpiled += D.v.dataType.String() + "64" + " "
-	}
+	transpiled += D.v.dataType.goType() + " "
 	transpiled += " = "
 	transpiled += D.e.transpile()
 	return transpiled
 }
 
+func (C ConstantDeclaration) transpile() string {
+	transpiled := "const "
+	transpiled += C.identifier + " "
+	transpiled += C.dataType.goType()
+	transpiled += " = "
+	transpiled += C.value
+	return transpiled
+}
+
+func (T TypeDefinition) transpile() string {
+	if T.alias {
+		return "type " + T.identifier + " = " + T.dataType.goType()
+	}
+	newtype, _ := T.dataType.defined()
+	return "type " + T.identifier + " " + newtype.underlying.goType()
+}
+
 func (A ArrayDeclaration) transpile() string {
 	transpiled := "var "
 	transpiled += A.arr.identifier + " "
 
-	transpiled += "["
-	transpiled += fmt.Sprintf("%d", A.arr.dataType.dimensions[0])
-	transpiled += "]"
-	if A.arr.dataType.baseType != Float {
-		transpiled += A.arr.dataType.baseType.String()
-	} else {
-		transpiled += A.arr.dataType.baseType.String() + "64"
-	}
+	transpiled += A.arr.dataType.goType()
 	transpiled += " = "
 	transpiled += A.expr.transpile()
 	return transpiled
@@ -86,12 +245,7 @@ func (A ArrayDeclaration) transpile() string {
 func (A ArrayValue[primitiveType]) transpile() string {
 	var transpiled string
 	transpiled += "[" + fmt.Sprintf("%d", A.length) + "]"
-
-	if A.baseType != Float {
-		transpiled += A.baseType.String()
-	} else {
-		transpiled += A.baseType.String() + "64"
-	}
+	transpiled += A.baseType.goType()
 	transpiled += "{"
 	for i, elem := range A.elements {
 		transpiled += elem.transpile()
@@ -104,8 +258,22 @@ func (A ArrayValue[primitiveType]) transpile() string {
 }
 
 func (F Function) transpile() string {
-	transpiled := "func "
-	transpiled += F.identifier
+	transpiled := "func"
+	if len(F.identifier) != 0 {
+		// lambdas don't have an identifier
+		transpiled += " " + F.identifier
+	}
+	if len(F.typeParams) != 0 {
+		transpiled += "["
+		for i, T := range F.typeParams {
+			d, _ := T.defined()
+			transpiled += d.identifier + " " + d.constraint
+			if i != len(F.typeParams)-1 {
+				transpiled += ", "
+			}
+		}
+		transpiled += "]"
+	}
 	transpiled += "("
 
 	var varCount, arrCount, tupCount int
@@ -114,39 +282,21 @@ func (F Function) transpile() string {
 		if t == VariableParameter {
 			p := F.parameters[varCount]
 			transpiled += p.identifier
-			if p.dataType != Float {
-				transpiled += " " + p.dataType.String()
-			} else {
-				transpiled += " " + p.dataType.String() + "64"
-			}
+			transpiled += " " + p.dataType.goType()
 			if i != len(F.paramsOrder)-1 {
 				transpiled += ", "
 			}
 			varCount++
+		} else if t == VariadicParameter {
+			// always the last parameter
+			arr := F.arrays[arrCount]
+			transpiled += arr.identifier
+			transpiled += " ..." + arr.dataType.baseType.goType()
+			arrCount++
 		} else if t == TupleParameter {
 			t := F.tuples[tupCount]
 			transpiled += t.identifier
-			transpiled += " tuple"
-			transpiled += fmt.Sprintf("%d", len(t.pattern.dataTypes))
-			transpiled += "["
-			for i, T := range t.pattern.dataTypes {
-				switch T {
-				case Int:
-					transpiled += "int"
-				case Float:
-					transpiled += "float64"
-				case Bool:
-					transpiled += "bool"
-				case Byte:
-					transpiled += "byte"
-				case String:
-					transpiled += "string"
-				}
-				if i != len(t.pattern.dataTypes)-1 {
-					transpiled += ", "
-				}
-			}
-			transpiled += "]"
+			transpiled += " " + tupleType(t.pattern).goType()
 			if i != len(F.paramsOrder)-1 {
 				transpiled += ", "
 			}
@@ -155,11 +305,7 @@ func (F Function) transpile() string {
 			arr := F.arrays[arrCount]
 			transpiled += arr.identifier
 			transpiled += " "
-			transpiled += "[" + fmt.Sprintf("%d", arr.dataType.dimensions[0]) + "]"
-			transpiled += arr.dataType.baseType.String()
-			if arr.dataType.baseType == Float {
-				transpiled += "64"
-			}
+			transpiled += arr.dataType.goType()
 			if i != len(F.paramsOrder)-1 {
 				transpiled += ", "
 			}
@@ -170,63 +316,163 @@ func (F Function) transpile() string {
 	transpiled += ")"
 
 	if F.returnDomain == tuple {
-		T := F.tupleReturnType
-		transpiled += " " + "tuple"
-		transpiled += fmt.Sprintf("%d", len(T.dataTypes))
-		transpiled += "["
-		for i, dataType := range T.dataTypes {
-			switch dataType {
-			case Int:
-				transpiled += "int"
-			case Float:
-				transpiled += "float64"
-			case Bool:
-				transpiled += "bool"
-			case Byte:
-				transpiled += "byte"
-			case String:
-				transpiled += "string"
-			}
-			if i != len(T.dataTypes)-1 {
-				transpiled += ", "
-			}
-		}
-		transpiled += "]"
+		transpiled += " " + tupleType(F.tupleReturnType).goType()
 	} else if F.returnDomain == derived {
 		if len(F.derivedReturnType.dimensions) == 0 {
 			panic("shouldn't be possible to panic here 🙏")
 		}
-		transpiled += " " + "[" + fmt.Sprintf("%d", F.derivedReturnType.dimensions[0]) + "]" + F.derivedReturnType.baseType.String()
-		if F.derivedReturnType.baseType == Float {
-			transpiled += "64"
-		}
+		transpiled += " " + F.derivedReturnType.goType()
+	} else if F.recovers {
+		// named so that it can be set after recovering from ?
+		transpiled += " (_r " + F.returnType.goType() + ")"
 	} else {
 		if F.returnType != IO {
-			transpiled += " " + F.returnType.String()
-			if F.returnType == Float {
-				transpiled += "64"
-			}
+			transpiled += " " + F.returnType.goType()
 		}
 	}
 
 	transpiled += " {"
 
+	if F.recovers {
+		// ? panics if the value is None or Err, which is turned back into a return value here
+		transpiled += "\n"
+		transpiled += "defer func() {"
+		transpiled += "\n"
+		transpiled += "if p := recover(); p != nil {"
+		transpiled += "\n"
+		if isResult(F.returnType) {
+			transpiled += "e, ok := p.(propagation)"
+			transpiled += "\n"
+			transpiled += "if !ok {"
+		} else {
+			transpiled += "if _, ok := p.(propagation); !ok {"
+		}
+		transpiled += "\n"
+		transpiled += "panic(p) // not caused by ?"
+		transpiled += "\n"
+		transpiled += "}"
+		transpiled += "\n"
+		if isResult(F.returnType) {
+			d, _ := F.returnType.defined()
+			transpiled += "_r = " + F.returnType.goType() + "{tag: Result_Err, Err_0: e.err.(" + typeArguments(d)[1].goType() + ")}"
+		} else {
+			transpiled += "_r = " + F.returnType.goType() + "{tag: Option_None}"
+		}
+		transpiled += "\n"
+		transpiled += "}"
+		transpiled += "\n"
+		transpiled += "}()"
+	}
+
+	lengths := make(map[string]string) // length parameter -> array it was taken from
+	for _, arr := range F.arrays {
+		if len(arr.lengthParam) == 0 {
+			continue
+		}
+		if first, ok := lengths[arr.lengthParam]; ok {
+			// arrays sharing a length parameter must have the same length
+			transpiled += "\n"
+			transpiled += "if len(" + arr.identifier + ") != " + arr.lengthParam + " {"
+			transpiled += "\n"
+			transpiled += "panic(" + string([]byte{34}) + arr.identifier + " and " + first + " must have the same length" + string([]byte{34}) + ")"
+			transpiled += "\n"
+			transpiled += "}"
+			continue
+		}
+		lengths[arr.lengthParam] = arr.identifier
+		transpiled += "\n"
+		transpiled += arr.lengthParam + " := len(" + arr.identifier + ")"
+		transpiled += "\n"
+		transpiled += "_ = " + arr.lengthParam
+	}
+
+	for _, tup := range F.tuples {
+		if tup.destructuring != nil {
+			transpiled += "\n"
+			transpiled += tup.destructuring.transpile()
+		}
+	}
+
 	return transpiled
 }
 
 func (L Loop) transpile() string {
-	transpiled := "for "
+	var transpiled string
+	if L.label != nil && L.label.used {
+		transpiled += L.label.goLabel + ":"
+		transpiled += "\n"
+	}
+	if L.iterator != nil {
+		return transpiled + L.iterator.transpile()
+	}
+	if L.condition.transpile() == "true" {
+		// Go only knows that a loop without a condition can't end without break
+		return transpiled + "for {"
+	}
+	transpiled += "for "
 	transpiled += L.condition.transpile()
 	transpiled += " {"
 	return transpiled
 }
 
+func (it Iterator) transpile() string {
+	if it.array != nil {
+		transpiled := "for _, " + it.identifier + " := range " + it.array.transpile() + " {"
+		transpiled += "\n"
+		transpiled += "_ = " + it.identifier
+		return transpiled
+	}
+
+	// the end and step are stored before the loop unless they are literals
+	names, values := []string{it.identifier}, []string{it.start.transpile()}
+	end := it.end.transpile()
+	if _, ok := numberLiteral(end); !ok {
+		names, values = append(names, "_end"), append(values, end)
+		end = "_end"
+	}
+	step := "1"
+	if len(it.step.items) != 0 {
+		step = strings.Trim(it.step.transpile(), " ")
+	}
+
+	var condition string
+	if v, ok := numberLiteral(step); ok {
+		if v < 0 {
+			condition = it.identifier + " > " + end
+		} else {
+			condition = it.identifier + " < " + end
+		}
+	} else {
+		// the direction of a step which isn't a literal is only known when the program runs
+		builtinImports = append(builtinImports, "step")
+		names, values = append(names, "_step"), append(values, "_checkStep("+step+")")
+		step = "_step"
+		condition = it.identifier + " < " + end
+		if !unsignedType(it.dataType) {
+			condition = "_step > 0 && " + condition + " || _step < 0 && " + it.identifier + " > " + end
+		}
+	}
+
+	transpiled := "for " + strings.Join(names, ", ") + " := " + strings.Join(values, ", ") + "; " + condition + "; "
+	if step == "1" {
+		transpiled += it.identifier + "++"
+	} else {
+		transpiled += it.identifier + " += " + step
+	}
+	transpiled += " {"
+	return transpiled
+}
+
 func (B BreakStatement) transpile() string {
+	var label string
+	if B.label != nil {
+		label = " " + B.label.goLabel
+	}
 	switch B.T {
 	case Break:
-		return "break"
+		return "break" + label
 	case Continue:
-		return "continue"
+		return "continue" + label
 	}
 	panic("should be literally impossible for transpiler to ever panic here lol")
 }
@@ -249,7 +495,10 @@ func (S SelectionStatement) transpile() string {
 func (A Assignment) transpile() string {
 	var transpiled string
 	transpiled += A.v.identifier
-	transpiled += " = "
+	if A.operator == "++" || A.operator == "--" {
+		return transpiled + A.operator
+	}
+	transpiled += " " + A.operator + " "
 	transpiled += A.e.transpile()
 	return transpiled
 }
@@ -269,29 +518,68 @@ func (A ArrayIndexAssignment) transpile() string {
 	transpiled += A.arrIndex.index.transpile()
 	transpiled += "]"
 
-	transpiled += " = "
+	if A.operator == "++" || A.operator == "--" {
+		return transpiled + A.operator
+	}
+	transpiled += " " + A.operator + " "
 	transpiled += A.value.transpile()
 	return transpiled
 }
 
 func (A ArrayExpression) transpile() string {
+	var transpiled string
 	if len(A.literal.values) > 0 {
 		// fine as there are no operators that work on arrays
-		return A.literal.transpile()
+		transpiled = A.literal.transpile()
+	} else if A.node != nil {
+		transpiled = A.node.transpile()
+	} else {
+		transpiled = strings.Trim(A.stringValue, " ")
 	}
-	return A.stringValue
-	// fine as function call can't contain literals
+	if len(A.conversion.dimensions) != 0 {
+		// converting a slice to an array copies its elements
+		return A.conversion.goType() + "(" + transpiled + ")"
+	}
+	return transpiled
 }
 
-func (B BaseArray) transpile() string {
-	var transpiled string
-	transpiled += "[" + fmt.Sprintf("%d", B.length) + "]"
+func (B BinaryOperation) transpile() string {
+	// imports are only added here, as operations in constant expressions are folded into a literal
+	left, right := B.left.transpile(), B.right.transpile()
+	if integerType(B.dataType) {
+		if B.operator == "**" {
+			builtinImports = append(builtinImports, "power")
+			return "_pow(" + left + ", " + right + ")"
+		}
+		// dividing integers in Go already rounds towards zero
+		return "(" + left + " / " + right + ")"
+	}
+	imports = append(imports, "math")
 
-	if B.dataType != Float {
-		transpiled += B.dataType.String()
+	var transpiled string
+	if B.dataType == Float {
+		if B.operator == "**" {
+			return "math.Pow(" + left + ", " + right + ")"
+		}
+		return "math.Trunc(" + left + " / " + right + ")"
+	}
+	// the math package only works with float64
+	if B.operator == "**" {
+		transpiled = "math.Pow(float64(" + left + "), float64(" + right + "))"
 	} else {
-		transpiled += B.dataType.String() + "64"
+		transpiled = "math.Trunc(float64(" + left + " / " + right + "))"
 	}
+	return B.dataType.goType() + "(" + transpiled + ")"
+}
+
+func (A ArraySlice) transpile() string {
+	return A.arrayID + "[" + A.start.transpile() + ":" + A.end.transpile() + "]"
+}
+
+func (B BaseArray) transpile() string {
+	var transpiled string
+	transpiled += "[" + fmt.Sprintf("%d", B.length) + "]"
+	transpiled += B.dataType.goType()
 	transpiled += "{"
 	for i, elem := range B.values {
 		transpiled += elem.transpile()
@@ -307,27 +595,7 @@ func (B BaseArray) transpile() string {
 func (T TupleLiteral) transpile() string {
 	// necessary struct and interface already created above
 	var transpiled string
-	transpiled += " tuple"
-	transpiled += fmt.Sprintf("%d", len(T.values))
-	transpiled += "["
-	for i, expr := range T.values {
-		switch expr.dataType {
-		case Int:
-			transpiled += "int"
-		case Float:
-			transpiled += "float64"
-		case Bool:
-			transpiled += "bool"
-		case Byte:
-			transpiled += "byte"
-		case String:
-			transpiled += "string"
-		}
-		if i != len(T.values)-1 {
-			transpiled += ", "
-		}
-	}
-	transpiled += "]"
+	transpiled += " " + tupleType(T.pattern).goType()
 	transpiled += "{"
 	for i, e := range T.values {
 		transpiled += "v" + fmt.Sprintf("%d", i) + ":" + " "
@@ -341,27 +609,23 @@ func (T TupleLiteral) transpile() string {
 }
 
 func (F FunctionCall) transpile() string {
-	var transpiled string
-	transpiled += F.functionName + "("
-	var varCount, arrCount, tupCount int
-	for i := 0; i < len(F.order); i++ {
-		switch F.order[i] {
-		case VariableParameter:
-			transpiled += F.parameters[varCount].transpile()
-			varCount++
-		case ArrayParameter:
-			transpiled += F.arrays[arrCount].identifier
-			arrCount++
-		case TupleParameter:
-			transpiled += F.tuples[tupCount].identifier
-			tupCount++
-		}
-		if i != len(F.order)-1 {
-			transpiled += ", "
-		}
+	var arguments []string
+	for _, arg := range F.arguments {
+		arguments = append(arguments, arg.transpile())
 	}
-	transpiled += ")"
-	return transpiled
+	return F.functionName + "(" + strings.Join(arguments, ", ") + ")"
+}
+
+func (S SliceArgument) transpile() string {
+	if S.spread {
+		return S.array.asSlice() + "..."
+	}
+	return S.array.asSlice()
+}
+
+func (C ComparedCollection) transpile() string {
+	// brackets stop Go from reading a literal in an if condition as the start of its body
+	return "(" + C.value.transpile() + ")"
 }
 
 func (T TupleExpression) transpile() string {
@@ -369,6 +633,10 @@ func (T TupleExpression) transpile() string {
 		return T.literal.transpile()
 	} else if T.exprType == FnCall {
 		return T.fnCall.transpile()
+	} else if T.exprType == TupleMember {
+		return T.member.transpile()
+	} else if T.exprType == TupleValue {
+		return T.value.transpile()
 	}
 	return T.t.identifier
 }
@@ -376,32 +644,35 @@ func (T TupleExpression) transpile() string {
 func (T TupleDeclaration) transpile() string {
 	transpiled := "var "
 	transpiled += T.t.identifier + " "
-	transpiled += " tuple"
-	transpiled += fmt.Sprintf("%d", len(T.t.pattern.dataTypes))
-	transpiled += "["
-	for i, dataType := range T.t.pattern.dataTypes {
-		switch dataType {
-		case Int:
-			transpiled += "int"
-		case Float:
-			transpiled += "float64"
-		case Bool:
-			transpiled += "bool"
-		case Byte:
-			transpiled += "byte"
-		case String:
-			transpiled += "string"
-		}
-		if i != len(T.t.pattern.dataTypes)-1 {
-			transpiled += ", "
-		}
-	}
-	transpiled += "]"
+	transpiled += " " + tupleType(T.t.pattern).goType()
 	transpiled += " = "
 	transpiled += T.e.transpile()
 	return transpiled
 }
 
+func (D Destructuring) transpile() string {
+	var transpiled string
+	if D.e != nil {
+		transpiled += "var " + D.tuple + " " + D.dataType.goType() + " = " + D.e.transpile()
+		transpiled += "\n"
+	}
+	used := false
+	for i, name := range D.names {
+		if name == "_" {
+			continue
+		}
+		used = true
+		transpiled += "var " + name + " " + D.pattern.dataTypes[i].goType() + " = " + D.tuple + fmt.Sprintf(".v%d", i)
+		transpiled += "\n"
+		transpiled += "_ = " + name // in case the name is never used
+		transpiled += "\n"
+	}
+	if !used {
+		transpiled += "_ = " + D.tuple
+	}
+	return strings.TrimSuffix(transpiled, "\n")
+}
+
 func (T TupleAssignment) transpile() string {
 	transpiled := T.t.identifier
 	transpiled += " = "
@@ -429,28 +700,377 @@ func (M Macro) transpile() string {
 		panic("macro not supported by transpile()")
 	}
 
-	transpiled += M.value.transpile()
+	var values []string
+	for _, v := range M.values {
+		values = append(values, v.transpile())
+	}
+	// fmt.Print only puts spaces between arguments which aren't strings, so
+	// they are added explicitly to print the same way as println!()
+	separator := ", "
+	if M.T == Print {
+		separator = `, " ", `
+	}
+	transpiled += "(" + strings.Join(values, separator) + ")"
 	return transpiled
 }
 
 func (s Scope) transpile() string {
 	var transpiled string
+	enclosing := hoistedChecks // checks for the statement this scope is part of
 
 	for _, item := range s.items {
 		T := typeOfItem(item)
+		hoistedChecks = nil
 
+		var statement string
 		if T == "Expression" || T == "ArrayExpression" || T == "TupleExpression" {
-			transpiled += "return " + item.transpile()
+			statement = "return " + item.transpile()
 			// only way for an expression to come alone
 		} else {
-			transpiled += item.transpile()
+			statement = item.transpile()
 		}
+		for _, check := range hoistedChecks {
+			// values used with ? are checked before the statement
+			transpiled += check
+			transpiled += "\n"
+		}
+		transpiled += statement
 		transpiled += "\n"
 	}
 
+	hoistedChecks = enclosing
+	return transpiled
+}
+
+func (R RecordDeclaration) transpile() string {
+	record, _ := R.dataType.defined()
+	transpiled := "type " + record.identifier + " struct {"
+	transpiled += "\n"
+	for _, f := range record.fields {
+		transpiled += f.identifier + " " + f.dataType.goType()
+		transpiled += "\n"
+	}
+	transpiled += "}"
 	return transpiled
 }
 
+func (R RecordLiteral) transpile() string {
+	T := R.dataType.goType()
+	if R.update {
+		// functional update copies the record and then sets the fields
+		transpiled := "func(_r " + T + ") " + T + " {"
+		for i, field := range R.fields {
+			transpiled += " _r." + field + " = " + R.values[i].transpile() + ";"
+		}
+		transpiled += " return _r }(" + R.base.transpile() + ")"
+		return transpiled
+	}
+	transpiled := T + "{"
+	for i, field := range R.fields {
+		transpiled += field + ": " + R.values[i].transpile()
+		if i != len(R.fields)-1 {
+			transpiled += ", "
+		}
+	}
+	transpiled += "}"
+	return transpiled
+}
+
+func (M MemberAccess) transpile() string {
+	transpiled := M.root
+	for _, selector := range M.selectors {
+		transpiled += selector
+	}
+	return transpiled
+}
+
+func (M MemberAssignment) transpile() string {
+	transpiled := M.member.transpile()
+	if M.operator == "++" || M.operator == "--" {
+		return transpiled + M.operator
+	}
+	transpiled += " " + M.operator + " "
+	transpiled += M.value.transpile()
+	return transpiled
+}
+
+func (E EnumDeclaration) transpile() string {
+	enum, _ := E.dataType.defined()
+	var transpiled string
+
+	if !hasPayload(E.dataType) {
+		transpiled += "type " + enum.identifier + " int"
+		transpiled += "\n"
+		transpiled += "const ("
+		transpiled += "\n"
+		for i := range enum.variants {
+			transpiled += variantConstant(E.dataType, i)
+			if i == 0 {
+				transpiled += " " + enum.identifier + " = iota"
+			}
+			transpiled += "\n"
+		}
+		transpiled += ")"
+		transpiled += "\n"
+		transpiled += "func (e " + enum.identifier + ") String() string {"
+		transpiled += "\n"
+		transpiled += "return [...]string{"
+		for i, v := range enum.variants {
+			transpiled += string([]byte{34}) + v.identifier + string([]byte{34})
+			if i != len(enum.variants)-1 {
+				transpiled += ", "
+			}
+		}
+		transpiled += "}[e]"
+		transpiled += "\n"
+		transpiled += "}"
+		return transpiled
+	}
+
+	transpiled += "const ("
+	transpiled += "\n"
+	for i := range enum.variants {
+		transpiled += variantConstant(E.dataType, i)
+		if i == 0 {
+			transpiled += " = iota"
+		}
+		transpiled += "\n"
+	}
+	transpiled += ")"
+	transpiled += "\n"
+
+	transpiled += "type " + enum.identifier + " struct {"
+	transpiled += "\n"
+	transpiled += "tag int"
+	transpiled += "\n"
+	for i, v := range enum.variants {
+		for j, T := range v.payload {
+			transpiled += payloadField(E.dataType, i, j) + " " + T.goType()
+			transpiled += "\n"
+		}
+	}
+	transpiled += "}"
+	transpiled += "\n"
+
+	// String() so that print!() shows the variant rather than the struct
+	transpiled += "func (e " + enum.identifier + ") String() string {"
+	transpiled += "\n"
+	transpiled += "switch e.tag {"
+	transpiled += "\n"
+	for i, v := range enum.variants {
+		transpiled += "case " + variantConstant(E.dataType, i) + ":"
+		transpiled += "\n"
+		transpiled += "return " + string([]byte{34}) + v.identifier
+		if len(v.payload) != 0 {
+			transpiled += "(" + string([]byte{34})
+			for j := range v.payload {
+				transpiled += " + fmt.Sprint(e." + payloadField(E.dataType, i, j) + ")"
+				if j != len(v.payload)-1 {
+					transpiled += " + " + string([]byte{34}) + ", " + string([]byte{34})
+				}
+			}
+			transpiled += " + " + string([]byte{34}) + ")"
+		}
+		transpiled += string([]byte{34})
+		transpiled += "\n"
+	}
+	transpiled += "}"
+	transpiled += "\n"
+	transpiled += "return " + string([]byte{34, 34})
+	transpiled += "\n"
+	transpiled += "}"
+	return transpiled
+}
+
+func (E EnumValue) transpile() string {
+	if !hasPayload(E.dataType) {
+		return variantConstant(E.dataType, E.variant)
+	}
+	transpiled := E.dataType.goType() + "{tag: " + variantConstant(E.dataType, E.variant)
+	for i, value := range E.values {
+		transpiled += ", " + payloadField(E.dataType, E.variant, i) + ": " + value.transpile()
+	}
+	transpiled += "}"
+	return transpiled
+}
+
+func (M MatchExpression) transpile() string {
+	transpiled := "func(_m " + M.scrutineeType.goType() + ") " + M.dataType.goType() + " {"
+	transpiled += "\n"
+	exhausted := false // whether the last arm matches anything without a check
+	for _, arm := range M.arms {
+		conditions, bindings := arm.pattern.conditions("_m")
+		var body string
+		for _, b := range bindings {
+			body += b.name + " := " + b.path
+			body += "\n"
+			body += "_ = " + b.name // in case the binding is only used in the guard or not at all
+			body += "\n"
+		}
+		if arm.guarded {
+			body += "if " + arm.guard.transpile() + " {"
+			body += "\n"
+			body += "return " + arm.value.transpile()
+			body += "\n"
+			body += "}"
+			body += "\n"
+		} else {
+			body += "return " + arm.value.transpile()
+			body += "\n"
+		}
+
+		if len(conditions) == 0 && !arm.guarded {
+			transpiled += body
+			exhausted = true
+			break
+		}
+		if len(conditions) == 0 {
+			transpiled += "{"
+		} else {
+			transpiled += "if " + strings.Join(conditions, " && ") + " {"
+		}
+		transpiled += "\n"
+		transpiled += body
+		transpiled += "}"
+		transpiled += "\n"
+	}
+	if !exhausted {
+		// exhaustiveness already checked so this can't happen
+		transpiled += "panic(" + string([]byte{34}) + "unreachable" + string([]byte{34}) + ")"
+		transpiled += "\n"
+	}
+	transpiled += "}(" + M.scrutinee + ")"
+	return transpiled
+}
+
+func (L Lambda) transpile() string {
+	transpiled := L.fn.transpile()
+	transpiled += "\n"
+	transpiled += "return " + L.body.transpile()
+	transpiled += "\n"
+	transpiled += "}"
+	return captureByValue(transpiled, L.captured, L.dataType)
+}
+
+func captureByValue(closure string, captured []string, T primitiveType) string {
+	if len(captured) == 0 {
+		return closure
+	}
+
+	// copy captured variables so that they are captured by value
+	wrapped := "func() " + T.goType() + " {"
+	wrapped += "\n"
+	for _, c := range captured {
+		wrapped += c + " := " + c
+		wrapped += "\n"
+	}
+	wrapped += "return " + closure
+	wrapped += "\n"
+	wrapped += "}()"
+	return wrapped
+}
+
+func (L LocalFunction) transpile() string {
+	// declared before it is assigned so that it can call itself
+	transpiled := "var " + L.fn.identifier + " " + L.dataType.goType()
+	transpiled += "\n"
+	transpiled += "_ = " + L.fn.identifier
+	transpiled += "\n"
+
+	literal := L.fn
+	literal.identifier = ""
+	closure := literal.transpile()
+	closure += "\n"
+	closure += L.body.transpile()
+	closure += "}"
+	transpiled += L.fn.identifier + " = " + captureByValue(closure, L.captured, L.dataType)
+	return transpiled
+}
+
+func (P Propagation) transpile() string {
+	if !P.hoisted {
+		return P.value.transpile() + ".propagate()"
+	}
+	// the value is stored and checked before the statement, which returns early if there is no value
+	value := P.value.transpile() // transpiled first as it can contain other values checked by ?
+	temporary := fmt.Sprintf("_p%d", propagationCount)
+	propagationCount++
+
+	d, _ := P.value.dataType.defined()
+	first, second, returned := "Some", "None", "{tag: Option_None}"
+	if d.generic == "Result" {
+		first, second, returned = "Ok", "Err", "{tag: Result_Err, Err_0: "+temporary+".Err_0}"
+	}
+	check := temporary + " := " + value
+	check += "\n"
+	check += "if " + temporary + ".tag == " + d.generic + "_" + second + " {"
+	check += "\n"
+	check += "return " + P.returnType.goType() + returned
+	check += "\n"
+	check += "}"
+	hoistedChecks = append(hoistedChecks, check)
+	return temporary + "." + first + "_0"
+}
+
+func (C Conversion) transpile() string {
+	return C.dataType.goType() + "(" + C.value.transpile() + ")"
+}
+
+func (N NumericLiteral) transpile() string {
+	return N.dataType.goType() + "(" + N.value + ")"
+}
+
+func (C ComplexFunction) transpile() string {
+	var args []string
+	for _, arg := range C.args {
+		args = append(args, arg.transpile())
+	}
+	return C.identifier + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (E EarlyReturn) transpile() string {
+	if E.value == nil {
+		return "return"
+	}
+	return "return " + E.value.transpile()
+}
+
+func (V ValueBlock) transpile() string {
+	var transpiled string
+	if V.declaration != nil {
+		transpiled += "var " + V.declaration.identifier + " " + V.declaration.dataType.goType()
+		transpiled += "\n"
+		transpiled += "_ = " + V.declaration.identifier // in case the variable is never used
+		transpiled += "\n"
+	}
+	for i, branch := range V.branches {
+		switch {
+		case len(V.conditions) == 0:
+			transpiled += "{" // block
+		case i == 0:
+			transpiled += "if " + V.conditions[i].transpile() + " {"
+		case i < len(V.conditions):
+			transpiled += "} else if " + V.conditions[i].transpile() + " {"
+		default:
+			transpiled += "} else {"
+		}
+		transpiled += "\n"
+		transpiled += branch.transpile()
+	}
+	if !V.multiLine {
+		transpiled += "}"
+	}
+	return transpiled
+}
+
+func (I IfExpression) transpile() string {
+	return "func() " + I.dataType.goType() + " {\n" + I.block.transpile() + "\n}()"
+}
+
+func (B BlockResult) transpile() string {
+	return B.target.assign + " " + B.value.transpile()
+}
+
 func (S ScopeCloser) transpile() string {
 	return S.closer
 }
@@ -2,6 +2,7 @@ package transpiler
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -12,6 +13,8 @@ const (
 	Literal TupleExpressionType = iota
 	TupleVariable
 	FnCall
+	TupleMember
+	TupleValue
 )
 
 // tuple implementaion in Go
@@ -28,13 +31,15 @@ type tuple3[T0 any, T1 any, T2 any] struct {
 */
 
 type Tuple struct {
-	identifier string
-	pattern    TuplePattern
-	mut        bool
+	identifier    string
+	pattern       TuplePattern
+	mut           bool
+	destructuring *Destructuring // only used by parameters which are destructured e.g. (x, y): (float, float)
 }
 
 type TupleLiteral struct {
-	values []Expression
+	values  []Transpileable // elements can be expressions, arrays or other tuples
+	pattern TuplePattern
 }
 
 type TuplePattern struct {
@@ -52,9 +57,11 @@ type TupleIndexing struct {
 }
 
 type TupleExpression struct {
-	fnCall   FunctionCall // optional
-	literal  TupleLiteral // optional
-	t        Tuple        // optional - one of three must be present
+	fnCall   FunctionCall  // optional
+	literal  TupleLiteral  // optional
+	t        Tuple         // optional
+	member   MemberAccess  // optional - one of five must be present e.g. t.2 where t is (int, (int, int))
+	value    Transpileable // optional - any other value giving a tuple e.g. g()?
 	exprType TupleExpressionType
 }
 
@@ -74,11 +81,18 @@ func parseTuplePattern(pattern string, lineNum int) TuplePattern {
 	}
 
 	dataTypes := []primitiveType{}
-	typeStrings := strings.Split(p[1:len(p)-1], ", ")
+	typeStrings := splitTopLevel(p[1:len(p)-1], ',')
+	// elements can themselves be tuples or arrays
 	for _, s := range typeStrings {
 		T := readType(s, lineNum)
+		if T == IO {
+			panic(fmt.Sprintf("Line %d: tuples cannot contain elements of type IO", lineNum+1))
+		}
 		dataTypes = append(dataTypes, T)
 	}
+	if len(dataTypes) == 0 {
+		panic(fmt.Sprintf("Line %d: tuple pattern has no elements", lineNum+1))
+	}
 
 	tupleImports = append(tupleImports, len(dataTypes))
 	// later collected into hashset
@@ -88,13 +102,39 @@ func parseTuplePattern(pattern string, lineNum int) TuplePattern {
 	}
 }
 
-func matchTuplePattern(tuple TupleLiteral, pattern TuplePattern, lineNum int) struct{} {
-	if len(tuple.values) != len(pattern.dataTypes) {
+func tupleType(pattern TuplePattern) primitiveType {
+	// returns the id of the tuple type so it can be used like any other type
+	// the same pattern always gives the same id
+	identifier := "("
+	for i, T := range pattern.dataTypes {
+		identifier += T.String()
+		if i != len(pattern.dataTypes)-1 {
+			identifier += ", "
+		}
+	}
+	identifier += ")"
+
+	for i, d := range definedTypes {
+		// compared by elements rather than identifier as type parameters in different functions can share names
+		if d.kind == TupleKind && slices.Equal(d.elements, pattern.dataTypes) {
+			return firstDefinedType + primitiveType(i)
+		}
+	}
+	tupleImports = append(tupleImports, len(pattern.dataTypes))
+	return defineType(definedType{
+		identifier: identifier,
+		kind:       TupleKind,
+		elements:   pattern.dataTypes,
+	}, 0)
+}
+
+func matchTuplePattern(found TuplePattern, pattern TuplePattern, lineNum int) struct{} {
+	if len(found.dataTypes) != len(pattern.dataTypes) {
 		panic(fmt.Sprintf("Line %d: tuple does not match expected tuple pattern because they do not have the same length", lineNum+1))
 	}
 
-	for i := 0; i < len(tuple.values); i++ {
-		if tuple.values[i].dataType != pattern.dataTypes[i] {
+	for i := 0; i < len(found.dataTypes); i++ {
+		if found.dataTypes[i] != pattern.dataTypes[i] {
 			panic(fmt.Sprintf("Line %d: tuple does not match expected pattern because element %d has the wrong data type", lineNum+1, i+1))
 		}
 	}
@@ -108,45 +148,21 @@ func parseTupleLiteral(tupleValue string, pattern TuplePattern, lineNum int, cur
 		panic(fmt.Sprintf("Line %d: tuple is invalid because it is not enclosed by brackets ()", lineNum+1))
 	}
 
-	elements := trimmed[1 : len(trimmed)-1]
-
-	var elementStrings []string
-	var stringLiteral bool
-	var currentString string
-
-	for i := 0; i < len(elements); i++ {
-		if elements[i] == '"' {
-			stringLiteral = !stringLiteral
-		}
-		switch elements[i] {
-		case ',':
-			if !stringLiteral {
-				elementStrings = append(elementStrings, currentString)
-				currentString = ""
-			}
-		default:
-			currentString += string(elements[i])
-		}
-		if i == len(elements)-1 && len(currentString) != 0 {
-			elementStrings = append(elementStrings, currentString)
-		}
+	elementStrings := splitTopLevel(trimmed[1:len(trimmed)-1], ',')
+	if len(elementStrings) != len(pattern.dataTypes) {
+		panic(fmt.Sprintf("Line %d: tuple does not match expected tuple pattern because they do not have the same length", lineNum+1))
 	}
 
-	var expressions []Expression
-
-	for _, s := range elementStrings {
-		expr := parseExpression(s, lineNum, currentScope)
-		expressions = append(expressions, expr)
-	}
+	var values []Transpileable
 
-	tupleFound := TupleLiteral{
-		values: expressions,
+	for i, s := range elementStrings {
+		// each element is parsed according to the expected type so that nested literals can be parsed
+		values = append(values, parseTypedValue(s, pattern.dataTypes[i], lineNum, currentScope))
 	}
 
-	_ = matchTuplePattern(tupleFound, pattern, lineNum)
-
 	return TupleLiteral{
-		values: expressions,
+		values:  values,
+		pattern: pattern,
 	}
 }
 
@@ -163,6 +179,19 @@ func parseTupleExpression(expr string, pattern TuplePattern, lineNum int, curren
 			literal:  literal,
 		}
 	}
+	if isPropagation(trimmed) {
+		// Option or Result carrying a tuple
+		propagation := parsePropagation(trimmed, lineNum, currentScope)
+		found, ok := propagation.dataType.defined()
+		if !ok || !isTuple(propagation.dataType) {
+			panic(fmt.Sprintf("Line %d: expected tuple but found value of type %v", lineNum+1, propagation.dataType))
+		}
+		_ = matchTuplePattern(TuplePattern{dataTypes: found.elements}, pattern, lineNum)
+		return TupleExpression{
+			value:    propagation,
+			exprType: TupleValue,
+		}
+	}
 	var currentString string
 Loop:
 	for i := 0; i < len(expr); i++ {
@@ -175,15 +204,18 @@ Loop:
 		}
 	}
 	currentString = strings.Trim(currentString, " ") // remove leading spaces
-	if fn, ok := (*currentScope).functions[currentString]; ok {
-		// functions returning derived type
-		if fn.returnDomain == tuple {
-			call := parseFunctionCall(strings.Trim(expr, " "), lineNum, currentScope)
-			// check that function call is actually valid
-			return TupleExpression{
-				fnCall:   call,
-				exprType: FnCall,
-			}
+	if _, ok := lookupFunction(currentString, currentScope); ok && trimmed[len(trimmed)-1] == ')' {
+		// functions returning tuples, including generic functions whose return type is only known from the arguments
+		// and variables holding functions
+		call := parseFunctionCall(trimmed, lineNum, currentScope)
+		found, ok := call.dataType.defined()
+		if !ok || !isTuple(call.dataType) {
+			panic(fmt.Sprintf("Line %d: expected tuple but function %s returns type %v", lineNum+1, call.functionName, call.dataType))
+		}
+		_ = matchTuplePattern(TuplePattern{dataTypes: found.elements}, pattern, lineNum)
+		return TupleExpression{
+			fnCall:   call,
+			exprType: FnCall,
 		}
 	}
 
@@ -191,11 +223,26 @@ Loop:
 		panic(fmt.Sprintf("Line %d: invalid tuple expression", lineNum+1))
 	}
 
+	if isMemberAccess(trimmed, currentScope) || strings.Contains(trimmed, "[") {
+		// tuple nested inside another tuple, a record or an array
+		member := parseMemberAccess(trimmed, lineNum, currentScope)
+		if !isTuple(member.dataType) {
+			panic(fmt.Sprintf("Line %d: expected tuple but found value of type %v", lineNum+1, member.dataType))
+		}
+		found, _ := member.dataType.defined()
+		_ = matchTuplePattern(TuplePattern{dataTypes: found.elements}, pattern, lineNum)
+		return TupleExpression{
+			member:   member,
+			exprType: TupleMember,
+		}
+	}
+
 	id := strings.Fields(trimmed)[0]
 	t, ok := (*currentScope).tuples[id]
 	if !ok {
 		panic(fmt.Sprintf("Line %d: tuple %s not found in scope", lineNum+1, id))
 	}
+	_ = matchTuplePattern(t.pattern, pattern, lineNum)
 
 	return TupleExpression{
 		t:        t,
@@ -252,7 +299,7 @@ func parseTupleDeclaration(line string, lineNum int, currentScope *Scope) TupleD
 		}
 	}
 
-	expectedPattern := parseTuplePattern(line[colonIndex+1:equalsIndex], lineNum)
+	expectedPattern := parseTuplePattern(expandAlias(line[colonIndex+1:equalsIndex]), lineNum)
 
 	expression := line[equalsIndex+1:]
 	exprFound := parseTupleExpression(expression, expectedPattern, lineNum, currentScope)
@@ -305,6 +352,9 @@ func parseTupleIndexing(indexing string, lineNum int, currentScope *Scope) Tuple
 }
 
 func parseTupleAssignment(line string, lineNum int, currentScope *Scope) TupleAssignment {
+	if id, operator, _ := splitAssignment(line, lineNum); operator != "=" {
+		panic(fmt.Sprintf("Line %d: %s cannot be used with tuple %s", lineNum+1, operator, id))
+	}
 	var equalsIndex int
 	for i := 0; i < len(line); i++ {
 		if line[i] == '=' {
@@ -347,6 +397,7 @@ func parseMultiLineTupleExpression(lines []string, lineNum int, pattern TuplePat
 	// so that when variable declarations are actually parsed they don't throw an already declared error
 	arraysCopy := make(map[string]Array)
 	tuplesCopy := make(map[string]Tuple)
+	functionsCopy := make(map[string]Function) // local functions
 
 	for k, v := range (*currentScope).vars {
 		varsCopy[k] = v
@@ -357,6 +408,9 @@ func parseMultiLineTupleExpression(lines []string, lineNum int, pattern TuplePat
 	for k, v := range (*currentScope).tuples {
 		tuplesCopy[k] = v
 	}
+	for k, v := range (*currentScope).functions {
+		functionsCopy[k] = v
+	}
 
 	// manual copy as maps are reference types
 
@@ -368,6 +422,7 @@ func parseMultiLineTupleExpression(lines []string, lineNum int, pattern TuplePat
 
 	for n := lineNum; n < len(lines); n++ {
 		line := lines[n]
+		depth := bracketCount // before the line
 		for i := 0; i < len(line); i++ {
 			if line[i] == '{' {
 				bracketCount++
@@ -378,7 +433,7 @@ func parseMultiLineTupleExpression(lines []string, lineNum int, pattern TuplePat
 		if bracketCount == 0 {
 			break
 		}
-		if bracketCount != 1 {
+		if depth != 1 {
 			// ignore lines which are not in main scope
 			continue
 		}
@@ -388,6 +443,12 @@ func parseMultiLineTupleExpression(lines []string, lineNum int, pattern TuplePat
 			_ = parseArrayDeclaration(lines[n], n, currentScope)
 		} else if getItemType(lines[n], n, currentScope) == TupDeclaration {
 			_ = parseTupleDeclaration(lines[n], n, currentScope)
+		} else if getItemType(lines[n], n, currentScope) == DestructuringDeclaration {
+			_ = parseDestructuringDeclaration(lines[n], n, currentScope)
+		} else if getItemType(lines[n], n, currentScope) == ValueBlockItem && strings.Fields(lines[n])[0] == "let" {
+			declareValue(parseValueDeclaration(lines[n], n, currentScope), currentScope)
+		} else if getItemType(lines[n], n, currentScope) == FunctionDeclaration {
+			declareLocalFunction(lines, n, currentScope)
 		}
 		if exprCount >= 1 {
 			if len(strings.Trim(line, " ")) > 0 {
@@ -411,10 +472,20 @@ func parseMultiLineTupleExpression(lines []string, lineNum int, pattern TuplePat
 	(*currentScope).vars = varsCopy
 	(*currentScope).arrays = arraysCopy
 	(*currentScope).tuples = tuplesCopy
+	(*currentScope).functions = functionsCopy
 	// return maps to original
 	return to_return
 }
 
+func isTupleLiteral(value string) bool {
+	// (1, 2) rather than a bracketed expression such as (1 + 2)
+	trimmed := strings.Trim(value, " ")
+	if len(trimmed) < 2 || trimmed[0] != '(' || closingBracket(trimmed) != len(trimmed)-1 {
+		return false
+	}
+	return len(splitTopLevel(trimmed[1:len(trimmed)-1], ',')) > 1
+}
+
 func isTupleIndexing(item string) bool { // helper function for Expression.transpile()
 	if parseCharType(item[0]) == letter {
 		var stringLiteral, byteLiteral bool
@@ -463,45 +534,13 @@ func transpileTupleIndexing(item string) string {
 func findExpectedPattern(lines []string, lineNum int) TuplePattern {
 	// needs to loop backwards through lines to find function declartion with return type typeAnnotation
 	// doesn't really need error checking as function declaration will already have been parsed
-	var tuplePatternString string
-	var opened bool
 	for i := lineNum; i >= 0; i-- {
 		words := strings.Fields(lines[i])
-		if len(words) == 0 {
-			continue
-		}
-		if words[0] == "function" {
-			line := lines[i]
-			var bracketCount int
-		Loop:
-			for i := len(line) - 1; i >= 0; i-- {
-				if bracketCount == 0 && opened {
-					// i.e. it has been opened and then closed
-					break
-				}
-				switch line[i] {
-				case ')':
-					bracketCount--
-					tuplePatternString = string(line[i]) + tuplePatternString
-				case '(':
-					bracketCount++
-					tuplePatternString = string(line[i]) + tuplePatternString
-					if bracketCount == 0 {
-						break Loop
-					}
-				default:
-					if opened {
-						tuplePatternString = string(line[i]) + tuplePatternString
-					}
-				}
-				if bracketCount != 0 {
-					opened = true
-				}
-
-			}
+		if len(words) != 0 && words[0] == "function" && findScopeEnd(lines, i) >= lineNum {
+			// skips local functions which end before the line
+			// the return type could be an alias of a tuple type
+			return parseTuplePattern(returnTypeAnnotation(lines[i]), lineNum)
 		}
 	}
-
-	return parseTuplePattern(tuplePatternString, lineNum)
-	// ^ shouldn't be possible for this to panic
+	panic(fmt.Sprintf("Line %d: tuple returned outside of a function", lineNum+1))
 }
@@ -0,0 +1,103 @@
+package transpiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// type alias and newtype implementation in Go
+/**
+type Meters = float
+newtype Kelvin = float
+
+type Meters = float64
+type Kelvin float64
+
+// an alias is just another name for the same type, so Meters and float
+// can be used together. a newtype is a different type, so values have to
+// be converted with Kelvin(273.15) and float(k)
+*/
+
+type TypeDefinition struct {
+	identifier string
+	dataType   primitiveType // type being aliased, or the newtype itself
+	alias      bool
+}
+
+// aliased types are stored as they were written, so that arrays and tuples
+// which are recognised by their brackets can also be given aliases
+var typeAliases map[string]string
+
+func isTypeAlias(line string) bool {
+	// type Name = { ... } declares a record instead
+	equals := strings.Index(line, "=")
+	return equals != -1 && !strings.HasPrefix(strings.Trim(line[equals+1:], " "), "{")
+}
+
+func parseTypeDefinition(line string, lineNum int) TypeDefinition {
+	words := strings.Fields(line)
+	if words[0] != "type" && words[0] != "newtype" {
+		panic("parseTypeDefinition() called without type or newtype keyword")
+	}
+	if len(words) < 4 || words[2] != "=" {
+		panic(fmt.Sprintf("Line %d: expected type definition of the form %s Name = type", lineNum+1, words[0]))
+	}
+
+	identifier := parseIdentifier(words[1]+":", lineNum)
+	if _, ok := lookupType(identifier); ok {
+		panic(fmt.Sprintf("Line %d: type %s is already defined", lineNum+1, identifier))
+	}
+
+	aliased := expandAlias(line[strings.Index(line, "=")+1:])
+	T := readType(aliased, lineNum)
+	if T == IO {
+		panic(fmt.Sprintf("Line %d: cannot define type %s as IO", lineNum+1, identifier))
+	}
+
+	if words[0] == "type" {
+		if typeAliases == nil {
+			typeAliases = make(map[string]string)
+		}
+		typeAliases[identifier] = aliased
+		return TypeDefinition{
+			identifier: identifier,
+			dataType:   T,
+			alias:      true,
+		}
+	}
+
+	if isNewtype(T) || (!numericType(T) && T != Bool && T != Byte && T != String) {
+		panic(fmt.Sprintf("Line %d: newtype %s must be defined as a number, bool, byte or string but found type %v", lineNum+1, identifier, T))
+	}
+
+	return TypeDefinition{
+		identifier: identifier,
+		dataType: defineType(definedType{
+			identifier: identifier,
+			kind:       NewtypeKind,
+			underlying: T,
+		}, lineNum),
+	}
+}
+
+func expandAlias(dataType string) string {
+	// replaces an alias with the type it stands for
+	trimmed := strings.Trim(dataType, " ")
+	if aliased, ok := typeAliases[trimmed]; ok {
+		return aliased
+	}
+	return trimmed
+}
+
+func isNewtype(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == NewtypeKind
+}
+
+func underlyingType(T primitiveType) primitiveType {
+	// the type a newtype is defined as, which decides which operators can be used on it
+	if d, ok := T.defined(); ok && d.kind == NewtypeKind {
+		return d.underlying
+	}
+	return T
+}
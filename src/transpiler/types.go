@@ -2,11 +2,14 @@ package transpiler
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 )
 
 type (
 	primitiveType int
 	derivedTypes  int
+	typeKind      int
 )
 
 type HasType interface {
@@ -19,9 +22,47 @@ const (
 	Bool
 	Byte
 	String
+	I32
+	I64
+	U8
+	U64
+	F32
+	Complex
 	IO // used as function return type for main()
+
+	firstDefinedType // types declared in the source file get ids from here onwards
+)
+
+const (
+	RecordKind typeKind = iota
+	EnumKind
+	TupleKind
+	ArrayKind
+	TypeParameterKind
+	FunctionKind
+	NewtypeKind
 )
 
+// types declared by the user (e.g. records) are registered here when
+// they are parsed, and given a primitiveType id so that they can be
+// used anywhere a primitive type can
+type definedType struct {
+	identifier string
+	kind       typeKind
+	fields     []Variable      // only used by records
+	variants   []Variant       // only used by enums
+	elements   []primitiveType // only used by tuples
+	array      ArrayType       // only used by arrays
+	constraint string          // only used by type parameters
+	generic    string          // only used by Option and Result, name of the generic Go type
+	parameters []primitiveType // only used by function types
+	returns    primitiveType   // only used by function types
+	variadic   bool            // only used by the function types of variadic local functions
+	underlying primitiveType   // only used by newtypes
+}
+
+var definedTypes []definedType
+
 const (
 	arrInt derivedTypes = iota
 	arrFloat
@@ -48,21 +89,158 @@ func (p primitiveType) String() string {
 		return "byte"
 	case String:
 		return "string"
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case U8:
+		return "u8"
+	case U64:
+		return "u64"
+	case F32:
+		return "f32"
+	case Complex:
+		return "complex"
 	case IO:
 		return "IO"
 	default:
+		if T, ok := p.defined(); ok {
+			return T.identifier
+		}
 		panic("Somehow a type not in the enum got passed into primitiveType.String()")
 	}
 }
 
+func (p primitiveType) goType() string {
+	// name of the type in the transpiled Go code
+	switch p {
+	case Float:
+		return "float64"
+	case I32:
+		return "int32"
+	case I64:
+		return "int64"
+	case U8:
+		return "uint8"
+	case U64:
+		return "uint64"
+	case F32:
+		return "float32"
+	case Complex:
+		return "complex128"
+	default:
+		T, ok := p.defined()
+		if ok && T.kind == TupleKind {
+			transpiled := fmt.Sprintf("tuple%d[", len(T.elements))
+			for i, e := range T.elements {
+				transpiled += e.goType()
+				if i != len(T.elements)-1 {
+					transpiled += ", "
+				}
+			}
+			return transpiled + "]"
+		}
+		if ok && T.kind == ArrayKind {
+			return T.array.goType()
+		}
+		if ok && T.kind == FunctionKind {
+			var params []string
+			for i, param := range T.parameters {
+				if T.variadic && i == len(T.parameters)-1 {
+					rest, _ := param.defined()
+					params = append(params, "..."+rest.array.baseType.goType())
+					continue
+				}
+				params = append(params, param.goType())
+			}
+			return "func(" + strings.Join(params, ", ") + ") " + T.returns.goType()
+		}
+		if ok && len(T.generic) != 0 {
+			var args []string
+			for _, arg := range typeArguments(T) {
+				args = append(args, arg.goType())
+			}
+			return T.generic + "[" + strings.Join(args, ", ") + "]"
+		}
+		return p.String()
+	}
+}
+
+func (p primitiveType) defined() (definedType, bool) {
+	if p < firstDefinedType || int(p-firstDefinedType) >= len(definedTypes) {
+		return definedType{}, false
+	}
+	return definedTypes[p-firstDefinedType], true
+}
+
+func defineType(T definedType, lineNum int) primitiveType {
+	if _, ok := lookupType(T.identifier); ok {
+		panic(fmt.Sprintf("Line %d: type %s is already defined", lineNum+1, T.identifier))
+	}
+	definedTypes = append(definedTypes, T)
+	return firstDefinedType + primitiveType(len(definedTypes)-1)
+}
+
+func lookupType(identifier string) (primitiveType, bool) {
+	if T, ok := typeParameters[identifier]; ok {
+		// only in scope inside the generic function
+		return T, true
+	}
+	if aliased, ok := typeAliases[identifier]; ok {
+		return readType(aliased, 0), true // already checked when the alias was defined
+	}
+	for i, T := range definedTypes {
+		if T.identifier == identifier && T.kind != TypeParameterKind {
+			return firstDefinedType + primitiveType(i), true
+		}
+	}
+	return 0, false
+}
+
+func isRecord(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == RecordKind
+}
+
+func isEnum(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == EnumKind
+}
+
+func isTuple(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == TupleKind
+}
+
+func isArray(T primitiveType) bool {
+	d, ok := T.defined()
+	return ok && d.kind == ArrayKind
+}
+
 func numericType(T primitiveType) bool {
-	if T == Int || T == Float {
+	switch underlyingType(T) {
+	case Int, Float, I32, I64, U8, U64, F32:
 		return true
 	}
+	if d, ok := T.defined(); ok && d.kind == TypeParameterKind {
+		return d.constraint == "numeric"
+	}
 	return false
 }
 
 func readType(dataType string, lineNum int) primitiveType { // reads data type from assignment
+	dataType = strings.Trim(dataType, " ")
+	if isFunctionType(dataType) {
+		// checked first as the parameters are in brackets and the return type could be an array
+		return readFunctionType(dataType, lineNum)
+	} else if strings.HasSuffix(dataType, "]") {
+		// compound types are given ids so that they can be nested e.g. (string, float[3], (int, int))
+		return arrayTypeID(parseArrayType(dataType, lineNum))
+	} else if strings.HasPrefix(dataType, "(") {
+		return tupleType(parseTuplePattern(dataType, lineNum))
+	} else if T, ok := readBuiltinType(dataType, lineNum); ok {
+		return T
+	}
 	switch dataType {
 	case "int":
 		return Int
@@ -74,13 +252,57 @@ func readType(dataType string, lineNum int) primitiveType { // reads data type f
 		return Byte
 	case "string":
 		return String
+	case "i32":
+		return I32
+	case "i64":
+		return I64
+	case "u8":
+		return U8
+	case "u64":
+		return U64
+	case "f32":
+		return F32
+	case "f64":
+		return Float // the same type as float
+	case "complex":
+		return Complex
 	case "IO":
 		return IO
 	default:
+		if T, ok := lookupType(dataType); ok {
+			return T
+		}
 		panic(fmt.Sprintf("Line %d: data type %s is invalid", lineNum+1, dataType))
 	}
 }
 
+func typeAnnotation(line string) string {
+	// returns everything between the colon and the equals sign of a declaration
+	// as compound types such as (float, float)[100] can contain spaces
+	colon := strings.Index(line, ":")
+	equals := strings.Index(line, "=")
+	if colon == -1 || equals < colon {
+		return ""
+	}
+	return expandAlias(line[colon+1 : equals])
+}
+
+func returnTypeAnnotation(line string) string {
+	// same as above but for the return type of a function declaration
+	open := strings.Index(line, "(")
+	if open == -1 {
+		return ""
+	}
+	// searched for after the parameters as they can have function types
+	paramsEnd := open + closingBracket(line[open:])
+	arrow := strings.Index(line[paramsEnd:], "->")
+	equals := strings.LastIndex(line, "=")
+	if arrow == -1 || equals < paramsEnd+arrow {
+		return ""
+	}
+	return expandAlias(line[paramsEnd+arrow+2 : equals])
+}
+
 func getValType(value string, lineNum int) primitiveType {
 	if value[0] == '"' && value[len(value)-1] == '"' {
 		checkStringVal(value, lineNum)
@@ -92,6 +314,10 @@ func getValType(value string, lineNum int) primitiveType {
 	} else if value == "true" || value == "false" {
 		checkBoolVal(value, lineNum)
 		return Bool
+	} else if number, T, ok := literalSuffix(value); ok {
+		// literal with a type suffix e.g. 5i32
+		checkSuffixedVal(number, T, lineNum)
+		return T
 	} // else must be a number
 
 	foundNum := false
@@ -107,7 +333,7 @@ func getValType(value string, lineNum int) primitiveType {
 	}
 
 	for _, char := range value {
-		if char == '.' {
+		if char == '.' || char == 'e' || char == 'E' {
 			checkFloatVal(value, lineNum)
 			return Float
 		}
@@ -117,83 +343,9 @@ func getValType(value string, lineNum int) primitiveType {
 	return Int
 }
 
-func nextTerm(expression []string, index int, lineNum int) []string { // helper functions for expressionType()
-	if index == len(expression)-1 {
-		panic(fmt.Sprintf("Line %d: expected another token in expression", lineNum+1))
-	}
-	bracketCount := 0
-	if expression[index+1] != "(" {
-		// brackets open a new term of more than one token
-		if _, ok := unaryOperators()[expression[index+1]]; ok {
-			return nextTerm(expression, index+1, lineNum)
-		}
-		return []string{expression[index+1]}
-		// next token after bracket
-	}
-	for i := index + 1; i < len(expression); i++ {
-		switch expression[i] {
-		case "(":
-			bracketCount++
-		case ")":
-			bracketCount--
-		}
-		if bracketCount == 0 {
-			if i == len(expression)-1 {
-				return expression[index+1:]
-			}
-			return expression[index+1 : i+1]
-		}
-	}
-	panic(fmt.Sprintf("Line %d: brackets opened in expression but never closed", lineNum+1))
-}
-
-func previousTerm(expression []string, index int, lineNum int) []string { // similar helper function
-	if index == 0 {
-		panic("previousTerm() was called with no previous term somehow")
-	}
-	bracketCount := 0
-	if expression[index-1] != ")" {
-		return []string{expression[index-1]}
-	}
-	for i := index - 1; i >= 0; i-- {
-		switch expression[i] {
-		case "(":
-			bracketCount++
-		case ")":
-			bracketCount--
-		}
-		if bracketCount == 0 {
-			return expression[i:index]
-		}
-	}
-	panic(fmt.Sprintf("Line %d: brackets closed in expression but never opened", lineNum+1))
-}
-
-func nextOperator(expression []string, index int) (int, error) {
-	// returns index of next operator or error if there is none
-	bracketCount := 0
-	for i := index + 1; i < len(expression); i++ {
-		switch expression[i] {
-		case "(":
-			bracketCount++
-		case ")":
-			bracketCount--
-		}
-
-		if bracketCount == 0 {
-			_, ok1 := binaryOperators()[expression[i]]
-			_, ok2 := unaryOperators()[expression[i]]
-			if ok1 || ok2 {
-				return i, nil
-			}
-		}
-	}
-	return index, fmt.Errorf("found no next operator in expression")
-}
-
 func expressionType(expression []string, lineNum int, currentScope *Scope) primitiveType {
-	// NOTE: does not currently support collections
-	// collections have a separate function
+	// NOTE: collections are only supported as values that are compared
+	// other collection expressions have a separate function
 	// also does not support multi-line expressions
 	// which have another separate function
 
@@ -237,11 +389,39 @@ func expressionType(expression []string, lineNum int, currentScope *Scope) primi
 			panic(fmt.Sprintf("Line %d: Expression contains only operators and no values", lineNum+1))
 		}
 
+		if isIfExpression(expr[0]) {
+			return parseIfExpression(expr[0], lineNum, currentScope).dataType
+		}
+
+		if isRecordLiteral(expr[0]) {
+			return parseRecordLiteral(expr[0], lineNum, currentScope).dataType
+		}
+
+		if isPropagation(expr[0]) {
+			// checked first as the value before ? could be any other kind of token
+			return parsePropagation(expr[0], lineNum, currentScope).dataType
+		}
+
+		if isEnumValue(expr[0]) {
+			return parseEnumValue(expr[0], lineNum, currentScope).dataType
+		}
+
+		if isMemberAccess(expr[0], currentScope) { // tuple indexing, record field access or element of array
+			return parseMemberAccess(expr[0], lineNum, currentScope).dataType
+		}
+
+		if isConversion(expr[0]) {
+			return parseConversion(expr[0], lineNum, currentScope).dataType
+		}
+
+		if isComplexFunction(expr[0]) {
+			return parseComplexFunction(expr[0], lineNum, currentScope).dataType
+		}
+
 		for i := 0; i < len(expr[0]); i++ {
 			if expr[0][i] == '(' {
 				fnCall := parseFunctionCall(expr[0], lineNum, currentScope)
-				fn := currentScope.functions[fnCall.functionName]
-				return fn.returnType
+				return fnCall.dataType // return type can depend on type parameters
 			}
 		}
 
@@ -249,8 +429,7 @@ func expressionType(expression []string, lineNum int, currentScope *Scope) primi
 			// array indexing inside function call would have been caught above
 			if expr[0][i] == '[' {
 				arrIndex := parseArrayIndexing(expr[0], lineNum, currentScope)
-				// might need generics for multi-dimensional arrays
-				return arrIndex.dataType.baseType
+				return elementType(arrIndex.dataType)
 			}
 		}
 
@@ -258,20 +437,17 @@ func expressionType(expression []string, lineNum int, currentScope *Scope) primi
 			return v.dataType
 		}
 
-		if expr[0][0] != '"' { // check for tuple indexing
-			fullStopIndex := -1
-			for i := 0; i < len(expr[0]); i++ {
-				if expr[0][i] == '.' {
-					fullStopIndex = i
-				}
-			}
+		if arr, ok := (*currentScope).arrays[expr[0]]; ok {
+			return arrayTypeID(arr.dataType)
+		}
 
-			if fullStopIndex != -1 {
-				if _, ok := (*currentScope).tuples[expr[0][:fullStopIndex]]; ok {
-					idx := parseTupleIndexing(expr[0], lineNum, currentScope)
-					return idx.t.pattern.dataTypes[idx.i]
-				}
-			}
+		if t, ok := (*currentScope).tuples[expr[0]]; ok {
+			return tupleType(t.pattern)
+		}
+
+		if fn, ok := (*currentScope).functions[expr[0]]; ok {
+			// named function used as a value
+			return signature(fn, lineNum)
 		}
 
 		return getValType(expr[0], lineNum) // not operator, variable or function
@@ -280,135 +456,288 @@ func expressionType(expression []string, lineNum int, currentScope *Scope) primi
 		switch expr[0] {
 		case "-":
 			x := parseExpression(expr[1], lineNum, currentScope)
-			if x.dataType != Int && x.dataType != Float {
+			if !arithmeticType(x.dataType) {
 				panic(fmt.Sprintf("Line %d: use of unary operator - with non-numeric data type %v", lineNum+1, x.dataType))
 			}
+			if unsignedType(x.dataType) {
+				panic(fmt.Sprintf("Line %d: cannot use unary operator - with unsigned data type %v", lineNum+1, x.dataType))
+			}
 			return x.dataType
 		case "!":
 			x := parseExpression(expr[1], lineNum, currentScope)
-			if x.dataType != Bool {
+			if underlyingType(x.dataType) != Bool {
 				panic(fmt.Sprintf("Line %d: use of unary operator - with non-boolean data type %v", lineNum+1, x.dataType))
 			}
-			return Bool
+			return x.dataType
 		default:
 			panic(fmt.Sprintf("Line %d: expressions of length 2 tokens must begin with unary operators - or !", lineNum+1))
 		}
 	}
-	typesFound := make(map[primitiveType]struct{}) // Hashset of all types found in expression
-	previousOperatorIndex := -1                    // index in expression slice where last term ended.
-	// initialise as -1 for first function call
+	checker := typeChecker{
+		tokens:       expr,
+		lineNum:      lineNum,
+		currentScope: currentScope,
+	}
+	T := checker.or()
+	if checker.pos != len(expr) {
+		panic(fmt.Sprintf("Line %d: unexpected token %s in expression", lineNum+1, expr[checker.pos]))
+	}
+	return T
+}
 
-	// match input types of all operators, terms:
-	for {
-		operatorIndex, err := nextOperator(expr, previousOperatorIndex)
-		if err != nil { // no next operator in expression
-			break
+func isComparedCollection(token string, currentScope *Scope) bool {
+	// tuple and array literals and slices, which can be compared with == and !=
+	return len(token) != 0 && (token[0] == '[' || isTupleLiteral(token) || isArraySlice(token, currentScope))
+}
+
+func comparedCollections(expression []string, lineNum int, currentScope *Scope) []Transpileable {
+	// parses the collections compared in an expression, in the order they appear
+	if !slices.ContainsFunc(expression, func(token string) bool { return isComparedCollection(token, currentScope) }) {
+		return nil
+	}
+	checker := typeChecker{
+		tokens:       expression,
+		lineNum:      lineNum,
+		currentScope: currentScope,
+	}
+	_ = checker.or()
+
+	var collections []Transpileable
+	for i := range expression {
+		if value, ok := checker.collections[i]; ok {
+			collections = append(collections, value)
 		}
-		previousOperatorIndex = operatorIndex
-		operator := expr[operatorIndex]
-		switch operator {
-		case "-":
-			if operatorIndex == 0 {
-				next := nextTerm(expr, operatorIndex, lineNum)
-				if !numericType(expressionType(next, lineNum, currentScope)) {
-					panic(fmt.Sprintf("Line %d: Unary operator '-' found before non numeric type", lineNum+1))
-				}
-				// typesFound[expressionType(next, lineNum, currentScope)] = struct{}{}
-			} else {
-				_, prevBinary := binaryOperators()[expr[operatorIndex-1]]
-				if prevBinary { // after either numeric operator or comparative operator
-					next := nextTerm(expr, operatorIndex, lineNum)
-					if !numericType(expressionType(next, lineNum, currentScope)) {
-						panic(fmt.Sprintf("Line %d: Unary operator '-' found before non numeric type", lineNum+1))
-					}
-					// do not add to typesFound if used as unary operator
-
-				} else { // used as binary operator
-					previous := previousTerm(expr, operatorIndex, lineNum)
-					next := nextTerm(expr, operatorIndex, lineNum)
-					if !numericType(expressionType(previous, lineNum, currentScope)) || !numericType(expressionType(next, lineNum, currentScope)) {
-						panic(fmt.Sprintf("Line %d: binary opertor '-' used with non-numeric values", lineNum+1))
-					}
-					typesFound[expressionType(next, lineNum, currentScope)] = struct{}{}
-				}
-			}
-		case "!":
-			next := nextTerm(expr, operatorIndex, lineNum)
-			if expressionType(next, lineNum, currentScope) != Bool {
-				panic(fmt.Sprintf("Line %d: Unary operator '!' used before non-boolean value", lineNum+1))
-			}
-			// typesFound[Bool] = struct{}{}
-		case "+":
-			// handle seperately as it can be used with strings as well
-			previous := previousTerm(expr, operatorIndex, lineNum)
-			next := nextTerm(expr, operatorIndex, lineNum)
-			previousType := expressionType(previous, lineNum, currentScope)
-			nextType := expressionType(next, lineNum, currentScope)
-			if previousType == String && nextType == String {
-				typesFound[String] = struct{}{}
-			} else {
-				if !numericType(previousType) {
-					panic(fmt.Sprintf("Line %d: binary operator '%s' used after non-numeric type %v", lineNum+1, expr[operatorIndex], previousType))
-				}
-				if !numericType(nextType) {
-					panic(fmt.Sprintf("Line %d: binary operator '%s' used before non-numeric type %v", lineNum+1, expr[operatorIndex], nextType))
-				}
-				if previousType != nextType {
-					panic(fmt.Sprintf("Lind %d: binary operator '%s' used with both integer and float values", lineNum+1, expr[operatorIndex]))
-				}
-				typesFound[nextType] = struct{}{}
-			}
-		case "*", "/":
-			previous := previousTerm(expr, operatorIndex, lineNum)
-			next := nextTerm(expr, operatorIndex, lineNum)
-			previousType := expressionType(previous, lineNum, currentScope)
-			nextType := expressionType(next, lineNum, currentScope)
-			if !numericType(previousType) {
-				panic(fmt.Sprintf("Line %d: binary operator '%s' used after non-numeric type %v", lineNum+1, expr[operatorIndex], previousType))
-			}
-			if !numericType(nextType) {
-				panic(fmt.Sprintf("Line %d: binary operator '%s' used before non-numeric type %v", lineNum+1, expr[operatorIndex], nextType))
-			}
-			if previousType != nextType {
-				panic(fmt.Sprintf("Lind %d: binary operator '%s' used with both integer and float values", lineNum+1, expr[operatorIndex]))
-			}
-			typesFound[nextType] = struct{}{}
-		case "||", "&&":
-			previous := previousTerm(expr, operatorIndex, lineNum)
-			next := nextTerm(expr, operatorIndex, lineNum)
-			previousType := expressionType(previous, lineNum, currentScope)
-			nextType := expressionType(next, lineNum, currentScope)
-			if previousType != Bool {
-				panic(fmt.Sprintf("Line %d: binary operator '%s' used after non-boolean type %v", lineNum+1, expr[operatorIndex], previousType))
-			}
-			if nextType != Bool {
-				panic(fmt.Sprintf("Line %d: binary operator '%s' used before non-boolean type %v", lineNum+1, expr[operatorIndex], nextType))
+	}
+	return collections
+}
+
+// checks the types of the terms of an expression in the same order of precedence as Go
+// as the operators are transpiled directly
+type typeChecker struct {
+	tokens       []string
+	pos          int
+	lineNum      int
+	currentScope *Scope
+	operations   [][3]int              // start, operator and end of operations which don't exist in Go e.g. x ** 2
+	collections  map[int]Transpileable // tuple and array literals and slices which are compared
+}
+
+func (c *typeChecker) peek() string {
+	if c.pos == len(c.tokens) {
+		return ""
+	}
+	return c.tokens[c.pos]
+}
+
+func (c *typeChecker) or() primitiveType {
+	return c.binary([]string{"||"}, c.and)
+}
+
+func (c *typeChecker) and() primitiveType {
+	return c.binary([]string{"&&"}, c.comparison)
+}
+
+func (c *typeChecker) comparison() primitiveType {
+	// tuple and array literals and slices are parsed using the type of the other side
+	// as their own types can't always be inferred e.g. t == (1, 2)
+	left, T := c.operand()
+	for slices.Contains([]string{"==", "!=", ">", "<", ">=", "<="}, c.peek()) {
+		operator := c.peek()
+		c.pos++
+		right, U := c.operand()
+		if left != -1 && right != -1 && isArraySlice(c.tokens[left], c.currentScope) {
+			// the length of a literal is always known, so a slice is converted to its type
+			U = c.collection(right, U)
+			right = -1
+		}
+		if left != -1 {
+			T = c.collection(left, U)
+		}
+		if right != -1 {
+			U = c.collection(right, T)
+		}
+		T = binaryOperatorType(operator, T, U, c.lineNum)
+		left = -1
+	}
+	if left != -1 {
+		panic(fmt.Sprintf("Line %d: %s can only be used in an expression by comparing it with == or !=", c.lineNum+1, c.tokens[left]))
+	}
+	return T
+}
+
+func (c *typeChecker) operand() (int, primitiveType) {
+	// returns the index of the operand if it is a collection, which is parsed once the type of the other side is known
+	if isComparedCollection(c.peek(), c.currentScope) {
+		c.pos++
+		switch c.peek() {
+		case "==", "!=", ">", "<", ">=", "<=", "&&", "||", ")", "}", "":
+			return c.pos - 1, Int // type isn't used until it is parsed
+		}
+		c.pos--
+	}
+	return -1, c.sum()
+}
+
+func (c *typeChecker) collection(index int, other primitiveType) primitiveType {
+	// parses a compared collection using the type of the other side if that is also a collection
+	token := c.tokens[index]
+	var value Transpileable
+	var T primitiveType
+	if isArraySlice(token, c.currentScope) && !isArray(other) {
+		// compared as an array, which it is converted to if its length is known
+		arr := parseArrayExpression(token, other, c.lineNum, c.currentScope)
+		value, T = copyArray(arr, arr.dataType, c.lineNum), arrayTypeID(arr.dataType)
+	} else {
+		value, T = parseExpectedValue(token, other, c.lineNum, c.currentScope)
+	}
+	if c.collections == nil {
+		c.collections = make(map[int]Transpileable)
+	}
+	c.collections[index] = ComparedCollection{value: value}
+	return T
+}
+
+func (c *typeChecker) sum() primitiveType {
+	return c.binary([]string{"+", "-", "|", "^"}, c.product)
+}
+
+func (c *typeChecker) product() primitiveType {
+	return c.binary([]string{"*", "/", "%", "div", "<<", ">>", "&"}, c.unary)
+}
+
+func (c *typeChecker) binary(operators []string, next func() primitiveType) primitiveType {
+	// left associative operators with the same precedence
+	start := c.pos
+	T := next()
+	for slices.Contains(operators, c.peek()) {
+		operator := c.peek()
+		operatorIndex := c.pos
+		c.pos++
+		T = binaryOperatorType(operator, T, next(), c.lineNum)
+		if operator == "div" {
+			c.operations = append(c.operations, [3]int{start, operatorIndex, c.pos})
+		}
+	}
+	return T
+}
+
+func (c *typeChecker) unary() primitiveType {
+	switch c.peek() {
+	case "-":
+		c.pos++
+		T := c.unary()
+		checkNegation(T, c.lineNum)
+		return T
+	case "!":
+		c.pos++
+		T := c.unary()
+		if underlyingType(T) != Bool {
+			panic(fmt.Sprintf("Line %d: Unary operator '!' used before non-boolean value", c.lineNum+1))
+		}
+		return T
+	}
+	return c.power()
+}
+
+func (c *typeChecker) power() primitiveType {
+	// ** is right associative and applies before unary operators e.g. -x ** 2 is -(x ** 2)
+	start := c.pos
+	T := c.primary()
+	if c.peek() == "**" {
+		operatorIndex := c.pos
+		c.pos++
+		T = binaryOperatorType("**", T, c.unary(), c.lineNum)
+		c.operations = append(c.operations, [3]int{start, operatorIndex, c.pos})
+	}
+	return T
+}
+
+func (c *typeChecker) primary() primitiveType {
+	token := c.peek()
+	c.pos++
+	if isComparedCollection(token, c.currentScope) {
+		panic(fmt.Sprintf("Line %d: %s can only be used in an expression by comparing it with == or !=", c.lineNum+1, token))
+	}
+	switch token {
+	case "(", "{":
+		T := c.or()
+		if closing := c.peek(); closing != ")" && closing != "}" {
+			panic(fmt.Sprintf("Line %d: bracket opened in expression but never closed", c.lineNum+1))
+		}
+		c.pos++
+		return T
+	case "":
+		panic(fmt.Sprintf("Line %d: expected another token in expression", c.lineNum+1))
+	}
+	if _, ok := binaryOperators()[token]; ok {
+		panic(fmt.Sprintf("Line %d: Expected value before operator %s", c.lineNum+1, token))
+	}
+	return expressionType([]string{token}, c.lineNum, c.currentScope)
+}
+
+func binaryOperatorType(operator string, left, right primitiveType, lineNum int) primitiveType {
+	// each operator has its own rules for the types it can be used with
+	switch operator {
+	case "+":
+		// can be used with strings as well
+		if underlyingType(left) == String && left == right {
+			return left
+		}
+		checkOperands(operator, left, right, arithmeticType, "non-numeric", lineNum)
+	case "-", "*", "/":
+		checkOperands(operator, left, right, arithmeticType, "non-numeric", lineNum)
+	case "**", "div":
+		checkOperands(operator, left, right, numericType, "non-numeric", lineNum)
+		if isTypeParameter(left) {
+			panic(fmt.Sprintf("Line %d: binary operator '%s' cannot be used with type parameter %v because it could be an int or a float", lineNum+1, operator, left))
+		}
+	case "%", "&", "|", "^":
+		checkOperands(operator, left, right, integerType, "non-integer", lineNum)
+	case "<<", ">>":
+		// the number of bits shifted by can be any integer type
+		if !integerType(left) {
+			panic(fmt.Sprintf("Line %d: binary operator '%s' used after non-integer type %v", lineNum+1, operator, left))
+		}
+		if !integerType(right) {
+			panic(fmt.Sprintf("Line %d: binary operator '%s' used before non-integer type %v", lineNum+1, operator, right))
+		}
+	case "||", "&&":
+		checkOperands(operator, left, right, func(T primitiveType) bool { return underlyingType(T) == Bool }, "non-boolean", lineNum)
+	default:
+		// comparative operators
+		if left != right {
+			panic(fmt.Sprintf("Line %d: Binary operator '%s' used with two different types %v and %v", lineNum+1, operator, left, right))
+		}
+		checkComparable(left, lineNum)
+		if (isRecord(left) || isEnum(left) || isArray(left) || isTuple(left) || underlyingType(left) == Complex) && operator != "==" && operator != "!=" {
+			panic(fmt.Sprintf("Line %d: values of type %v can only be compared with == and !=", lineNum+1, left))
+		}
+		if isTypeParameter(left) {
+			// operators allowed depend on the constraint of the type parameter
+			constraint := "ordered"
+			if operator == "==" || operator == "!=" {
+				constraint = "comparable"
 			}
-			// now they must already be the same
-			typesFound[Bool] = struct{}{}
-		case "==", ">", "<", ">=", "<=", "!=":
-			previous := previousTerm(expr, operatorIndex, lineNum)
-			next := nextTerm(expr, operatorIndex, lineNum)
-			previousType := expressionType(previous, lineNum, currentScope)
-			nextType := expressionType(next, lineNum, currentScope)
-			if previousType != nextType {
-				panic(fmt.Sprintf("Line %d: Binary operator '%s' used with two different types %v and %v", lineNum+1, expr[operatorIndex], previousType, nextType))
+			if !satisfies(left, constraint) {
+				d, _ := left.defined()
+				panic(fmt.Sprintf("Line %d: cannot use operator '%s' on values of type %v because it is only constrained by %s", lineNum+1, operator, left, d.constraint))
 			}
-			typesFound[Bool] = struct{}{}
 		}
+		return Bool
 	}
-	if len(typesFound) == 0 { // shouldn't even be possible to get this lol
-		panic(fmt.Sprintf("Line %d: expression has no data type", lineNum+1))
+	return left
+}
+
+func checkOperands(operator string, left, right primitiveType, allowed func(primitiveType) bool, kind string, lineNum int) {
+	if !allowed(left) {
+		panic(fmt.Sprintf("Line %d: binary operator '%s' used after %s type %v", lineNum+1, operator, kind, left))
 	}
-	if len(typesFound) != 1 {
-		panic(fmt.Sprintf("Line %d: expression contains more than one data type", lineNum+1))
+	if !allowed(right) {
+		panic(fmt.Sprintf("Line %d: binary operator '%s' used before %s type %v", lineNum+1, operator, kind, right))
 	}
-
-	var exprType primitiveType
-	for k := range typesFound { // all types in expression are the same, and the expression must have a type so this works
-		exprType = k
+	if left != right {
+		panic(fmt.Sprintf("Line %d: binary operator '%s' used with two different types %v and %v", lineNum+1, operator, left, right))
 	}
-	return exprType
 }
 
 func checkIntVal(value string, lineNum int) { // checks to see if int value contains illegal characters/leading zeros etc.
@@ -431,9 +760,26 @@ func checkIntVal(value string, lineNum int) { // checks to see if int value cont
 
 func checkFloatVal(value string, lineNum int) {
 	// check valid float literal
+	if e := strings.IndexAny(value, "eE"); e != -1 {
+		// scientific notation e.g. 6.674e-11
+		exponent := value[e+1:]
+		if len(exponent) != 0 && (exponent[0] == '-' || exponent[0] == '+') {
+			exponent = exponent[1:]
+		}
+		if len(exponent) == 0 {
+			panic(fmt.Sprintf("Line %d: expected exponent after %c in float value %s", lineNum+1, value[e], value))
+		}
+		for _, char := range exponent {
+			if !(char > 47 && char < 58) {
+				panic(fmt.Sprintf("Line %d: character %c cannot be part of the exponent of a float value", lineNum+1, char))
+			}
+		}
+		value = value[:e]
+	}
+
 	switch value[0] {
 	case '0':
-		if !(value[1] == '.') {
+		if len(value) > 1 && value[1] != '.' {
 			panic(fmt.Sprintf("Line %d: Leading zeros must be followed by decimal point, here it is followed by %c", lineNum+1, value[1]))
 		}
 	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
@@ -454,6 +800,25 @@ func checkFloatVal(value string, lineNum int) {
 	}
 }
 
+func checkNegation(T primitiveType, lineNum int) {
+	// checks the value after a unary -
+	if !arithmeticType(T) {
+		panic(fmt.Sprintf("Line %d: Unary operator '-' found before non numeric type", lineNum+1))
+	}
+	if unsignedType(T) {
+		panic(fmt.Sprintf("Line %d: cannot use unary operator - with unsigned data type %v", lineNum+1, T))
+	}
+}
+
+func isExponent(item string) bool {
+	// whether a token is a number ending in e, so that a + or - after it
+	// is the sign of the exponent rather than an operator
+	if len(item) < 2 || parseCharType(item[0]) != number {
+		return false
+	}
+	return item[len(item)-1] == 'e' || item[len(item)-1] == 'E'
+}
+
 func checkBoolVal(value string, lineNum int) {
 	// valid bool literal
 	if !(value == "true" || value == "false") {
@@ -0,0 +1,72 @@
+package transpiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// variadic functions in Go
+/**
+function largest(first: float, rest: ...float) -> float = {
+	let mut best: float = first
+	loop x in rest {
+		if x > best {
+			best = x
+		}
+	}
+	best
+}
+
+largest(1.0, 4.0, 2.0)
+largest(1.0, xs...)
+
+func largest(first float64, rest ...float64) float64 {
+	...
+}
+
+largest(1.0, 4.0, 2.0)
+largest(1.0, xs[:]...)
+
+// inside the function the variadic parameter is an immutable array of any length
+// rest: ...float[n] also binds the number of arguments to n, as with array parameters
+*/
+
+func parseVariadicArguments(param Array, arguments []string, bindings map[primitiveType]primitiveType, lineNum int, currentScope *Scope) []Transpileable {
+	// checks each argument against the type of the elements of the variadic parameter
+	elementType := param.dataType.baseType
+	var parsed []Transpileable
+	for _, argument := range arguments {
+		if strings.HasSuffix(argument, "...") {
+			// the elements of an array are passed as the arguments
+			if len(arguments) != 1 {
+				panic(fmt.Sprintf("Line %d: an array passed to variadic parameter %s with ... must be its only argument", lineNum+1, param.identifier))
+			}
+			array := strings.TrimSuffix(argument, "...")
+			baseType := substitute(elementType, bindings)
+			if isTypeParameter(baseType) && array[0] == '[' {
+				baseType = literalBaseType(array, lineNum, currentScope)
+			}
+			arrayExpression := parseArrayExpression(array, baseType, lineNum, currentScope)
+			if !unify(elementType, arrayExpression.dataType.baseType, bindings) {
+				panic(fmt.Sprintf("Line %d: cannot pass array of type %v to variadic parameter of type %v", lineNum+1, arrayExpression.dataType.baseType, substitute(elementType, bindings)))
+			}
+			arrayExpression = copyArray(arrayExpression, ArrayType{
+				baseType:   arrayExpression.dataType.baseType,
+				dimensions: []int{-1},
+			}, lineNum)
+			return []Transpileable{SliceArgument{array: arrayExpression, spread: true}}
+		}
+
+		if isTuple(elementType) {
+			t, _ := elementType.defined()
+			parsed = append(parsed, parseTupleExpression(argument, TuplePattern{dataTypes: t.elements}, lineNum, currentScope))
+			continue
+		}
+		expression := parseContextualExpression(argument, elementType, lineNum, currentScope)
+		if !unify(elementType, expression.dataType, bindings) {
+			panic(fmt.Sprintf("Line %d: cannot use expression of type %v as argument of type %v", lineNum+1, expression.dataType.String(), substitute(elementType, bindings).String()))
+		}
+		parsed = append(parsed, expression)
+	}
+	return parsed
+}